@@ -0,0 +1,216 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// requestAttributes returns the request fields exposed under the "request" attribute root.
+func requestAttributes(r *Request) map[string]interface{} {
+	return map[string]interface{}{
+		"resource": r.Resource,
+		"action":   r.Action,
+		"role":     r.Role,
+		"subject":  r.Role,
+		"scope":    r.Scope,
+	}
+}
+
+// resolveAttribute resolves a root attribute name against r. The "request" root exposes the request fields,
+// "meta" the request metadata and any other name is looked up with RequestAttribute, in the request metadata
+// and then through the attribute providers of the enforcer.
+func resolveAttribute(r *Request, name string) (interface{}, bool, error) {
+	if r == nil {
+		return nil, false, nil
+	}
+
+	switch name {
+	case "request":
+		return requestAttributes(r), true, nil
+	case "meta":
+		return r.Metadata(), true, nil
+	}
+
+	v, ok, err := RequestAttribute(r, name)
+	if err != nil {
+		return nil, false, &providerError{name: name, err: err}
+	}
+
+	return v, ok, nil
+}
+
+// providerError is returned when an attribute provider fails, to tell it apart from undefined attributes.
+type providerError struct {
+	name string
+	err  error
+}
+
+func (e *providerError) Error() string {
+	return "attribute " + e.name + ": " + e.err.Error()
+}
+
+func (e *providerError) Unwrap() error {
+	return e.err
+}
+
+// attributeField returns the element of v addressed by key. Maps are indexed by string keys, http.Header by
+// header names in any case, slices and arrays by integer indices and structs by the json names of their
+// exported fields.
+func attributeField(v interface{}, key interface{}) (interface{}, bool) {
+	if h, ok := v.(http.Header); ok {
+		ks, ok := key.(string)
+		if !ok {
+			return nil, false
+		}
+
+		vals, ok := h[http.CanonicalHeaderKey(ks)]
+
+		return vals, ok
+	}
+
+	rv := indirectValue(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		ks, ok := key.(string)
+		if !ok {
+			return nil, false
+		}
+
+		return structField(rv, ks)
+	case reflect.Map:
+		ks, ok := key.(string)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+
+		fv := rv.MapIndex(reflect.ValueOf(ks).Convert(rv.Type().Key()))
+		if !fv.IsValid() {
+			return nil, false
+		}
+
+		return fv.Interface(), true
+	case reflect.Slice, reflect.Array:
+		i, ok := attributeIndex(key)
+		if !ok || i < 0 || i >= rv.Len() {
+			return nil, false
+		}
+
+		return rv.Index(i).Interface(), true
+	}
+
+	return nil, false
+}
+
+// structField returns the exported field of rv whose json name, or Go name when it has no json tag, is
+// name. Fields of embedded structs without a json tag are promoted.
+func structField(rv reflect.Value, name string) (interface{}, bool) {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && tag == "" {
+			if fv := indirectValue(rv.Field(i)); fv.Kind() == reflect.Struct {
+				if v, ok := structField(fv, name); ok {
+					return v, true
+				}
+			}
+
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if tag == name || tag == "" && f.Name == name {
+			fv := rv.Field(i)
+			if !fv.CanInterface() {
+				return nil, false
+			}
+
+			return fv.Interface(), true
+		}
+	}
+
+	return nil, false
+}
+
+// attributeIndex converts a numeric key into a slice index.
+func attributeIndex(key interface{}) (int, bool) {
+	f, ok := toFloat(key)
+	if !ok || f != float64(int(f)) {
+		return 0, false
+	}
+
+	return int(f), true
+}
+
+// indirectValue dereferences pointers and interfaces until it reaches a concrete value.
+func indirectValue(rv reflect.Value) reflect.Value {
+	for rv.IsValid() && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+
+		rv = rv.Elem()
+	}
+
+	return rv
+}
+
+// compileAttributeRef parses an attribute reference such as meta.owner_id, request.role or
+// claims.groups[0]. References use the path syntax of expressions but may not contain operators, calls or
+// computed indices.
+func compileAttributeRef(src string) (exprNode, error) {
+	if src == "" {
+		return nil, errors.New("attribute reference is required")
+	}
+
+	n, err := compileExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAttributePath(n) {
+		return nil, fmt.Errorf("%s is not an attribute reference", src)
+	}
+
+	return n, nil
+}
+
+func isAttributePath(n exprNode) bool {
+	switch n := n.(type) {
+	case *exprIdent:
+		return true
+	case *exprMember:
+		return isAttributePath(n.object)
+	case *exprIndex:
+		lit, ok := n.index.(*exprLiteral)
+		if !ok {
+			return false
+		}
+
+		switch lit.val.(type) {
+		case string, float64:
+			return isAttributePath(n.object)
+		}
+	}
+
+	return false
+}
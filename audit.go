@@ -24,6 +24,19 @@ type Auditor interface {
 	LogPolicyEffect(req *Request, effect PolicyEffect)
 }
 
+// ConditionEvaluation records the result of evaluating a condition of a policy.
+type ConditionEvaluation struct {
+	Policy    string
+	Condition string
+	ConditionResult
+}
+
+// ConditionAuditor is implemented by Auditors that log the conditions evaluated for a request along with their
+// explanations. The enforcer only collects evaluations when its Auditor implements it.
+type ConditionAuditor interface {
+	LogConditions(req *Request, evals []ConditionEvaluation)
+}
+
 // NewConsoleAuditor returns an Auditor that prints the audit log to stdout.
 func NewConsoleAuditor(lvl AuditLevel) Auditor {
 	return &consoleAuditor{
@@ -40,6 +53,7 @@ const (
 	logReq   = "[AUDIT_REQ]:"
 	logAllow = "[AUDIT_ALLOW]:"
 	logDeny  = "[AUDIT_DENY]:"
+	logCond  = "[AUDIT_COND]:"
 )
 
 // LogRequest prints the request to console if AuditLevel is at or above AuditRequest.
@@ -58,3 +72,19 @@ func (a *consoleAuditor) LogPolicyEffect(req *Request, effect PolicyEffect) {
 		log.Printf(logfmt, logAllow, req.Action, req.Resource, req.Role, req.Scope)
 	}
 }
+
+// LogConditions prints the evaluated conditions of a request to console if AuditLevel is AuditAll.
+func (a *consoleAuditor) LogConditions(req *Request, evals []ConditionEvaluation) {
+	if a.lvl < AuditAll {
+		return
+	}
+
+	for _, ev := range evals {
+		switch {
+		case ev.Err != nil:
+			log.Printf("%s policy=%s condition=%s error=%q\n", logCond, ev.Policy, ev.Condition, ev.Err)
+		default:
+			log.Printf("%s policy=%s condition=%s met=%t reason=%q\n", logCond, ev.Policy, ev.Condition, ev.Met, ev.Reason)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Captures holds the values captured by a match, keyed by capture name. Regular expression groups are also
+// keyed by their number, starting at "1".
+type Captures map[string]string
+
+// CaptureMatcher is implemented by matchers that report the captures of their matches. The enforcer makes the
+// captures of a policy's action, resource and scope matches available to its conditions and templates under
+// captures.action, captures.resource and captures.scope.
+type CaptureMatcher interface {
+	MatchPolicyCaptures(p Policy, def []string, val string) (bool, Captures, error)
+}
+
+// capturesKey identifies the captures of the policy under evaluation in a request context.
+type capturesKey struct{}
+
+// RequestCaptures returns the captures of the policy under evaluation by field, such as "resource", or nil
+// outside of policy evaluation.
+func RequestCaptures(r *Request) map[string]Captures {
+	if r == nil || r.Context == nil {
+		return nil
+	}
+
+	caps, _ := r.Context.Value(capturesKey{}).(map[string]Captures)
+
+	return caps
+}
+
+// withCaptures returns a copy of r whose context carries the captures of field in addition to those r already
+// carries.
+func withCaptures(r *Request, field string, c Captures) *Request {
+	prev := RequestCaptures(r)
+
+	caps := make(map[string]Captures, len(prev)+1)
+	for k, v := range prev {
+		caps[k] = v
+	}
+
+	caps[field] = c
+
+	rc := *r
+	rc.Context = context.WithValue(nonNilContext(r.Context), capturesKey{}, caps)
+
+	return &rc
+}
+
+// templateMeta holds the characters a substituted value may not contain, since matchers would read them as
+// pattern syntax.
+const templateMeta = `*?[]{}<>\`
+
+// ExpandTemplate replaces the ${reference} placeholders of s with the attributes they reference, such as
+// ${request.subject}, ${meta.tenant} or ${captures.resource.id}. A reference that is undefined, does not hold
+// a string, number or boolean, or whose value contains pattern characters is an error.
+func ExpandTemplate(s string, r *Request) (string, error) {
+	if !isTemplate(s) {
+		return s, nil
+	}
+
+	var sb strings.Builder
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("template %q: unclosed placeholder", s)
+		}
+
+		ref := s[i+2 : i+end]
+
+		path, err := compileAttributeRef(ref)
+		if err != nil {
+			return "", fmt.Errorf("template %q: %w", s, err)
+		}
+
+		v, err := path.eval(requestEnv{req: r})
+		if err != nil {
+			return "", fmt.Errorf("template %q: %w", s, err)
+		}
+
+		var val string
+
+		switch v := v.(type) {
+		case string:
+			val = v
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			val = fmt.Sprint(v)
+		default:
+			return "", fmt.Errorf("template %q: %s is not a string", s, ref)
+		}
+
+		if strings.ContainsAny(val, templateMeta) {
+			return "", fmt.Errorf("template %q: %s contains pattern characters", s, ref)
+		}
+
+		sb.WriteString(s[:i])
+		sb.WriteString(val)
+		s = s[i+end+1:]
+	}
+}
+
+// templateError reports a template of a policy that could not be expanded for a request. It makes that policy
+// indeterminate instead of failing the evaluation of the others.
+type templateError struct {
+	err error
+}
+
+func (e *templateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *templateError) Unwrap() error {
+	return e.err
+}
+
+// expandTemplates expands the templates of def, returning def itself when none holds a placeholder.
+func expandTemplates(def []string, r *Request) ([]string, error) {
+	var out []string
+
+	for i, s := range def {
+		if !isTemplate(s) {
+			if out != nil {
+				out = append(out, s)
+			}
+
+			continue
+		}
+
+		if out == nil {
+			out = append(make([]string, 0, len(def)), def[:i]...)
+		}
+
+		x, err := ExpandTemplate(s, r)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, x)
+	}
+
+	if out == nil {
+		return def, nil
+	}
+
+	return out, nil
+}
+
+// validateTemplate checks the placeholders of s without resolving them.
+func validateTemplate(s string) error {
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			return nil
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return fmt.Errorf("unclosed placeholder")
+		}
+
+		if _, err := compileAttributeRef(s[i+2 : i+end]); err != nil {
+			return err
+		}
+
+		s = s[i+end+1:]
+	}
+}
+
+// isTemplate tells whether s holds placeholders, which can only be matched once expanded.
+func isTemplate(s string) bool {
+	return strings.Contains(s, "${")
+}
@@ -0,0 +1,228 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	r := withCaptures(
+		NewRequest("documents/42", "read", "alice", "", map[string]interface{}{"tenant": "acme", "level": 3, "bad": "a*"}),
+		"resource", Captures{"id": "42"},
+	)
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"documents/*", "documents/*", false},
+		{"users/${request.subject}/*", "users/alice/*", false},
+		{"${meta.tenant}:${captures.resource.id}", "acme:42", false},
+		{"level${level}", "level3", false},
+		{"users/${meta.missing}", "", true},
+		{"users/${meta.bad}", "", true},
+		{"users/${captures}", "", true},
+		{"users/${request.subject", "", true},
+		{"users/${1 + 1}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandTemplate(tt.template, r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ExpandTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	if _, err := NewPolicy(
+		PolicyName("bad_template"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("users/${request.}"),
+	); err == nil {
+		t.Error("NewPolicy() with an invalid template error = nil")
+	}
+}
+
+func TestMatcherCaptures(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		def     []string
+		val     string
+		want    Captures
+	}{
+		{
+			name:    "regex numbered",
+			matcher: NewRegexMatcher(),
+			def:     []string{"documents/<[0-9]+>"},
+			val:     "documents/42",
+			want:    Captures{"1": "42"},
+		},
+		{
+			name:    "regex named",
+			matcher: NewRegexMatcher(),
+			def:     []string{"users/*", "<(?P<kind>[a-z]+)>/<(?P<id>[0-9]+)>"},
+			val:     "documents/42",
+			want:    Captures{"1": "documents", "2": "documents", "kind": "documents", "3": "42", "4": "42", "id": "42"},
+		},
+		{
+			name:    "regex wildcard",
+			matcher: NewRegexMatcher(),
+			def:     []string{"documents/*"},
+			val:     "documents/42",
+		},
+		{
+			name:    "glob",
+			matcher: NewGlobMatcher(),
+			def:     []string{"articles/*/comments/{[0-9]*,new}"},
+			val:     "articles/1/comments/9",
+			want:    Captures{"1": "1", "2": "9", "3": "9", "4": ""},
+		},
+		{
+			name:    "glob segments",
+			matcher: NewGlobMatcher(),
+			def:     []string{"files/**/*.txt"},
+			val:     "files/a/b/c.txt",
+			want:    Captures{"1": "a/b", "2": "c"},
+		},
+		{
+			name:    "resource name",
+			matcher: NewResourceNameMatcher(DefaultResourceSchema, nil),
+			def:     []string{"rn:docs:*:acme:document/*"},
+			val:     "rn:docs:eu:acme:document/42",
+			want:    Captures{"service": "docs", "region": "eu", "tenant": "acme", "type": "document", "id": "42"},
+		},
+		{
+			name:    "resource name fallback",
+			matcher: NewResourceNameMatcher(DefaultResourceSchema, NewRegexMatcher()),
+			def:     []string{"<read|write>"},
+			val:     "write",
+			want:    Captures{"1": "write"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, caps, err := tt.matcher.(CaptureMatcher).MatchPolicyCaptures(nil, tt.def, tt.val)
+			if err != nil || !match {
+				t.Fatalf("MatchPolicyCaptures() = %v, %v", match, err)
+			}
+
+			if len(caps) != len(tt.want) || (len(caps) > 0 && !reflect.DeepEqual(caps, tt.want)) {
+				t.Errorf("MatchPolicyCaptures() captures = %v, want %v", caps, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceCaptures(t *testing.T) {
+	pm := NewManager()
+
+	owner := MustNewPolicy(
+		PolicyName("owned_documents"),
+		PolicyAllow(),
+		WithRole(NewRole("user")),
+		SetActions("edit"),
+		SetResources("documents/<(?P<id>[0-9]+)>"),
+		WithCondition(ConditionOptions{
+			Name: "owner",
+			Type: "attr_in",
+			Options: map[string]interface{}{
+				"left":  "captures.resource.id",
+				"right": "meta.owned",
+			},
+		}),
+	)
+
+	tenant := MustNewPolicy(
+		PolicyName("tenant_reports"),
+		PolicyAllow(),
+		WithRole(NewRole("user")),
+		SetActions("read"),
+		SetResources("tenants/<(?P<tenant>[a-z]+)>/reports/<.+>"),
+		SetScopes("${captures.resource.tenant}", "${meta.home}"),
+	)
+
+	for _, p := range []Policy{owner, tenant} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewEnforcer(pm, NewRegexMatcher(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := map[string]interface{}{"owned": []interface{}{"7", "42"}, "home": "globex"}
+
+	tests := []struct {
+		name string
+		req  *Request
+		want bool
+	}{
+		{"owned document", NewRequest("documents/42", "edit", "user", "", owned), true},
+		{"other document", NewRequest("documents/43", "edit", "user", "", owned), false},
+		{"own tenant", NewRequest("tenants/acme/reports/q1", "read", "user", "acme", owned), true},
+		{"home tenant", NewRequest("tenants/acme/reports/q1", "read", "user", "globex", owned), true},
+		{"other tenant", NewRequest("tenants/acme/reports/q1", "read", "user", "initech", owned), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := e.Enforce(tt.req); (err == nil) != tt.want {
+				t.Errorf("Enforce() = %v, want allowed %v", err, tt.want)
+			}
+
+			if RequestCaptures(tt.req) != nil {
+				t.Error("Enforce() left captures on the caller's request")
+			}
+		})
+	}
+}
+
+func TestEnforceTemplateFailure(t *testing.T) {
+	pm := NewManager()
+
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("public"), PolicyAllow(), WithRole(NewRole("user")), SetActions("read"), SetResources("public/*")),
+		MustNewPolicy(PolicyName("tenant"), PolicyAllow(), WithRole(NewRole("user")), SetActions("read"), SetResources("tenants/${meta.tenant}/*")),
+		MustNewPolicy(PolicyName("archived"), PolicyDeny(), WithRole(NewRole("user")), SetActions("delete"), SetResources("archive/${meta.tenant}/*")),
+		MustNewPolicy(PolicyName("archive"), PolicyAllow(), WithRole(NewRole("user")), SetActions("delete"), SetResources("archive/*")),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewEnforcer(pm, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *Request
+		want bool
+	}{
+		{"public without tenant", NewRequest("public/x", "read", "user", "", nil), true},
+		{"public with pattern tenant", NewRequest("public/x", "read", "user", "", map[string]interface{}{"tenant": "a*"}), true},
+		{"own tenant", NewRequest("tenants/acme/x", "read", "user", "", map[string]interface{}{"tenant": "acme"}), true},
+		{"tenant without tenant", NewRequest("tenants/acme/x", "read", "user", "", nil), false},
+		{"pattern tenant", NewRequest("tenants/acme/x", "read", "user", "", map[string]interface{}{"tenant": "a*"}), false},
+		{"archive of another tenant", NewRequest("archive/acme/x", "delete", "user", "", map[string]interface{}{"tenant": "globex"}), true},
+		{"own archive", NewRequest("archive/acme/x", "delete", "user", "", map[string]interface{}{"tenant": "acme"}), false},
+		{"archive without tenant", NewRequest("archive/acme/x", "delete", "user", "", nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := e.Enforce(tt.req); (err == nil) != tt.want {
+				t.Errorf("Enforce() = %v, want allowed %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/manager"
 	"github.com/urfave/cli/v2"
 )
 
@@ -15,7 +16,7 @@ func policyCmd() *cli.Command {
 		Name:        "policy",
 		Usage:       "policy subcommand",
 		Category:    "policy",
-		Subcommands: []*cli.Command{policyBuildCmd()},
+		Subcommands: []*cli.Command{policyBuildCmd(), policyValidateCmd(), policyMigrateCmd()},
 	}
 }
 
@@ -34,6 +35,10 @@ func policyBuildAction(ctx *cli.Context) error {
 		return err
 	}
 
+	if err := reportValidation(redtape.ValidatePolicyOptions(*p, nil)); err != nil {
+		return err
+	}
+
 	b, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
 		return err
@@ -44,6 +49,101 @@ func policyBuildAction(ctx *cli.Context) error {
 	return nil
 }
 
+func policyValidateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "validate",
+		Usage:     "validate a policy document",
+		ArgsUsage: "<policy.json>",
+		Category:  "policy",
+		Action:    policyValidateAction,
+	}
+}
+
+func policyValidateAction(ctx *cli.Context) error {
+	b, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var opts redtape.PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil {
+		return err
+	}
+
+	if err := reportValidation(redtape.ValidatePolicyOptions(opts, nil)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "policy %s is valid\n", opts.Name)
+
+	return nil
+}
+
+// reportValidation prints warnings and errors to stderr and returns the validation error.
+func reportValidation(v *redtape.Validation) error {
+	for _, p := range v.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", p)
+	}
+
+	for _, p := range v.Errors() {
+		fmt.Fprintf(os.Stderr, "error: %s\n", p)
+	}
+
+	if len(v.Errors()) > 0 {
+		return fmt.Errorf("policy has %d error(s)", len(v.Errors()))
+	}
+
+	return nil
+}
+
+func policyMigrateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "migrate",
+		Usage:     "convert embedded roles of a policy document into role references",
+		ArgsUsage: "<policy.json>",
+		Category:  "policy",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "path", Usage: "directory of the role store", Value: "."},
+			&cli.StringFlag{Name: "name", Usage: "name of the role store", Value: "redtape"},
+		},
+		Action: policyMigrateAction,
+	}
+}
+
+func policyMigrateAction(ctx *cli.Context) error {
+	b, err := os.ReadFile(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var opts redtape.PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil {
+		return err
+	}
+
+	rm, err := manager.NewFile(func(o *manager.FileOptions) {
+		o.Path = ctx.String("path")
+		o.Name = ctx.String("name")
+	}).RoleManager()
+	if err != nil {
+		return err
+	}
+
+	migrated, err := redtape.MigrateRoles(opts, rm)
+	if err != nil {
+		return err
+	}
+
+	b, err = json.MarshalIndent(migrated, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, string(b))
+
+	return nil
+}
+
 type surveyPolicy struct {
 	Name        string
 	Description string
@@ -88,6 +188,13 @@ func newPolicySurvey() (*redtape.PolicyOptions, error) {
 
 	a.Actions = act
 
+	roles, err := rangePrompt("match roles")
+	if err != nil {
+		return nil, err
+	}
+
+	a.Roles = roles
+
 	p := a.build()
 
 	return &p, nil
@@ -100,6 +207,7 @@ func (p surveyPolicy) build() redtape.PolicyOptions {
 		redtape.SetResources(p.Resources...),
 		redtape.SetActions(p.Actions...),
 		redtape.SetScopes(p.Scopes...),
+		redtape.WithRoleRefs(p.Roles...),
 		redtape.SetPolicyEffect(p.Effect),
 	)
 }
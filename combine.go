@@ -0,0 +1,137 @@
+package redtape
+
+// CombiningAlgorithm names the strategy used to reduce the effects of several policies into a single effect.
+type CombiningAlgorithm string
+
+const (
+	// DenyOverrides denies a request when any applicable policy denies it.
+	DenyOverrides CombiningAlgorithm = "deny_overrides"
+	// AllowOverrides allows a request when any applicable policy allows it.
+	AllowOverrides CombiningAlgorithm = "allow_overrides"
+	// FirstApplicable applies the effect of the first applicable policy in evaluation order.
+	FirstApplicable CombiningAlgorithm = "first_applicable"
+)
+
+// NewCombiningAlgorithm returns a CombiningAlgorithm for a given string, defaulting to DenyOverrides.
+func NewCombiningAlgorithm(s string) CombiningAlgorithm {
+	switch s {
+	case "allow_overrides":
+		return AllowOverrides
+	case "first_applicable":
+		return FirstApplicable
+	default:
+		return DenyOverrides
+	}
+}
+
+// decision is the outcome of evaluating a policy or policy set against a request.
+type decision int
+
+const (
+	decisionNotApplicable decision = iota
+	decisionAllow
+	decisionDeny
+	// decisionIndeterminate is reached when a policy applies to the request but one of its conditions could
+	// not be evaluated.
+	decisionIndeterminate
+)
+
+// evaluation carries a decision along with the policy responsible for it and, for indeterminate decisions,
+// the error that caused it and the decisions that might have been reached had it not failed, as in the
+// Indeterminate{D}, {P} and {DP} of XACML.
+type evaluation struct {
+	decision   decision
+	policy     Policy
+	err        error
+	couldAllow bool
+	couldDeny  bool
+}
+
+// indeterminateEvaluation returns the indeterminate evaluation of p failing with err, which could only have
+// reached the effect of p.
+func indeterminateEvaluation(p Policy, err error) evaluation {
+	return evaluation{
+		decision:   decisionIndeterminate,
+		policy:     p,
+		err:        err,
+		couldAllow: p.Effect() == PolicyEffectAllow,
+		couldDeny:  p.Effect() == PolicyEffectDeny,
+	}
+}
+
+// combine evaluates n children in order and reduces their decisions with alg. Evaluation stops as soon as
+// the algorithm has reached a final decision. An indeterminate child only takes precedence when it might have
+// produced the overriding effect: with DenyOverrides an indeterminate deny wins over allows, while an
+// indeterminate allow loses to an allow and only decides when nothing else applies. The other way around
+// with AllowOverrides.
+func combine(alg CombiningAlgorithm, n int, eval func(int) (evaluation, error)) (evaluation, error) {
+	// couldDeny and couldAllow hold the first indeterminate child that might have denied or allowed
+	var allow, deny, couldDeny, couldAllow *evaluation
+
+	for i := 0; i < n; i++ {
+		ev, err := eval(i)
+		if err != nil {
+			return evaluation{}, err
+		}
+
+		switch ev.decision {
+		case decisionNotApplicable:
+			continue
+		case decisionAllow:
+			if alg == AllowOverrides || alg == FirstApplicable {
+				return ev, nil
+			}
+
+			if allow == nil {
+				allow = &ev
+			}
+		case decisionDeny:
+			if alg == DenyOverrides || alg == FirstApplicable {
+				return ev, nil
+			}
+
+			if deny == nil {
+				deny = &ev
+			}
+		case decisionIndeterminate:
+			if alg == FirstApplicable {
+				return ev, nil
+			}
+
+			if ev.couldDeny && couldDeny == nil {
+				couldDeny = &ev
+			}
+
+			if ev.couldAllow && couldAllow == nil {
+				couldAllow = &ev
+			}
+		}
+	}
+
+	// the overriding effect has returned early
+	if alg == AllowOverrides {
+		return settle(couldAllow, deny, couldDeny), nil
+	}
+
+	return settle(couldDeny, allow, couldAllow), nil
+}
+
+// settle reduces what is left once the overriding effect did not decide. An indeterminate child that might
+// have overridden wins, and might also have reached the other effect when other children did. Otherwise the
+// other effect is decided by a clean child before an indeterminate one.
+func settle(overriding, other, couldOther *evaluation) evaluation {
+	switch {
+	case overriding != nil:
+		ev := *overriding
+		ev.couldAllow = ev.couldAllow || other != nil && other.decision == decisionAllow || couldOther != nil && couldOther.couldAllow
+		ev.couldDeny = ev.couldDeny || other != nil && other.decision == decisionDeny || couldOther != nil && couldOther.couldDeny
+
+		return ev
+	case other != nil:
+		return *other
+	case couldOther != nil:
+		return *couldOther
+	default:
+		return evaluation{decision: decisionNotApplicable}
+	}
+}
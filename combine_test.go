@@ -0,0 +1,57 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCombineIndeterminate(t *testing.T) {
+	policy := func(name string, effect PolicyOption) Policy {
+		return MustNewPolicy(PolicyName(name), SetActions("read"), WithRole(NewRole("staff")), effect)
+	}
+
+	var (
+		allow    = evaluation{decision: decisionAllow, policy: policy("allow", PolicyAllow())}
+		deny     = evaluation{decision: decisionDeny, policy: policy("deny", PolicyDeny())}
+		indAllow = indeterminateEvaluation(policy("ind_allow", PolicyAllow()), errors.New("failed"))
+		indDeny  = indeterminateEvaluation(policy("ind_deny", PolicyDeny()), errors.New("failed"))
+	)
+
+	tests := []struct {
+		name       string
+		alg        CombiningAlgorithm
+		children   []evaluation
+		want       decision
+		wantPolicy string
+		couldAllow bool
+		couldDeny  bool
+	}{
+		{"deny overrides indeterminate allow and allow", DenyOverrides, []evaluation{indAllow, allow}, decisionAllow, "allow", false, false},
+		{"deny overrides indeterminate allow alone", DenyOverrides, []evaluation{indAllow}, decisionIndeterminate, "ind_allow", true, false},
+		{"deny overrides indeterminate deny and allow", DenyOverrides, []evaluation{allow, indDeny}, decisionIndeterminate, "ind_deny", true, true},
+		{"deny overrides indeterminate deny alone", DenyOverrides, []evaluation{indDeny}, decisionIndeterminate, "ind_deny", false, true},
+		{"deny overrides deny", DenyOverrides, []evaluation{indDeny, deny}, decisionDeny, "deny", false, false},
+		{"allow overrides indeterminate deny and deny", AllowOverrides, []evaluation{indDeny, deny}, decisionDeny, "deny", false, false},
+		{"allow overrides indeterminate allow and deny", AllowOverrides, []evaluation{deny, indAllow}, decisionIndeterminate, "ind_allow", true, true},
+		{"allow overrides allow", AllowOverrides, []evaluation{indAllow, allow}, decisionAllow, "allow", false, false},
+		{"first applicable", FirstApplicable, []evaluation{indAllow, allow}, decisionIndeterminate, "ind_allow", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := combine(tt.alg, len(tt.children), func(i int) (evaluation, error) {
+				return tt.children[i], nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ev.decision != tt.want || ev.policy.ID() != tt.wantPolicy {
+				t.Fatalf("combine() = %v from %s, want %v from %s", ev.decision, ev.policy.ID(), tt.want, tt.wantPolicy)
+			}
+
+			if ev.couldAllow != tt.couldAllow || ev.couldDeny != tt.couldDeny {
+				t.Errorf("combine() could allow %v, deny %v, want %v, %v", ev.couldAllow, ev.couldDeny, tt.couldAllow, tt.couldDeny)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package redtape
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -22,6 +24,84 @@ func NewConditionRegistry(conds ...map[string]ConditionBuilder) ConditionRegistr
 		new(RoleEqualsCondition).Name(): func() Condition {
 			return new(RoleEqualsCondition)
 		},
+		new(AllCondition).Name(): func() Condition {
+			return new(AllCondition)
+		},
+		new(AnyCondition).Name(): func() Condition {
+			return new(AnyCondition)
+		},
+		new(NotCondition).Name(): func() Condition {
+			return new(NotCondition)
+		},
+		new(ExprCondition).Name(): func() Condition {
+			return new(ExprCondition)
+		},
+		new(StringEqualsCondition).Name(): func() Condition {
+			return new(StringEqualsCondition)
+		},
+		new(StringNotEqualsCondition).Name(): func() Condition {
+			return new(StringNotEqualsCondition)
+		},
+		new(StringInCondition).Name(): func() Condition {
+			return new(StringInCondition)
+		},
+		new(StringPrefixCondition).Name(): func() Condition {
+			return new(StringPrefixCondition)
+		},
+		new(StringSuffixCondition).Name(): func() Condition {
+			return new(StringSuffixCondition)
+		},
+		new(StringContainsCondition).Name(): func() Condition {
+			return new(StringContainsCondition)
+		},
+		new(StringRegexCondition).Name(): func() Condition {
+			return new(StringRegexCondition)
+		},
+		new(StringGlobCondition).Name(): func() Condition {
+			return new(StringGlobCondition)
+		},
+		numericOperators[numericEq].name:  numericConditionBuilder(numericEq),
+		numericOperators[numericNe].name:  numericConditionBuilder(numericNe),
+		numericOperators[numericLt].name:  numericConditionBuilder(numericLt),
+		numericOperators[numericLte].name: numericConditionBuilder(numericLte),
+		numericOperators[numericGt].name:  numericConditionBuilder(numericGt),
+		numericOperators[numericGte].name: numericConditionBuilder(numericGte),
+		new(NumericBetweenCondition).Name(): func() Condition {
+			return new(NumericBetweenCondition)
+		},
+		new(TimeOfDayCondition).Name(): func() Condition {
+			return new(TimeOfDayCondition)
+		},
+		new(DayOfWeekCondition).Name(): func() Condition {
+			return new(DayOfWeekCondition)
+		},
+		new(DateRangeCondition).Name(): func() Condition {
+			return new(DateRangeCondition)
+		},
+		new(AttrEqualsCondition).Name(): func() Condition {
+			return new(AttrEqualsCondition)
+		},
+		new(AttrNotEqualsCondition).Name(): func() Condition {
+			return new(AttrNotEqualsCondition)
+		},
+		new(AttrInCondition).Name(): func() Condition {
+			return new(AttrInCondition)
+		},
+		new(AttrSubsetCondition).Name(): func() Condition {
+			return new(AttrSubsetCondition)
+		},
+		new(ContainsAnyCondition).Name(): func() Condition {
+			return new(ContainsAnyCondition)
+		},
+		new(ContainsAllCondition).Name(): func() Condition {
+			return new(ContainsAllCondition)
+		},
+		new(SubsetOfCondition).Name(): func() Condition {
+			return new(SubsetOfCondition)
+		},
+		new(IntersectsWithCondition).Name(): func() Condition {
+			return new(IntersectsWithCondition)
+		},
 	}
 
 	for _, ce := range conds {
@@ -33,15 +113,103 @@ func NewConditionRegistry(conds ...map[string]ConditionBuilder) ConditionRegistr
 	return reg
 }
 
+var (
+	sharedMu         sync.RWMutex
+	sharedConditions = make(map[string]ConditionBuilder)
+)
+
+// RegisterConditions adds builders to the shared registry, replacing builders already registered under the
+// same name. Packages providing conditions call it to make them available to SharedConditionRegistry.
+func RegisterConditions(builders map[string]ConditionBuilder) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	for k, b := range builders {
+		sharedConditions[k] = b
+	}
+}
+
+// SharedConditionRegistry returns a ConditionRegistry containing the default Conditions along with every
+// condition added with RegisterConditions, followed by the custom conditions in conds.
+func SharedConditionRegistry(conds ...map[string]ConditionBuilder) ConditionRegistry {
+	sharedMu.RLock()
+	shared := make(map[string]ConditionBuilder, len(sharedConditions))
+
+	for k, b := range sharedConditions {
+		shared[k] = b
+	}
+	sharedMu.RUnlock()
+
+	return NewConditionRegistry(append([]map[string]ConditionBuilder{shared}, conds...)...)
+}
+
 // Condition is the interface allowing different types of conditional expressions.
 type Condition interface {
 	Name() string
 	Meets(interface{}, *Request) bool
 }
 
+// ConditionResult is the outcome of evaluating a condition. Err is set when the condition could not be
+// evaluated, for example because the value has the wrong type, in which case Met is ignored. Reason is a
+// short explanation of the outcome.
+type ConditionResult struct {
+	Met    bool
+	Reason string
+	Err    error
+}
+
+// ConditionEvaluator is implemented by conditions that can report errors and explanations. The enforcer
+// prefers Evaluate over Meets when a condition implements it.
+type ConditionEvaluator interface {
+	Evaluate(val interface{}, r *Request) ConditionResult
+}
+
+// ProvidedValueCondition is implemented by conditions whose value, the attribute named after the condition,
+// may be supplied by the attribute providers of the enforcer when the request metadata does not hold it. Other
+// conditions are only given metadata values, so evaluating them never calls a provider; they can still ask for
+// attributes they need with RequestAttribute.
+type ProvidedValueCondition interface {
+	Condition
+	ProvidedValue()
+}
+
+// ConsumingCondition is implemented by conditions whose evaluation consumes state, such as a rate limit taking
+// a token. The enforcer evaluates them after the other conditions of a policy, and only when those are all met,
+// so that requests denied by another condition are not counted.
+type ConsumingCondition interface {
+	Condition
+	ConsumesOnEvaluation()
+}
+
+// isConsumingCondition reports whether c, or the condition selected by c, is a ConsumingCondition.
+func isConsumingCondition(c Condition) bool {
+	c, _ = unwrapCondition(c)
+	_, ok := c.(ConsumingCondition)
+
+	return ok
+}
+
+// ConditionInitializer is implemented by conditions that need to prepare themselves once their options are
+// decoded, such as conditions that build nested conditions from the registry they were built with.
+type ConditionInitializer interface {
+	Init(ConditionRegistry) error
+}
+
 // Conditions is a map of named Conditions.
 type Conditions map[string]Condition
 
+// Names returns the names of the conditions in sorted order, the order in which the enforcer evaluates them.
+func (c Conditions) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
 // NewConditions accepts an array of options and an optional ConditionRegistry and returns a Conditions map.
 func NewConditions(opts []ConditionOptions, reg ConditionRegistry) (Conditions, error) {
 	if reg == nil {
@@ -51,28 +219,107 @@ func NewConditions(opts []ConditionOptions, reg ConditionRegistry) (Conditions,
 	cond := make(map[string]Condition)
 
 	for _, co := range opts {
-		if cf, ok := reg[co.Type]; ok {
-			nc := cf()
-			if len(co.Options) > 0 {
-				if err := mapstructure.Decode(co.Options, &nc); err != nil {
-					return nil, err
-				}
-			}
-
-			cond[co.Name] = nc
-		} else {
-			return nil, fmt.Errorf("unknown condition type %s, is it registered?", co.Type)
+		nc, err := newCondition(co, reg)
+		if err != nil {
+			return nil, err
 		}
+
+		cond[co.Name] = nc
 	}
 
 	return cond, nil
 }
 
-// ConditionOptions contains the values used to build a Condition.
+func newCondition(co ConditionOptions, reg ConditionRegistry) (Condition, error) {
+	cf, ok := reg[co.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown condition type %s, is it registered?", co.Type)
+	}
+
+	nc := cf()
+	if len(co.Options) > 0 {
+		// options are decoded with the same json tags used to marshal conditions
+		dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			TagName: "json",
+			Result:  &nc,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := dec.Decode(co.Options); err != nil {
+			return nil, err
+		}
+	}
+
+	if ci, ok := nc.(ConditionInitializer); ok {
+		if err := ci.Init(reg); err != nil {
+			return nil, fmt.Errorf("condition %s: %w", co.Name, err)
+		}
+	}
+
+	if co.Selector != "" {
+		sc, err := newSelectedCondition(nc, co.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("condition %s: %w", co.Name, err)
+		}
+
+		return sc, nil
+	}
+
+	return nc, nil
+}
+
+// conditionValue returns the request attribute a named condition is evaluated against: the value addressed
+// by the selector of the condition when it has one, otherwise the attribute called name. Attribute providers
+// are only asked for that attribute when the condition is a ProvidedValueCondition. Missing attributes are nil;
+// only failures of attribute providers are errors.
+func conditionValue(name string, c Condition, r *Request) (interface{}, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	if sc, ok := c.(*selectedCondition); ok {
+		return sc.value(r)
+	}
+
+	if _, ok := c.(ProvidedValueCondition); !ok {
+		v, _ := localAttribute(r, name)
+		return v, nil
+	}
+
+	v, _, err := RequestAttribute(r, name)
+	if err != nil {
+		return nil, &providerError{name: name, err: err}
+	}
+
+	return v, nil
+}
+
+// evaluateCondition evaluates a named condition against r, through Evaluate when the condition implements
+// ConditionEvaluator and through Meets otherwise.
+func evaluateCondition(name string, c Condition, r *Request) ConditionResult {
+	val, err := conditionValue(name, c, r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	if ce, ok := c.(ConditionEvaluator); ok {
+		return ce.Evaluate(val, r)
+	}
+
+	return ConditionResult{Met: c.Meets(val, r)}
+}
+
+// ConditionOptions contains the values used to build a Condition. By default a condition is evaluated against
+// the request metadata value stored under its Name. Selector overrides this with a path into the metadata
+// using dots and indices, such as claims.groups[0] or headers["X-Forwarded-For"]. Paths traverse maps,
+// slices, structs by their json field names and http.Header by canonical header keys.
 type ConditionOptions struct {
-	Name    string                 `json:"name"`
-	Type    string                 `json:"type"`
-	Options map[string]interface{} `json:"options"`
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Selector string                 `json:"selector,omitempty"`
+	Options  map[string]interface{} `json:"options"`
 }
 
 // BoolCondition matches a boolean value from context to the preconfigured value.
@@ -100,6 +347,9 @@ func (c *RoleEqualsCondition) Name() string {
 	return "role_equals"
 }
 
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *RoleEqualsCondition) ProvidedValue() {}
+
 // Meets evaluates true when the role val matches Request#Role.
 func (c *RoleEqualsCondition) Meets(val interface{}, r *Request) bool {
 	switch v := val.(type) {
@@ -0,0 +1,279 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// attributePair holds the two compiled attribute references compared by an attribute condition.
+type attributePair struct {
+	left  exprNode
+	right exprNode
+}
+
+func (p *attributePair) init(left, right string) error {
+	l, err := compileAttributeRef(left)
+	if err != nil {
+		return err
+	}
+
+	r, err := compileAttributeRef(right)
+	if err != nil {
+		return err
+	}
+
+	p.left, p.right = l, r
+
+	return nil
+}
+
+// resolve evaluates both references against r. Either reference being undefined or null is an error, so that
+// two missing attributes never compare equal.
+func (p *attributePair) resolve(r *Request) (interface{}, interface{}, error) {
+	if p.left == nil || p.right == nil {
+		return nil, nil, errors.New("attribute references are not compiled")
+	}
+
+	env := requestEnv{req: r}
+
+	lv, err := p.left.eval(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rv, err := p.right.eval(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case lv == nil:
+		return nil, nil, fmt.Errorf("attribute %s is null", p.left)
+	case rv == nil:
+		return nil, nil, fmt.Errorf("attribute %s is null", p.right)
+	}
+
+	return lv, rv, nil
+}
+
+// result explains the comparison of the pair with op.
+func (p *attributePair) result(met bool, op string) ConditionResult {
+	if met {
+		return ConditionResult{Met: true, Reason: fmt.Sprintf("%s %s %s", p.left, op, p.right)}
+	}
+
+	return ConditionResult{Reason: fmt.Sprintf("not %s %s %s", p.left, op, p.right)}
+}
+
+func validateAttributePair(v *Validation, left, right string) {
+	if _, err := compileAttributeRef(left); err != nil {
+		v.Errorf("left", "%v", err)
+	}
+
+	if _, err := compileAttributeRef(right); err != nil {
+		v.Errorf("right", "%v", err)
+	}
+}
+
+// attributeEqual reports whether two attribute values are equal. Numbers compare by value across types;
+// values of different kinds are never equal, and neither is a nil value equal to anything.
+func attributeEqual(l, r interface{}) bool {
+	if l == nil || r == nil {
+		return false
+	}
+
+	eq, err := exprEqual(l, r)
+
+	return err == nil && eq
+}
+
+// attributeMember reports whether v equals any element of the list coll. A coll that is not a list is
+// treated as a list of one element.
+func attributeMember(v, coll interface{}) bool {
+	list, ok := toList(coll)
+	if !ok {
+		return attributeEqual(v, coll)
+	}
+
+	for _, item := range list {
+		if attributeEqual(v, item) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attributeSubset reports whether every element of the list l is a member of coll. An l that is not a list
+// is treated as a list of one element.
+func attributeSubset(l, coll interface{}) bool {
+	list, ok := toList(l)
+	if !ok {
+		return attributeMember(l, coll)
+	}
+
+	for _, item := range list {
+		if !attributeMember(item, coll) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AttrEqualsCondition matches when the attributes referenced by Left and Right are equal, for example
+// meta.owner_id and request.role to check that a subject owns the resource it acts upon.
+//
+// References follow the path syntax of ExprCondition: the "request" root exposes the request fields, "meta"
+// the request metadata and any other root a metadata key, so subject.attrs.tenant reads the tenant from the
+// "subject" metadata value.
+type AttrEqualsCondition struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	pair  attributePair
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AttrEqualsCondition) Name() string {
+	return "attr_equals"
+}
+
+// Init fulfills ConditionInitializer by compiling the references.
+func (c *AttrEqualsCondition) Init(_ ConditionRegistry) error {
+	return c.pair.init(c.Left, c.Right)
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AttrEqualsCondition) Validate(v *Validation) {
+	validateAttributePair(v, c.Left, c.Right)
+}
+
+// Meets evaluates true when both attributes are defined and equal. The val parameter is not used.
+func (c *AttrEqualsCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. An undefined or null attribute is an error.
+func (c *AttrEqualsCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	l, rv, err := c.pair.resolve(r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return c.pair.result(attributeEqual(l, rv), "==")
+}
+
+// AttrNotEqualsCondition matches when the attributes referenced by Left and Right are both defined and
+// differ.
+type AttrNotEqualsCondition struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	pair  attributePair
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AttrNotEqualsCondition) Name() string {
+	return "attr_not_equals"
+}
+
+// Init fulfills ConditionInitializer by compiling the references.
+func (c *AttrNotEqualsCondition) Init(_ ConditionRegistry) error {
+	return c.pair.init(c.Left, c.Right)
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AttrNotEqualsCondition) Validate(v *Validation) {
+	validateAttributePair(v, c.Left, c.Right)
+}
+
+// Meets evaluates true when both attributes are defined and differ. The val parameter is not used.
+func (c *AttrNotEqualsCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. An undefined or null attribute is an error.
+func (c *AttrNotEqualsCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	l, rv, err := c.pair.resolve(r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return c.pair.result(!attributeEqual(l, rv), "!=")
+}
+
+// AttrInCondition matches when the attribute referenced by Left is an element of the list referenced by
+// Right, for example request.role in meta.document.editors.
+type AttrInCondition struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	pair  attributePair
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AttrInCondition) Name() string {
+	return "attr_in"
+}
+
+// Init fulfills ConditionInitializer by compiling the references.
+func (c *AttrInCondition) Init(_ ConditionRegistry) error {
+	return c.pair.init(c.Left, c.Right)
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AttrInCondition) Validate(v *Validation) {
+	validateAttributePair(v, c.Left, c.Right)
+}
+
+// Meets evaluates true when Left is a member of Right. The val parameter is not used.
+func (c *AttrInCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. An undefined or null attribute is an error.
+func (c *AttrInCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	l, rv, err := c.pair.resolve(r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return c.pair.result(attributeMember(l, rv), "in")
+}
+
+// AttrSubsetCondition matches when every element of the list referenced by Left is an element of the list
+// referenced by Right, for example the scopes a token requests against the scopes granted to its client.
+// A Left that is not a list is treated as a list of one element, and an empty Left is always a subset.
+type AttrSubsetCondition struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	pair  attributePair
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AttrSubsetCondition) Name() string {
+	return "attr_subset"
+}
+
+// Init fulfills ConditionInitializer by compiling the references.
+func (c *AttrSubsetCondition) Init(_ ConditionRegistry) error {
+	return c.pair.init(c.Left, c.Right)
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AttrSubsetCondition) Validate(v *Validation) {
+	validateAttributePair(v, c.Left, c.Right)
+}
+
+// Meets evaluates true when Left is a subset of Right. The val parameter is not used.
+func (c *AttrSubsetCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. An undefined or null attribute is an error.
+func (c *AttrSubsetCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	l, rv, err := c.pair.resolve(r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return c.pair.result(attributeSubset(l, rv), "subset of")
+}
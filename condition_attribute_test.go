@@ -0,0 +1,115 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttributeConditions(t *testing.T) {
+	meta := map[string]interface{}{
+		"owner_id": "alice",
+		"tenant":   "acme",
+		"count":    3,
+		"subject": map[string]interface{}{
+			"attrs": map[string]interface{}{
+				"tenant": "acme",
+				"scopes": []string{"read", "write"},
+				"count":  3.0,
+			},
+		},
+		"editors":   []interface{}{"bob", "alice"},
+		"requested": []string{"read"},
+		"headers":   map[string][]string{"X-Team": {"payments"}},
+		"nobody":    nil,
+		"unowned":   nil,
+	}
+
+	tests := []struct {
+		name    string
+		typ     string
+		left    string
+		right   string
+		want    bool
+		wantErr bool
+	}{
+		{"owner", "attr_equals", "meta.owner_id", "request.role", true, false},
+		{"owner root key", "attr_equals", "owner_id", "request.subject", true, false},
+		{"tenant", "attr_equals", "meta.tenant", "subject.attrs.tenant", true, false},
+		{"numbers across types", "attr_equals", "count", "subject.attrs.count", true, false},
+		{"kinds differ", "attr_equals", "count", "tenant", false, false},
+		{"undefined", "attr_equals", "meta.missing", "meta.missing", false, false},
+		{"both null", "attr_equals", "meta.nobody", "meta.unowned", false, false},
+		{"not equals null", "attr_not_equals", "meta.owner_id", "meta.nobody", false, false},
+		{"in null", "attr_in", "meta.nobody", "meta.editors", false, false},
+		{"quoted index", "attr_in", `meta["owner_id"]`, "meta.editors", true, false},
+		{"literal operand", "attr_equals", `headers["X-Team"][0]`, `"payments"`, false, true},
+		{"not equals", "attr_not_equals", "meta.owner_id", "request.resource", true, false},
+		{"not equals same", "attr_not_equals", "meta.tenant", "subject.attrs.tenant", false, false},
+		{"not equals undefined", "attr_not_equals", "meta.owner_id", "meta.missing", false, false},
+		{"in", "attr_in", "request.role", "meta.editors", true, false},
+		{"in indexed", "attr_in", "subject.attrs.scopes[1]", "subject.attrs.scopes", true, false},
+		{"in missing", "attr_in", "request.resource", "meta.editors", false, false},
+		{"in scalar", "attr_in", "meta.tenant", "subject.attrs.tenant", true, false},
+		{"subset", "attr_subset", "meta.requested", "subject.attrs.scopes", true, false},
+		{"subset not", "attr_subset", "subject.attrs.scopes", "meta.requested", false, false},
+		{"subset scalar", "attr_subset", "meta.tenant", "meta.tenant", true, false},
+		{"expression operand", "attr_subset", "headers.X-Team", "subject.attrs.scopes", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ConditionOptions{{Name: "cond", Type: tt.typ, Options: map[string]interface{}{
+				"left":  tt.left,
+				"right": tt.right,
+			}}}
+
+			conds, err := NewConditions(opts, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			r := NewRequest("docs/1", "edit", "alice", "", meta)
+
+			if got := conds["cond"].Meets(nil, r); got != tt.want {
+				t.Errorf("Condition.Meets() = %v, want %v", got, tt.want)
+			}
+
+			if res := conds["cond"].(ConditionEvaluator).Evaluate(nil, r); !tt.want && res.Met {
+				t.Errorf("Condition.Evaluate() = %+v, want not met", res)
+			}
+		})
+	}
+}
+
+func TestAttributeConditionValidation(t *testing.T) {
+	v := ValidatePolicyOptions(NewPolicyOptions(
+		PolicyName("p"),
+		SetActions("edit"),
+		WithRoleRefs("editor"),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "owner", Type: "attr_equals", Options: map[string]interface{}{
+			"left":  "meta.owner_id",
+			"right": "request.role",
+		}}),
+		WithCondition(ConditionOptions{Name: "tenant", Type: "attr_equals", Options: map[string]interface{}{
+			"left":  "meta.tenant == 'x'",
+			"right": "request.role",
+		}}),
+	), nil)
+
+	fields := problemFields(v.Errors())
+	if !reflect.DeepEqual(fields, []string{"conditions[1]"}) {
+		t.Errorf("ValidatePolicyOptions() errors = %v", v.Errors())
+	}
+
+	c := &AttrInCondition{Left: "meta[lower(x)]"}
+	cv := NewValidation()
+	c.Validate(cv)
+
+	if fields := problemFields(cv.Errors()); !reflect.DeepEqual(fields, []string{"left", "right"}) {
+		t.Errorf("Validate() errors = %v", cv.Errors())
+	}
+}
@@ -0,0 +1,270 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// collectionValues converts a []string, a []interface{} of strings or a string of elements separated by sep,
+// a comma when sep is empty, into a slice of strings. Elements of a string are trimmed and empty ones dropped,
+// so both "read,write" and "read write" with a space separator give two elements.
+func collectionValues(val interface{}, sep string) ([]string, bool) {
+	s, ok := val.(string)
+	if !ok {
+		return stringValues(val)
+	}
+
+	if sep == "" {
+		sep = ","
+	}
+
+	var parts []string
+	if strings.TrimSpace(sep) == "" {
+		parts = strings.Fields(s)
+	} else {
+		parts = strings.Split(s, sep)
+	}
+
+	out := make([]string, 0, len(parts))
+
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+
+	return out, true
+}
+
+// collectionSet returns the set of values, lower cased when ignoreCase is set.
+func collectionSet(values []string, ignoreCase bool) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[foldCase(v, ignoreCase)] = true
+	}
+
+	return set
+}
+
+// evalCollection reads the collection in val and passes it, case folded as configured, to eval. A missing
+// value is not met and a value that is not a collection is an error.
+func evalCollection(val interface{}, sep string, ignoreCase bool, eval func([]string) ConditionResult) ConditionResult {
+	if val == nil {
+		return ConditionResult{Reason: "no value"}
+	}
+
+	vals, ok := collectionValues(val, sep)
+	if !ok {
+		return ConditionResult{Err: fmt.Errorf("%v (%T) is not a collection of strings", val, val)}
+	}
+
+	folded := make([]string, len(vals))
+	for i, v := range vals {
+		folded[i] = foldCase(v, ignoreCase)
+	}
+
+	return eval(folded)
+}
+
+// validateCollection checks the values of a collection condition. Without values contains_any and
+// intersects_with never match while contains_all always does, so they are errors. subset_of without values
+// still matches empty collections and only warns.
+func validateCollection(v *Validation, values []string, required bool) {
+	switch {
+	case len(values) > 0:
+	case required:
+		v.Errorf("values", "at least one value is required")
+	default:
+		v.Warnf("values", "no values given, only empty collections match")
+	}
+}
+
+// ContainsAnyCondition matches collections holding at least one of Values, such as a group claim holding
+// one of the groups allowed to act.
+//
+// Collections may be a []string, a []interface{} of strings or a string of elements separated by Separator,
+// a comma by default. Use a space separator for OAuth scope strings.
+type ContainsAnyCondition struct {
+	Values     []string `json:"values"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Separator  string   `json:"separator,omitempty"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *ContainsAnyCondition) Name() string {
+	return "contains_any"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *ContainsAnyCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *ContainsAnyCondition) Validate(v *Validation) {
+	validateCollection(v, c.Values, true)
+}
+
+// Meets evaluates true when the collection val holds one of Values.
+func (c *ContainsAnyCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a collection is an error.
+func (c *ContainsAnyCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	want := collectionSet(c.Values, c.IgnoreCase)
+
+	return evalCollection(val, c.Separator, c.IgnoreCase, func(vals []string) ConditionResult {
+		for _, v := range vals {
+			if want[v] {
+				return ConditionResult{Met: true, Reason: fmt.Sprintf("contains %s", v)}
+			}
+		}
+
+		return ConditionResult{Reason: fmt.Sprintf("contains none of %s", strings.Join(c.Values, ", "))}
+	})
+}
+
+// ContainsAllCondition matches collections holding every one of Values, such as a token granted all the
+// scopes an action requires. Collections are read as by ContainsAnyCondition.
+type ContainsAllCondition struct {
+	Values     []string `json:"values"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Separator  string   `json:"separator,omitempty"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *ContainsAllCondition) Name() string {
+	return "contains_all"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *ContainsAllCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *ContainsAllCondition) Validate(v *Validation) {
+	validateCollection(v, c.Values, true)
+}
+
+// Meets evaluates true when the collection val holds all of Values.
+func (c *ContainsAllCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a collection is an error.
+func (c *ContainsAllCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	return evalCollection(val, c.Separator, c.IgnoreCase, func(vals []string) ConditionResult {
+		have := collectionSet(vals, false)
+
+		for _, w := range c.Values {
+			if !have[foldCase(w, c.IgnoreCase)] {
+				return ConditionResult{Reason: fmt.Sprintf("does not contain %s", w)}
+			}
+		}
+
+		return ConditionResult{Met: true, Reason: fmt.Sprintf("contains all of %s", strings.Join(c.Values, ", "))}
+	})
+}
+
+// SubsetOfCondition matches collections whose every element is one of Values, such as the scopes requested by
+// a client that must all be granted. An empty collection is a subset. Collections are read as by
+// ContainsAnyCondition.
+type SubsetOfCondition struct {
+	Values     []string `json:"values"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Separator  string   `json:"separator,omitempty"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *SubsetOfCondition) Name() string {
+	return "subset_of"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *SubsetOfCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *SubsetOfCondition) Validate(v *Validation) {
+	validateCollection(v, c.Values, false)
+}
+
+// Meets evaluates true when every element of the collection val is one of Values.
+func (c *SubsetOfCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a collection is an error.
+func (c *SubsetOfCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	allowed := collectionSet(c.Values, c.IgnoreCase)
+
+	return evalCollection(val, c.Separator, c.IgnoreCase, func(vals []string) ConditionResult {
+		for _, v := range vals {
+			if !allowed[v] {
+				return ConditionResult{Reason: fmt.Sprintf("%s is not one of %s", v, strings.Join(c.Values, ", "))}
+			}
+		}
+
+		return ConditionResult{Met: true, Reason: fmt.Sprintf("all elements are in %s", strings.Join(c.Values, ", "))}
+	})
+}
+
+// IntersectsWithCondition matches collections sharing at least Min elements with Values, one by default.
+// Collections are read as by ContainsAnyCondition.
+type IntersectsWithCondition struct {
+	Values     []string `json:"values"`
+	Min        int      `json:"min,omitempty"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Separator  string   `json:"separator,omitempty"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *IntersectsWithCondition) Name() string {
+	return "intersects_with"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *IntersectsWithCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *IntersectsWithCondition) Validate(v *Validation) {
+	validateCollection(v, c.Values, true)
+
+	if c.Min < 0 {
+		v.Errorf("min", "must not be negative")
+	}
+
+	if c.Min > len(collectionSet(c.Values, c.IgnoreCase)) {
+		v.Warnf("min", "exceeds the number of values, the condition never matches")
+	}
+}
+
+// Meets evaluates true when the collection val shares enough elements with Values.
+func (c *IntersectsWithCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a collection is an error.
+func (c *IntersectsWithCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	min := c.Min
+	if min <= 0 {
+		min = 1
+	}
+
+	want := collectionSet(c.Values, c.IgnoreCase)
+
+	return evalCollection(val, c.Separator, c.IgnoreCase, func(vals []string) ConditionResult {
+		shared := make([]string, 0, min)
+		seen := make(map[string]bool, len(vals))
+
+		for _, v := range vals {
+			if want[v] && !seen[v] {
+				seen[v] = true
+				shared = append(shared, v)
+			}
+		}
+
+		if len(shared) < min {
+			return ConditionResult{Reason: fmt.Sprintf("shares %d of the %d required elements", len(shared), min)}
+		}
+
+		return ConditionResult{Met: true, Reason: fmt.Sprintf("shares %s", strings.Join(shared, ", "))}
+	})
+}
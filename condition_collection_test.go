@@ -0,0 +1,112 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectionConditions(t *testing.T) {
+	scopes := map[string]interface{}{"values": []string{"read", "write"}}
+
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		val     interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"any", "contains_any", scopes, []string{"admin", "write"}, true, false},
+		{"any none", "contains_any", scopes, []string{"admin"}, false, false},
+		{"any interface list", "contains_any", scopes, []interface{}{"read"}, true, false},
+		{"any comma string", "contains_any", scopes, "admin, read", true, false},
+		{"any space string", "contains_any", map[string]interface{}{"values": []string{"read"}, "separator": " "}, "openid  read profile", true, false},
+		{"any ignore case", "contains_any", map[string]interface{}{"values": []string{"Ops"}, "ignore_case": true}, []string{"ops"}, true, false},
+		{"any empty", "contains_any", scopes, []string{}, false, false},
+		{"any missing", "contains_any", scopes, nil, false, false},
+		{"any wrong type", "contains_any", scopes, []interface{}{"read", 1}, false, true},
+		{"all", "contains_all", scopes, "write,read,admin", true, false},
+		{"all partial", "contains_all", scopes, []string{"read"}, false, false},
+		{"all ignore case", "contains_all", map[string]interface{}{"values": []string{"READ"}, "ignore_case": true}, "read", true, false},
+		{"subset", "subset_of", scopes, []string{"read"}, true, false},
+		{"subset extra", "subset_of", scopes, []string{"read", "delete"}, false, false},
+		{"subset empty", "subset_of", scopes, "", true, false},
+		{"subset number", "subset_of", scopes, 3, false, true},
+		{"intersects", "intersects_with", scopes, []string{"write"}, true, false},
+		{"intersects min", "intersects_with", map[string]interface{}{"values": []string{"a", "b", "c"}, "min": 2}, []string{"a", "a", "x"}, false, false},
+		{"intersects min met", "intersects_with", map[string]interface{}{"values": []string{"a", "b", "c"}, "min": 2}, "c,x,a", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, err := NewConditions([]ConditionOptions{{Name: "cond", Type: tt.typ, Options: tt.options}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := evaluateCondition("cond", conds["cond"], NewRequest("", "", "", "", map[string]interface{}{"cond": tt.val}))
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.Met != tt.want {
+				t.Errorf("Evaluate() met = %v, want %v (%s)", res.Met, tt.want, res.Reason)
+			}
+
+			if got := conds["cond"].Meets(tt.val, nil); got != (tt.want && !tt.wantErr) {
+				t.Errorf("Meets() = %v", got)
+			}
+		})
+	}
+}
+
+func TestCollectionValues(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		sep  string
+		want []string
+	}{
+		{"a,b, c,,", "", []string{"a", "b", "c"}},
+		{"a b\tc", " ", []string{"a", "b", "c"}},
+		{"a|b", "|", []string{"a", "b"}},
+		{[]string{"a b"}, " ", []string{"a b"}},
+		{[]interface{}{"a"}, "", []string{"a"}},
+	}
+	for _, tt := range tests {
+		got, ok := collectionValues(tt.val, tt.sep)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("collectionValues(%q, %q) = %q, %v, want %q", tt.val, tt.sep, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCollectionConditionValidation(t *testing.T) {
+	tests := []struct {
+		typ          string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{"contains_any", []string{"values"}, nil},
+		{"contains_all", []string{"values"}, nil},
+		{"intersects_with", []string{"values"}, nil},
+		{"subset_of", nil, []string{"values"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			conds, err := NewConditions([]ConditionOptions{{Name: "cond", Type: tt.typ}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := NewValidation()
+			conds["cond"].(ConditionValidator).Validate(v)
+
+			if got := problemFields(v.Errors()); !reflect.DeepEqual(got, tt.wantErrors) {
+				t.Errorf("Validate() errors = %v, want %v", v.Errors(), tt.wantErrors)
+			}
+
+			if got := problemFields(v.Warnings()); !reflect.DeepEqual(got, tt.wantWarnings) {
+				t.Errorf("Validate() warnings = %v, want %v", v.Warnings(), tt.wantWarnings)
+			}
+		})
+	}
+}
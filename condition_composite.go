@@ -0,0 +1,224 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// namedCondition is a nested condition along with the metadata key it is evaluated against.
+type namedCondition struct {
+	name string
+	cond Condition
+}
+
+func (nc namedCondition) evaluate(r *Request) ConditionResult {
+	return evaluateCondition(nc.name, nc.cond, r)
+}
+
+// resultMeets reports whether res was met without error.
+func resultMeets(res ConditionResult) bool {
+	return res.Err == nil && res.Met
+}
+
+func buildConditions(opts []ConditionOptions, reg ConditionRegistry) ([]namedCondition, error) {
+	conds := make([]namedCondition, 0, len(opts))
+
+	for i, co := range opts {
+		c, err := newCondition(co, reg)
+		if err != nil {
+			return nil, fmt.Errorf("conditions[%d]: %w", i, err)
+		}
+
+		conds = append(conds, namedCondition{name: co.Name, cond: c})
+	}
+
+	return conds, nil
+}
+
+func validateConditions(v *Validation, field string, conds []namedCondition) {
+	for i, nc := range conds {
+		if cv, ok := nc.cond.(ConditionValidator); ok {
+			cv.Validate(v.Field(fmt.Sprintf("%s[%d].options", field, i)))
+		}
+	}
+}
+
+// AllCondition meets when every nested condition meets. Each nested condition is evaluated against the
+// request metadata value named by its own Name, so several conditions can share the same key.
+type AllCondition struct {
+	Conditions []ConditionOptions `json:"conditions"`
+	conds      []namedCondition
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AllCondition) Name() string {
+	return "all"
+}
+
+// Init fulfills ConditionInitializer by building the nested conditions from reg.
+func (c *AllCondition) Init(reg ConditionRegistry) error {
+	conds, err := buildConditions(c.Conditions, reg)
+	c.conds = conds
+
+	return err
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AllCondition) Validate(v *Validation) {
+	if len(c.Conditions) == 0 {
+		v.Warnf("conditions", "no conditions given, the condition always matches")
+	}
+
+	validateConditions(v, "conditions", c.conds)
+}
+
+// Meets evaluates true when all nested conditions meet. The val parameter is not used.
+func (c *AllCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A nested condition that is not met decides the result, otherwise the
+// first nested error is reported.
+func (c *AllCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	var err error
+
+	for _, nc := range c.conds {
+		res := nc.evaluate(r)
+
+		switch {
+		case res.Err != nil:
+			if err == nil {
+				err = fmt.Errorf("%s: %w", nc.name, res.Err)
+			}
+		case !res.Met:
+			return ConditionResult{Reason: nc.name + " not met"}
+		}
+	}
+
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return ConditionResult{Met: true, Reason: "all conditions met"}
+}
+
+// AnyCondition meets when at least one nested condition meets. Each nested condition is evaluated against
+// the request metadata value named by its own Name.
+type AnyCondition struct {
+	Conditions []ConditionOptions `json:"conditions"`
+	conds      []namedCondition
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AnyCondition) Name() string {
+	return "any"
+}
+
+// Init fulfills ConditionInitializer by building the nested conditions from reg.
+func (c *AnyCondition) Init(reg ConditionRegistry) error {
+	conds, err := buildConditions(c.Conditions, reg)
+	c.conds = conds
+
+	return err
+}
+
+// Validate fulfills ConditionValidator.
+func (c *AnyCondition) Validate(v *Validation) {
+	if len(c.Conditions) == 0 {
+		v.Warnf("conditions", "no conditions given, the condition never matches")
+	}
+
+	validateConditions(v, "conditions", c.conds)
+}
+
+// Meets evaluates true when any nested condition meets. The val parameter is not used.
+func (c *AnyCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A nested condition that is met decides the result, otherwise the
+// first nested error is reported.
+func (c *AnyCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	var err error
+
+	for _, nc := range c.conds {
+		res := nc.evaluate(r)
+
+		switch {
+		case res.Err != nil:
+			if err == nil {
+				err = fmt.Errorf("%s: %w", nc.name, res.Err)
+			}
+		case res.Met:
+			return ConditionResult{Met: true, Reason: nc.name + " met"}
+		}
+	}
+
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return ConditionResult{Reason: "no condition met"}
+}
+
+// NotCondition negates a nested condition, which is evaluated against the request metadata value named by
+// its own Name.
+type NotCondition struct {
+	Condition ConditionOptions `json:"condition"`
+	cond      *namedCondition
+}
+
+// Name fulfills the Name method of Condition.
+func (c *NotCondition) Name() string {
+	return "not"
+}
+
+// Init fulfills ConditionInitializer by building the nested condition from reg.
+func (c *NotCondition) Init(reg ConditionRegistry) error {
+	if c.Condition.Type == "" {
+		return errors.New("not requires a nested condition")
+	}
+
+	nc, err := newCondition(c.Condition, reg)
+	if err != nil {
+		return err
+	}
+
+	c.cond = &namedCondition{name: c.Condition.Name, cond: nc}
+
+	return nil
+}
+
+// Validate fulfills ConditionValidator.
+func (c *NotCondition) Validate(v *Validation) {
+	if c.cond == nil {
+		return
+	}
+
+	if cv, ok := c.cond.cond.(ConditionValidator); ok {
+		cv.Validate(v.Field("condition.options"))
+	}
+}
+
+// Meets evaluates true when the nested condition does not meet. The val parameter is not used.
+func (c *NotCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. An error of the nested condition is reported rather than negated.
+func (c *NotCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	if c.cond == nil {
+		return ConditionResult{Err: errors.New("nested condition is not built")}
+	}
+
+	res := c.cond.evaluate(r)
+	if res.Err != nil {
+		return ConditionResult{Err: fmt.Errorf("%s: %w", c.cond.name, res.Err)}
+	}
+
+	if res.Met {
+		return ConditionResult{Reason: c.cond.name + " met"}
+	}
+
+	return ConditionResult{Met: true, Reason: c.cond.name + " not met"}
+}
@@ -0,0 +1,79 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExprCondition evaluates a boolean expression over the request, for example
+// `meta.amount < 1000 && subject.department == resource.owner_dept`.
+//
+// Root identifiers resolve to request metadata keys, with two reserved roots: "meta" is the metadata itself
+// and "request" exposes the resource, action, role (or subject) and scope of the request. Members are
+// accessed with dots and indices with brackets. The language supports arithmetic (+ - * / %), string
+// concatenation with +, comparisons (== != < <= > >=), boolean operators (&& || ! and their keywords
+// and, or, not), list, map and substring membership (in, not in, contains), the string operators
+// startsWith, endsWith and matches (regular expressions), list literals and the len, lower and upper
+// builtins.
+//
+// The expression is compiled once when the condition is built. Evaluation fails closed: a type error, an
+// undefined attribute or a non boolean result does not meet the condition.
+type ExprCondition struct {
+	Expression string `json:"expression"`
+	expr       exprNode
+}
+
+// Name fulfills the Name method of Condition.
+func (c *ExprCondition) Name() string {
+	return "expr"
+}
+
+// Init fulfills ConditionInitializer by compiling the expression.
+func (c *ExprCondition) Init(_ ConditionRegistry) error {
+	if c.Expression == "" {
+		return errors.New("expression is required")
+	}
+
+	expr, err := compileExpr(c.Expression)
+	if err != nil {
+		return err
+	}
+
+	c.expr = expr
+
+	return nil
+}
+
+// Meets evaluates true when the expression evaluates to true for r. The val parameter is not used.
+func (c *ExprCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. Type errors, undefined attributes and non boolean results are
+// reported as errors.
+func (c *ExprCondition) Evaluate(_ interface{}, r *Request) ConditionResult {
+	met, err := c.eval(r)
+	if err != nil {
+		return ConditionResult{Err: err}
+	}
+
+	return ConditionResult{Met: met, Reason: fmt.Sprintf("%s is %t", c.expr, met)}
+}
+
+func (c *ExprCondition) eval(r *Request) (bool, error) {
+	if c.expr == nil {
+		return false, errors.New("expression is not compiled")
+	}
+
+	v, err := c.expr.eval(requestEnv{req: r})
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New("expression did not evaluate to a boolean")
+	}
+
+	return b, nil
+}
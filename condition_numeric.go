@@ -0,0 +1,393 @@
+package redtape
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type numberKind int
+
+const (
+	numberInt numberKind = iota
+	numberUint
+	numberFloat
+)
+
+// number holds a numeric value without losing precision. Integers are kept as int64, or as uint64 when
+// they exceed the int64 range, so that large integers compare exactly instead of being rounded to float64.
+type number struct {
+	kind numberKind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// toNumber converts any Go numeric kind, json.Number or numeric string into a number. Booleans, empty
+// strings and NaN are not numbers.
+func toNumber(v interface{}) (number, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return parseNumber(string(n))
+	case string:
+		return parseNumber(n)
+	}
+
+	rv := indirectValue(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return number{}, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: numberInt, i: rv.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintNumber(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) {
+			return number{}, false
+		}
+
+		return number{kind: numberFloat, f: f}, true
+	case reflect.String:
+		return parseNumber(rv.String())
+	}
+
+	return number{}, false
+}
+
+func uintNumber(u uint64) number {
+	if u <= math.MaxInt64 {
+		return number{kind: numberInt, i: int64(u)}
+	}
+
+	return number{kind: numberUint, u: u}
+}
+
+func parseNumber(s string) (number, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return number{}, false
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return number{kind: numberInt, i: i}, true
+	}
+
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return uintNumber(u), true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return number{}, false
+	}
+
+	return number{kind: numberFloat, f: f}, true
+}
+
+// String returns the decimal representation of n.
+func (n number) String() string {
+	switch n.kind {
+	case numberUint:
+		return strconv.FormatUint(n.u, 10)
+	case numberFloat:
+		return strconv.FormatFloat(n.f, 'g', -1, 64)
+	}
+
+	return strconv.FormatInt(n.i, 10)
+}
+
+// compare returns -1, 0 or 1 when n is less than, equal to or greater than o. Integers and floats are
+// compared by value, so 3 equals 3.0 and math.MaxInt64 is less than float64(math.MaxInt64).
+func (n number) compare(o number) int {
+	switch {
+	case n.kind == numberFloat && o.kind == numberFloat:
+		return compareFloats(n.f, o.f)
+	case n.kind == numberFloat:
+		return -o.compare(n)
+	case o.kind == numberFloat:
+		return n.compareFloat(o.f)
+	case n.kind == numberUint && o.kind == numberUint:
+		return compareUints(n.u, o.u)
+	case n.kind == numberUint:
+		// o fits int64 while n does not
+		return 1
+	case o.kind == numberUint:
+		return -1
+	}
+
+	switch {
+	case n.i < o.i:
+		return -1
+	case n.i > o.i:
+		return 1
+	}
+
+	return 0
+}
+
+// compareFloat compares an integer n to f exactly by comparing integer parts first and the fraction of f
+// second.
+func (n number) compareFloat(f float64) int {
+	const twoPow63, twoPow64 = 1 << 63, 1 << 64
+
+	if math.IsInf(f, 0) {
+		return -int(math.Copysign(1, f))
+	}
+
+	t, frac := math.Modf(f)
+
+	var c int
+
+	switch {
+	case n.kind == numberUint && t >= twoPow64, n.kind == numberInt && t >= twoPow63:
+		return -1
+	case n.kind == numberInt && t < -twoPow63:
+		return 1
+	case n.kind == numberUint && t < 0:
+		return 1
+	case n.kind == numberUint:
+		c = compareUints(n.u, uint64(t))
+	default:
+		c = n.compare(number{kind: numberInt, i: int64(t)})
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	switch {
+	case frac > 0:
+		return -1
+	case frac < 0:
+		return 1
+	}
+
+	return 0
+}
+
+func compareUints(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// initNumber parses a numeric condition option.
+func initNumber(field string, v interface{}) (number, error) {
+	if v == nil {
+		return number{}, fmt.Errorf("%s is required", field)
+	}
+
+	n, ok := toNumber(v)
+	if !ok {
+		return number{}, fmt.Errorf("%s %v is not a number", field, v)
+	}
+
+	return n, nil
+}
+
+func validateNumber(v *Validation, field string, val interface{}) {
+	if _, err := initNumber(field, val); err != nil {
+		v.Errorf(field, "%v", err)
+	}
+}
+
+// metadataNumber converts the metadata value val into a number. A missing value is not met while a value
+// that is not a number is an error.
+func metadataNumber(val interface{}) (number, ConditionResult, bool) {
+	if val == nil {
+		return number{}, ConditionResult{Reason: "no value"}, false
+	}
+
+	vn, ok := toNumber(val)
+	if !ok {
+		return number{}, ConditionResult{Err: fmt.Errorf("%v (%T) is not a number", val, val)}, false
+	}
+
+	return vn, ConditionResult{}, true
+}
+
+// numericOperator is the comparison a NumericCondition applies to a metadata value and its Value.
+type numericOperator int
+
+const (
+	numericEq numericOperator = iota
+	numericNe
+	numericLt
+	numericLte
+	numericGt
+	numericGte
+)
+
+var numericOperators = [...]struct {
+	name   string
+	symbol string
+}{
+	numericEq:  {"numeric_eq", "=="},
+	numericNe:  {"numeric_ne", "!="},
+	numericLt:  {"numeric_lt", "<"},
+	numericLte: {"numeric_lte", "<="},
+	numericGt:  {"numeric_gt", ">"},
+	numericGte: {"numeric_gte", ">="},
+}
+
+// holds interprets the result of number.compare for the operator.
+func (op numericOperator) holds(c int) bool {
+	switch op {
+	case numericEq:
+		return c == 0
+	case numericNe:
+		return c != 0
+	case numericLt:
+		return c < 0
+	case numericLte:
+		return c <= 0
+	case numericGt:
+		return c > 0
+	case numericGte:
+		return c >= 0
+	}
+
+	return false
+}
+
+// numericConditionBuilder returns a ConditionBuilder for the NumericCondition applying op.
+func numericConditionBuilder(op numericOperator) ConditionBuilder {
+	return func() Condition {
+		return &NumericCondition{op: op}
+	}
+}
+
+// NumericCondition compares numeric metadata values to Value. It is registered once per comparison as
+// numeric_eq, numeric_ne, numeric_lt, numeric_lte, numeric_gt and numeric_gte.
+type NumericCondition struct {
+	Value interface{} `json:"value"`
+	op    numericOperator
+	n     number
+}
+
+// Name fulfills the Name method of Condition.
+func (c *NumericCondition) Name() string {
+	return numericOperators[c.op].name
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *NumericCondition) ProvidedValue() {}
+
+// Init fulfills ConditionInitializer by parsing Value.
+func (c *NumericCondition) Init(_ ConditionRegistry) (err error) {
+	c.n, err = initNumber("value", c.Value)
+	return err
+}
+
+// Validate fulfills ConditionValidator.
+func (c *NumericCondition) Validate(v *Validation) {
+	validateNumber(v, "value", c.Value)
+}
+
+// Meets evaluates true when val compares to Value by the operator of the condition.
+func (c *NumericCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a number is an error.
+func (c *NumericCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	vn, res, ok := metadataNumber(val)
+	if !ok {
+		return res
+	}
+
+	op := numericOperators[c.op].symbol
+
+	if !c.op.holds(vn.compare(c.n)) {
+		return ConditionResult{Reason: fmt.Sprintf("not %s %s %s", vn, op, c.n)}
+	}
+
+	return ConditionResult{Met: true, Reason: fmt.Sprintf("%s %s %s", vn, op, c.n)}
+}
+
+// NumericBetweenCondition matches numeric metadata values within the inclusive range Min to Max.
+type NumericBetweenCondition struct {
+	Min interface{} `json:"min"`
+	Max interface{} `json:"max"`
+	min number
+	max number
+}
+
+// Name fulfills the Name method of Condition.
+func (c *NumericBetweenCondition) Name() string {
+	return "numeric_between"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *NumericBetweenCondition) ProvidedValue() {}
+
+// Init fulfills ConditionInitializer by parsing Min and Max.
+func (c *NumericBetweenCondition) Init(_ ConditionRegistry) (err error) {
+	if c.min, err = initNumber("min", c.Min); err != nil {
+		return err
+	}
+
+	if c.max, err = initNumber("max", c.Max); err != nil {
+		return err
+	}
+
+	if c.min.compare(c.max) > 0 {
+		return fmt.Errorf("min %s is greater than max %s", c.min, c.max)
+	}
+
+	return nil
+}
+
+// Validate fulfills ConditionValidator.
+func (c *NumericBetweenCondition) Validate(v *Validation) {
+	validateNumber(v, "min", c.Min)
+	validateNumber(v, "max", c.Max)
+
+	min, minOK := toNumber(c.Min)
+	max, maxOK := toNumber(c.Max)
+
+	if minOK && maxOK && min.compare(max) > 0 {
+		v.Errorf("min", "%s is greater than max %s", min, max)
+	}
+}
+
+// Meets evaluates true when val lies between Min and Max, both inclusive.
+func (c *NumericBetweenCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a number is an error.
+func (c *NumericBetweenCondition) Evaluate(val interface{}, _ *Request) ConditionResult {
+	vn, res, ok := metadataNumber(val)
+	if !ok {
+		return res
+	}
+
+	if vn.compare(c.min) < 0 || vn.compare(c.max) > 0 {
+		return ConditionResult{Reason: fmt.Sprintf("%s outside %s to %s", vn, c.min, c.max)}
+	}
+
+	return ConditionResult{Met: true, Reason: fmt.Sprintf("%s within %s to %s", vn, c.min, c.max)}
+}
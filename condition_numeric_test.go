@@ -0,0 +1,137 @@
+package redtape
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNumericConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		val     interface{}
+		want    bool
+	}{
+		{"eq int", "numeric_eq", map[string]interface{}{"value": 3}, 3, true},
+		{"eq int float", "numeric_eq", map[string]interface{}{"value": 3}, 3.0, true},
+		{"eq uint8 int64", "numeric_eq", map[string]interface{}{"value": int64(200)}, uint8(200), true},
+		{"eq json number", "numeric_eq", map[string]interface{}{"value": 3}, json.Number("3"), true},
+		{"eq numeric string", "numeric_eq", map[string]interface{}{"value": "3"}, " 3 ", true},
+		{"eq fraction", "numeric_eq", map[string]interface{}{"value": 3}, 3.5, false},
+		{"eq non numeric", "numeric_eq", map[string]interface{}{"value": 3}, "three", false},
+		{"eq bool", "numeric_eq", map[string]interface{}{"value": 1}, true, false},
+		{"eq nil", "numeric_eq", map[string]interface{}{"value": 0}, nil, false},
+		{"eq large ints", "numeric_eq", map[string]interface{}{"value": int64(math.MaxInt64)}, int64(math.MaxInt64 - 1), false},
+		{"eq max uint", "numeric_eq", map[string]interface{}{"value": "18446744073709551615"}, uint64(math.MaxUint64), true},
+		{"ne", "numeric_ne", map[string]interface{}{"value": 3}, 4, true},
+		{"ne equal", "numeric_ne", map[string]interface{}{"value": 3}, float32(3), false},
+		{"ne non numeric", "numeric_ne", map[string]interface{}{"value": 3}, "x", false},
+		{"lt", "numeric_lt", map[string]interface{}{"value": 500}, 499.99, true},
+		{"lt equal", "numeric_lt", map[string]interface{}{"value": 500}, 500, false},
+		{"lt negative uint", "numeric_lt", map[string]interface{}{"value": uint64(math.MaxUint64)}, -1, true},
+		{"lt float past int64", "numeric_lt", map[string]interface{}{"value": float64(math.MaxInt64)}, int64(math.MaxInt64), true},
+		{"lte", "numeric_lte", map[string]interface{}{"value": 500}, json.Number("500"), true},
+		{"lte greater", "numeric_lte", map[string]interface{}{"value": 500}, "500.5", false},
+		{"gt", "numeric_gt", map[string]interface{}{"value": -1.5}, -1, true},
+		{"gt infinity", "numeric_gt", map[string]interface{}{"value": math.MaxInt64}, math.Inf(1), true},
+		{"gte", "numeric_gte", map[string]interface{}{"value": 3}, "3", true},
+		{"gte less", "numeric_gte", map[string]interface{}{"value": 3}, 2.999, false},
+		{"gte nan", "numeric_gte", map[string]interface{}{"value": 3}, math.NaN(), false},
+		{"between", "numeric_between", map[string]interface{}{"min": 1, "max": 10}, 5, true},
+		{"between inclusive min", "numeric_between", map[string]interface{}{"min": 1, "max": 10}, 1.0, true},
+		{"between inclusive max", "numeric_between", map[string]interface{}{"min": 1, "max": 10}, "10", true},
+		{"between outside", "numeric_between", map[string]interface{}{"min": 1, "max": 10}, 10.01, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ConditionOptions{{Name: "cond", Type: tt.typ, Options: tt.options}}
+
+			conds, err := NewConditions(opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := conds["cond"].Name(); got != tt.typ {
+				t.Errorf("Condition.Name() = %v, want %v", got, tt.typ)
+			}
+
+			if got := conds["cond"].Meets(tt.val, nil); got != tt.want {
+				t.Errorf("Condition.Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumericConditionRoundTrip(t *testing.T) {
+	doc := []byte(`{"conditions":[{"name":"amount","type":"numeric_lt","options":{"value":500}}]}`)
+
+	var o PolicyOptions
+	if err := json.Unmarshal(doc, &o); err != nil {
+		t.Fatal(err)
+	}
+
+	conds, err := NewConditions(o.Conditions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !conds["amount"].Meets(json.Number("120"), nil) {
+		t.Error("expected 120 to be less than 500")
+	}
+
+	b, err := (&policy{conditions: conds}).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rt PolicyOptions
+	if err := json.Unmarshal(b, &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	rconds, err := NewConditions(rt.Conditions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rconds["amount"].Meets(500, nil) {
+		t.Errorf("condition did not round trip: %s", b)
+	}
+}
+
+func TestNumericConditionValidation(t *testing.T) {
+	v := ValidatePolicyOptions(NewPolicyOptions(
+		PolicyName("p"),
+		SetActions("refund"),
+		WithRoleRefs("support"),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "amount", Type: "numeric_lt", Options: map[string]interface{}{
+			"value": "500",
+		}}),
+		WithCondition(ConditionOptions{Name: "version", Type: "numeric_gte", Options: map[string]interface{}{
+			"value": "three",
+		}}),
+		WithCondition(ConditionOptions{Name: "range", Type: "numeric_between", Options: map[string]interface{}{
+			"min": 10,
+			"max": 1,
+		}}),
+	), nil)
+
+	fields := problemFields(v.Errors())
+	if !reflect.DeepEqual(fields, []string{"conditions[1]", "conditions[2]"}) {
+		t.Errorf("ValidatePolicyOptions() errors = %v", v.Errors())
+	}
+
+	var c NumericBetweenCondition
+	c.Min, c.Max = 10, 1
+
+	cv := NewValidation()
+	c.Validate(cv)
+
+	if fields := problemFields(cv.Errors()); !reflect.DeepEqual(fields, []string{"min"}) {
+		t.Errorf("Validate() errors = %v", cv.Errors())
+	}
+}
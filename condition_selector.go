@@ -0,0 +1,82 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// metadataEnv resolves the roots of selectors as request metadata keys.
+type metadataEnv struct {
+	req *Request
+}
+
+func (e metadataEnv) resolve(name string) (interface{}, bool, error) {
+	v, ok, err := RequestAttribute(e.req, name)
+	if err != nil {
+		return nil, false, &providerError{name: name, err: err}
+	}
+
+	return v, ok, nil
+}
+
+// selectedCondition evaluates a condition against the metadata value addressed by a selector rather than
+// the value named by the condition.
+type selectedCondition struct {
+	Condition
+	selector string
+	path     exprNode
+}
+
+func newSelectedCondition(c Condition, selector string) (*selectedCondition, error) {
+	path, err := compileAttributeRef(selector)
+	if err != nil {
+		return nil, fmt.Errorf("selector: %w", err)
+	}
+
+	return &selectedCondition{
+		Condition: c,
+		selector:  selector,
+		path:      path,
+	}, nil
+}
+
+// Validate forwards validation to the selected condition.
+func (c *selectedCondition) Validate(v *Validation) {
+	if cv, ok := c.Condition.(ConditionValidator); ok {
+		cv.Validate(v)
+	}
+}
+
+// Evaluate forwards evaluation to the selected condition.
+func (c *selectedCondition) Evaluate(val interface{}, r *Request) ConditionResult {
+	if ce, ok := c.Condition.(ConditionEvaluator); ok {
+		return ce.Evaluate(val, r)
+	}
+
+	return ConditionResult{Met: c.Condition.Meets(val, r)}
+}
+
+// value returns the value addressed by the selector, or nil when the path does not exist. Only failures of
+// attribute providers are returned as errors.
+func (c *selectedCondition) value(r *Request) (interface{}, error) {
+	v, err := c.path.eval(metadataEnv{req: r})
+	if err != nil {
+		var perr *providerError
+		if errors.As(err, &perr) {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// unwrapCondition returns the condition wrapped by a selector along with the selector, if any.
+func unwrapCondition(c Condition) (Condition, string) {
+	if sc, ok := c.(*selectedCondition); ok {
+		return sc.Condition, sc.selector
+	}
+
+	return c, ""
+}
@@ -0,0 +1,138 @@
+package redtape
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type selectorBase struct {
+	Tenant string `json:"tenant"`
+}
+
+type selectorClaims struct {
+	selectorBase
+	Subject string   `json:"sub"`
+	Groups  []string `json:"groups"`
+	Secret  string   `json:"-"`
+	Level   int
+	private string
+}
+
+func TestConditionSelectors(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-Forwarded-For", "10.0.0.1")
+	header.Add("X-Forwarded-For", "10.0.0.2")
+
+	meta := map[string]interface{}{
+		"env": "prod",
+		"claims": map[string]interface{}{
+			"groups": []interface{}{"admins", "users"},
+			"org":    map[string]interface{}{"id": "acme"},
+		},
+		"token": &selectorClaims{
+			selectorBase: selectorBase{Tenant: "acme"},
+			Subject:      "alice",
+			Groups:       []string{"payments"},
+			Secret:       "s3cr3t",
+			Level:        2,
+			private:      "hidden",
+		},
+		"headers": header,
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		value    string
+		want     bool
+	}{
+		{"plain key", "", "prod", true},
+		{"root key", "env", "prod", true},
+		{"nested map", "claims.org.id", "acme", true},
+		{"slice index", "claims.groups[0]", "admins", true},
+		{"slice index out of range", "claims.groups[5]", "admins", false},
+		{"quoted key", `claims["org"]["id"]`, "acme", true},
+		{"struct json tag", "token.sub", "alice", true},
+		{"struct go name", "token.Subject", "alice", false},
+		{"struct no tag", "token.Level", "2", false},
+		{"struct embedded", "token.tenant", "acme", true},
+		{"struct slice", "token.groups[0]", "payments", true},
+		{"struct ignored", "token.Secret", "s3cr3t", false},
+		{"struct unexported", "token.private", "hidden", false},
+		{"header", `headers["X-Forwarded-For"][1]`, "10.0.0.2", true},
+		{"header any case", `headers["x-forwarded-for"][0]`, "10.0.0.1", true},
+		{"missing root", "missing.key", "prod", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "env"
+			if tt.selector != "" {
+				name = "check"
+			}
+
+			conds, err := NewConditions([]ConditionOptions{{
+				Name:     name,
+				Type:     "string_equals",
+				Selector: tt.selector,
+				Options:  map[string]interface{}{"value": tt.value},
+			}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := conds[name]
+			r := NewRequest("docs/1", "read", "alice", "", meta)
+
+			res := evaluateCondition(name, c, r)
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+
+			if res.Met != tt.want {
+				t.Errorf("Condition.Meets() = %v, want %v", res.Met, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionSelectorRoundTrip(t *testing.T) {
+	opts := []ConditionOptions{{
+		Name:     "admins",
+		Type:     "string_equals",
+		Selector: "claims.groups[0]",
+		Options:  map[string]interface{}{"value": "admins"},
+	}}
+
+	conds, err := NewConditions(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := (&policy{conditions: conds}).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), `"selector":"claims.groups[0]"`) {
+		t.Errorf("selector was not marshalled: %s", b)
+	}
+
+	var rt PolicyOptions
+	if err := json.Unmarshal(b, &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rt.Conditions) != 1 || rt.Conditions[0].Selector != "claims.groups[0]" {
+		t.Errorf("selector did not round trip: %s", b)
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{
+		Name:     "bad",
+		Type:     "bool",
+		Selector: "claims.groups[len(x)]",
+	}}, nil); err == nil {
+		t.Error("expected an invalid selector to be rejected")
+	}
+}
@@ -0,0 +1,342 @@
+package redtape
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+const (
+	// matchAny meets when any metadata value satisfies the condition.
+	matchAny = "any"
+	// matchAll meets when every metadata value satisfies the condition.
+	matchAll = "all"
+)
+
+// stringValues converts a string, []string or []interface{} of strings into a slice of strings.
+func stringValues(val interface{}) ([]string, bool) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+
+			out = append(out, s)
+		}
+
+		return out, true
+	}
+
+	return nil, false
+}
+
+// matchStrings applies pred to the string values of val. With the "all" mode every value must satisfy
+// pred, otherwise any value may. A value that is not a string or a list of strings, or an empty list,
+// never matches.
+func matchStrings(val interface{}, mode string, pred func(string) bool) bool {
+	vals, ok := stringValues(val)
+	if !ok || len(vals) == 0 {
+		return false
+	}
+
+	for _, v := range vals {
+		met := pred(v)
+
+		if mode == matchAll && !met {
+			return false
+		}
+
+		if mode != matchAll && met {
+			return true
+		}
+	}
+
+	return mode == matchAll
+}
+
+func validateMatchMode(v *Validation, mode string) {
+	if mode != "" && mode != matchAny && mode != matchAll {
+		v.Errorf("match", "unknown match mode %q, expected any or all", mode)
+	}
+}
+
+func foldCase(s string, ignoreCase bool) string {
+	if ignoreCase {
+		return strings.ToLower(s)
+	}
+
+	return s
+}
+
+// StringEqualsCondition matches string metadata values equal to Value.
+type StringEqualsCondition struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringEqualsCondition) Name() string {
+	return "string_equals"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringEqualsCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringEqualsCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val equal Value.
+func (c *StringEqualsCondition) Meets(val interface{}, _ *Request) bool {
+	return matchStrings(val, c.Match, func(s string) bool {
+		if c.IgnoreCase {
+			return strings.EqualFold(s, c.Value)
+		}
+
+		return s == c.Value
+	})
+}
+
+// StringNotEqualsCondition matches string metadata values that differ from Value.
+type StringNotEqualsCondition struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringNotEqualsCondition) Name() string {
+	return "string_not_equals"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringNotEqualsCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringNotEqualsCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val differ from Value.
+func (c *StringNotEqualsCondition) Meets(val interface{}, _ *Request) bool {
+	return matchStrings(val, c.Match, func(s string) bool {
+		if c.IgnoreCase {
+			return !strings.EqualFold(s, c.Value)
+		}
+
+		return s != c.Value
+	})
+}
+
+// StringInCondition matches string metadata values contained in Values.
+type StringInCondition struct {
+	Values     []string `json:"values"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Match      string   `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringInCondition) Name() string {
+	return "string_in"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringInCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringInCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+
+	if len(c.Values) == 0 {
+		v.Warnf("values", "no values given, the condition never matches")
+	}
+}
+
+// Meets evaluates true when the values of val are contained in Values.
+func (c *StringInCondition) Meets(val interface{}, _ *Request) bool {
+	return matchStrings(val, c.Match, func(s string) bool {
+		for _, cv := range c.Values {
+			if s == cv || c.IgnoreCase && strings.EqualFold(s, cv) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// StringPrefixCondition matches string metadata values starting with Value.
+type StringPrefixCondition struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringPrefixCondition) Name() string {
+	return "string_prefix"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringPrefixCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringPrefixCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val start with Value.
+func (c *StringPrefixCondition) Meets(val interface{}, _ *Request) bool {
+	prefix := foldCase(c.Value, c.IgnoreCase)
+
+	return matchStrings(val, c.Match, func(s string) bool {
+		return strings.HasPrefix(foldCase(s, c.IgnoreCase), prefix)
+	})
+}
+
+// StringSuffixCondition matches string metadata values ending with Value.
+type StringSuffixCondition struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringSuffixCondition) Name() string {
+	return "string_suffix"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringSuffixCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringSuffixCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val end with Value.
+func (c *StringSuffixCondition) Meets(val interface{}, _ *Request) bool {
+	suffix := foldCase(c.Value, c.IgnoreCase)
+
+	return matchStrings(val, c.Match, func(s string) bool {
+		return strings.HasSuffix(foldCase(s, c.IgnoreCase), suffix)
+	})
+}
+
+// StringContainsCondition matches string metadata values containing Value.
+type StringContainsCondition struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringContainsCondition) Name() string {
+	return "string_contains"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringContainsCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringContainsCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val contain Value.
+func (c *StringContainsCondition) Meets(val interface{}, _ *Request) bool {
+	sub := foldCase(c.Value, c.IgnoreCase)
+
+	return matchStrings(val, c.Match, func(s string) bool {
+		return strings.Contains(foldCase(s, c.IgnoreCase), sub)
+	})
+}
+
+// StringRegexCondition matches string metadata values against the regular expression Pattern. The pattern
+// is unanchored and compiled once when the condition is built.
+type StringRegexCondition struct {
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+	re         *regexp.Regexp
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringRegexCondition) Name() string {
+	return "string_regex"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringRegexCondition) ProvidedValue() {}
+
+// Init fulfills ConditionInitializer by compiling Pattern.
+func (c *StringRegexCondition) Init(_ ConditionRegistry) error {
+	pat := c.Pattern
+	if c.IgnoreCase {
+		pat = "(?i)" + pat
+	}
+
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return err
+	}
+
+	c.re = re
+
+	return nil
+}
+
+// Validate fulfills ConditionValidator.
+func (c *StringRegexCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val match Pattern.
+func (c *StringRegexCondition) Meets(val interface{}, _ *Request) bool {
+	if c.re == nil {
+		return false
+	}
+
+	return matchStrings(val, c.Match, c.re.MatchString)
+}
+
+// StringGlobCondition matches string metadata values against the wildcard Pattern, where * matches any
+// sequence of characters and ? matches a single character.
+type StringGlobCondition struct {
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Match      string `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *StringGlobCondition) Name() string {
+	return "string_glob"
+}
+
+// ProvidedValue fulfills ProvidedValueCondition.
+func (c *StringGlobCondition) ProvidedValue() {}
+
+// Validate fulfills ConditionValidator.
+func (c *StringGlobCondition) Validate(v *Validation) {
+	validateMatchMode(v, c.Match)
+}
+
+// Meets evaluates true when the values of val match Pattern.
+func (c *StringGlobCondition) Meets(val interface{}, _ *Request) bool {
+	pat := foldCase(c.Pattern, c.IgnoreCase)
+
+	return matchStrings(val, c.Match, func(s string) bool {
+		return strmatch.MatchWildcard(pat, foldCase(s, c.IgnoreCase))
+	})
+}
@@ -0,0 +1,99 @@
+package redtape
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStringConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		val     interface{}
+		want    bool
+	}{
+		{"equals", "string_equals", map[string]interface{}{"value": "eu"}, "eu", true},
+		{"equals case", "string_equals", map[string]interface{}{"value": "EU"}, "eu", false},
+		{"equals ignore case", "string_equals", map[string]interface{}{"value": "EU", "ignore_case": true}, "eu", true},
+		{"equals any of", "string_equals", map[string]interface{}{"value": "eu"}, []string{"us", "eu"}, true},
+		{"equals all of", "string_equals", map[string]interface{}{"value": "eu", "match": "all"}, []string{"us", "eu"}, false},
+		{"equals interface list", "string_equals", map[string]interface{}{"value": "eu"}, []interface{}{"eu"}, true},
+		{"equals wrong type", "string_equals", map[string]interface{}{"value": "1"}, 1, false},
+		{"equals empty list", "string_equals", map[string]interface{}{"value": "eu", "match": "all"}, []string{}, false},
+		{"not equals", "string_not_equals", map[string]interface{}{"value": "dev"}, "prod", true},
+		{"not equals all of", "string_not_equals", map[string]interface{}{"value": "dev", "match": "all"}, []string{"prod", "dev"}, false},
+		{"not equals ignore case", "string_not_equals", map[string]interface{}{"value": "DEV", "ignore_case": true}, "dev", false},
+		{"in", "string_in", map[string]interface{}{"values": []string{"read", "list"}}, "list", true},
+		{"in ignore case all of", "string_in", map[string]interface{}{"values": []string{"read", "list"}, "ignore_case": true, "match": "all"}, []string{"READ", "List"}, true},
+		{"in missing", "string_in", map[string]interface{}{"values": []string{"read"}}, "write", false},
+		{"prefix", "string_prefix", map[string]interface{}{"value": "team-"}, "team-payments", true},
+		{"prefix ignore case", "string_prefix", map[string]interface{}{"value": "TEAM-", "ignore_case": true}, "team-payments", true},
+		{"suffix", "string_suffix", map[string]interface{}{"value": "@example.com"}, "bob@example.com", true},
+		{"suffix all of", "string_suffix", map[string]interface{}{"value": "@example.com", "match": "all"}, []string{"a@example.com", "b@evil.com"}, false},
+		{"contains", "string_contains", map[string]interface{}{"value": "admin"}, "super-admin-1", true},
+		{"contains ignore case", "string_contains", map[string]interface{}{"value": "ADMIN", "ignore_case": true}, "super-admin-1", true},
+		{"regex", "string_regex", map[string]interface{}{"pattern": "^v[0-9]+$"}, "v12", true},
+		{"regex ignore case", "string_regex", map[string]interface{}{"pattern": "^v[0-9]+$", "ignore_case": true}, "V12", true},
+		{"regex no match", "string_regex", map[string]interface{}{"pattern": "^v[0-9]+$"}, "v1.2", false},
+		{"glob", "string_glob", map[string]interface{}{"pattern": "*.example.com"}, "api.example.com", true},
+		{"glob ignore case", "string_glob", map[string]interface{}{"pattern": "*.EXAMPLE.com", "ignore_case": true}, "api.example.COM", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ConditionOptions{{Name: "cond", Type: tt.typ, Options: tt.options}}
+
+			conds, err := NewConditions(opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := conds["cond"].Meets(tt.val, nil); got != tt.want {
+				t.Errorf("Condition.Meets() = %v, want %v", got, tt.want)
+			}
+
+			p := &policy{conditions: conds}
+
+			b, err := p.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rt PolicyOptions
+			if err := json.Unmarshal(b, &rt); err != nil {
+				t.Fatal(err)
+			}
+
+			rconds, err := NewConditions(rt.Conditions, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rconds, conds) {
+				t.Errorf("condition did not round trip: %s", b)
+			}
+		})
+	}
+}
+
+func TestStringConditionValidation(t *testing.T) {
+	v := ValidatePolicyOptions(NewPolicyOptions(
+		PolicyName("p"),
+		SetActions("read"),
+		WithRoleRefs("reader"),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "env", Type: "string_equals", Options: map[string]interface{}{
+			"value": "prod",
+			"match": "some",
+		}}),
+		WithCondition(ConditionOptions{Name: "ver", Type: "string_regex", Options: map[string]interface{}{
+			"pattern": "(",
+		}}),
+	), nil)
+
+	fields := problemFields(v.Errors())
+	if !reflect.DeepEqual(fields, []string{"conditions[0].options.match", "conditions[1]"}) {
+		t.Errorf("ValidatePolicyOptions() errors = %v", v.Errors())
+	}
+}
@@ -2,6 +2,7 @@ package redtape
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -78,3 +79,122 @@ func TestNewConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestCompositeConditions(t *testing.T) {
+	doc := []byte(`{
+		"name": "office_or_mfa",
+		"roles": ["staff"],
+		"actions": ["read"],
+		"effect": "allow",
+		"conditions": [{
+			"name": "access",
+			"type": "any",
+			"options": {
+				"conditions": [
+					{"name": "office", "type": "bool", "options": {"value": true}},
+					{"name": "mfa", "type": "all", "options": {"conditions": [
+						{"name": "mfa", "type": "bool", "options": {"value": true}},
+						{"name": "locked", "type": "not", "options": {"condition": {
+							"name": "locked", "type": "bool", "options": {"value": true}
+						}}}
+					]}}
+				]
+			}
+		}]
+	}`)
+
+	var opts PolicyOptions
+	if err := json.Unmarshal(doc, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPolicy(SetPolicyOptions(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rt PolicyOptions
+	if err := json.Unmarshal(b, &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rt.Conditions, opts.Conditions) {
+		t.Fatalf("conditions did not round trip:\n%s", b)
+	}
+
+	rp, err := NewPolicy(SetPolicyOptions(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"office", map[string]interface{}{"office": true}, true},
+		{"mfa", map[string]interface{}{"mfa": true}, true},
+		{"mfa locked", map[string]interface{}{"mfa": true, "locked": true}, false},
+		{"neither", map[string]interface{}{"mfa": false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, pol := range []Policy{p, rp} {
+				req := NewRequest("", "read", "staff", "", tt.meta)
+				c := pol.Conditions()["access"]
+				if got := c.Meets(nil, req); got != tt.want {
+					t.Errorf("Condition.Meets() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompositeConditionErrors(t *testing.T) {
+	amount := ConditionOptions{Name: "amount", Type: "numeric_lt", Options: map[string]interface{}{"value": 500}}
+	mfa := ConditionOptions{Name: "mfa", Type: "bool", Options: map[string]interface{}{"value": true}}
+
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		meta    map[string]interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"all error", "all", map[string]interface{}{"conditions": []ConditionOptions{amount, mfa}}, map[string]interface{}{"amount": "x", "mfa": true}, false, true},
+		{"all not met wins", "all", map[string]interface{}{"conditions": []ConditionOptions{amount, mfa}}, map[string]interface{}{"amount": "x", "mfa": false}, false, false},
+		{"any error", "any", map[string]interface{}{"conditions": []ConditionOptions{amount, mfa}}, map[string]interface{}{"amount": "x", "mfa": false}, false, true},
+		{"any met wins", "any", map[string]interface{}{"conditions": []ConditionOptions{amount, mfa}}, map[string]interface{}{"amount": "x", "mfa": true}, true, false},
+		{"not error is not negated", "not", map[string]interface{}{"condition": amount}, map[string]interface{}{"amount": "x"}, false, true},
+		{"not", "not", map[string]interface{}{"condition": amount}, map[string]interface{}{"amount": 900}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, err := NewConditions([]ConditionOptions{{Name: "c", Type: tt.typ, Options: tt.options}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := NewRequest("", "read", "staff", "", tt.meta)
+
+			res := evaluateCondition("c", conds["c"], req)
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.Met != tt.want {
+				t.Errorf("Evaluate() met = %v, want %v", res.Met, tt.want)
+			}
+
+			if got := conds["c"].Meets(nil, req); got != (tt.want && !tt.wantErr) {
+				t.Errorf("Condition.Meets() = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// dateLayout is accepted in addition to RFC 3339 for dates without a time of day.
+const dateLayout = "2006-01-02"
+
+// conditionTime returns the time a time based condition is evaluated at: the metadata value val when one is
+// given, otherwise the time of the request. Metadata values may be a time.Time, an RFC 3339 string or unix
+// seconds.
+func conditionTime(val interface{}, r *Request) (time.Time, bool) {
+	switch t := val.(type) {
+	case nil:
+		return RequestTime(r), true
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, false
+		}
+
+		return *t, true
+	case string:
+		pt, err := time.Parse(time.RFC3339, strings.TrimSpace(t))
+		return pt, err == nil
+	}
+
+	n, ok := toNumber(val)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch n.kind {
+	case numberInt:
+		return time.Unix(n.i, 0), true
+	case numberFloat:
+		sec, frac := math.Modf(n.f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+
+	return time.Time{}, false
+}
+
+// evalTime returns the time of val or r in loc, or the result of a condition that could not read it.
+func evalTime(val interface{}, r *Request, loc *time.Location) (time.Time, ConditionResult, bool) {
+	if loc == nil {
+		return time.Time{}, ConditionResult{Err: errors.New("condition is not initialized")}, false
+	}
+
+	t, ok := conditionTime(val, r)
+	if !ok {
+		return time.Time{}, ConditionResult{Err: fmt.Errorf("%v (%T) is not a time", val, val)}, false
+	}
+
+	return t.In(loc), ConditionResult{}, true
+}
+
+func timeResult(met bool, t time.Time, desc string) ConditionResult {
+	if !met {
+		return ConditionResult{Reason: fmt.Sprintf("%s is not %s", t.Format(time.RFC3339), desc)}
+	}
+
+	return ConditionResult{Met: true, Reason: fmt.Sprintf("%s is %s", t.Format(time.RFC3339), desc)}
+}
+
+// loadLocation returns the named time zone, or UTC when name is empty.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(name)
+}
+
+func validateLocation(v *Validation, name string) {
+	if _, err := loadLocation(name); err != nil {
+		v.Errorf("timezone", "%v", err)
+	}
+}
+
+// parseClock parses a time of day in the form 15:04 or 15:04:05 into a duration since midnight.
+func parseClock(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid time of day %q, expected HH:MM or HH:MM:SS", s)
+}
+
+// parseDate parses an RFC 3339 timestamp or a date in the form 2006-01-02, which is taken as midnight in loc.
+func parseDate(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected RFC 3339 or YYYY-MM-DD", s)
+	}
+
+	return t, nil
+}
+
+// TimeOfDayCondition matches when the time of day falls within the window from Start, inclusive, to End,
+// exclusive. A window whose end is before its start wraps past midnight, so 22:00 to 06:00 covers the night.
+type TimeOfDayCondition struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+	start    time.Duration
+	end      time.Duration
+	loc      *time.Location
+}
+
+// Name fulfills the Name method of Condition.
+func (c *TimeOfDayCondition) Name() string {
+	return "time_of_day"
+}
+
+// Init fulfills ConditionInitializer by parsing the window and time zone.
+func (c *TimeOfDayCondition) Init(_ ConditionRegistry) (err error) {
+	if c.start, err = parseClock(c.Start); err != nil {
+		return err
+	}
+
+	if c.end, err = parseClock(c.End); err != nil {
+		return err
+	}
+
+	if c.start == c.end {
+		return fmt.Errorf("start and end %s describe an empty window", c.Start)
+	}
+
+	c.loc, err = loadLocation(c.Timezone)
+
+	return err
+}
+
+// Validate fulfills ConditionValidator.
+func (c *TimeOfDayCondition) Validate(v *Validation) {
+	start, serr := parseClock(c.Start)
+	if serr != nil {
+		v.Errorf("start", "%v", serr)
+	}
+
+	end, eerr := parseClock(c.End)
+	if eerr != nil {
+		v.Errorf("end", "%v", eerr)
+	}
+
+	if serr == nil && eerr == nil && start == end {
+		v.Errorf("end", "equals start, the window is empty")
+	}
+
+	validateLocation(v, c.Timezone)
+}
+
+// Meets evaluates true when the time of val, or of the request, is within the window.
+func (c *TimeOfDayCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a time is an error.
+func (c *TimeOfDayCondition) Evaluate(val interface{}, r *Request) ConditionResult {
+	t, res, ok := evalTime(val, r, c.loc)
+	if !ok {
+		return res
+	}
+
+	return timeResult(c.within(t), t, "between "+c.Start+" and "+c.End)
+}
+
+func (c *TimeOfDayCondition) within(t time.Time) bool {
+	d := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+
+	if c.start < c.end {
+		return d >= c.start && d < c.end
+	}
+
+	return d >= c.start || d < c.end
+}
+
+// DayOfWeekCondition matches when the day of the week is one of Days. Days are given by their English
+// names or three letter abbreviations in any case.
+type DayOfWeekCondition struct {
+	Days     []string `json:"days"`
+	Timezone string   `json:"timezone"`
+	days     map[time.Weekday]bool
+	loc      *time.Location
+}
+
+// Name fulfills the Name method of Condition.
+func (c *DayOfWeekCondition) Name() string {
+	return "day_of_week"
+}
+
+// Init fulfills ConditionInitializer by parsing Days and the time zone.
+func (c *DayOfWeekCondition) Init(_ ConditionRegistry) (err error) {
+	c.days = make(map[time.Weekday]bool, len(c.Days))
+
+	for _, d := range c.Days {
+		wd, err := parseWeekday(d)
+		if err != nil {
+			return err
+		}
+
+		c.days[wd] = true
+	}
+
+	c.loc, err = loadLocation(c.Timezone)
+
+	return err
+}
+
+// Validate fulfills ConditionValidator.
+func (c *DayOfWeekCondition) Validate(v *Validation) {
+	if len(c.Days) == 0 {
+		v.Warnf("days", "no days given, the condition never matches")
+	}
+
+	for i, d := range c.Days {
+		if _, err := parseWeekday(d); err != nil {
+			v.Errorf(fmt.Sprintf("days[%d]", i), "%v", err)
+		}
+	}
+
+	validateLocation(v, c.Timezone)
+}
+
+// Meets evaluates true when the time of val, or of the request, falls on one of Days.
+func (c *DayOfWeekCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a time is an error.
+func (c *DayOfWeekCondition) Evaluate(val interface{}, r *Request) ConditionResult {
+	t, res, ok := evalTime(val, r, c.loc)
+	if !ok {
+		return res
+	}
+
+	return timeResult(c.days[t.Weekday()], t, "on "+strings.Join(c.Days, ", "))
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown day of the week %q", s)
+}
+
+// DateRangeCondition matches when the time is on or after After and before Before. Either bound may be
+// left empty to leave the range open. Bounds are RFC 3339 timestamps or dates, which start at midnight in
+// the configured time zone.
+type DateRangeCondition struct {
+	After    string `json:"after"`
+	Before   string `json:"before"`
+	Timezone string `json:"timezone"`
+	after    time.Time
+	before   time.Time
+}
+
+// Name fulfills the Name method of Condition.
+func (c *DateRangeCondition) Name() string {
+	return "date_range"
+}
+
+// Init fulfills ConditionInitializer by parsing the bounds.
+func (c *DateRangeCondition) Init(_ ConditionRegistry) error {
+	loc, err := loadLocation(c.Timezone)
+	if err != nil {
+		return err
+	}
+
+	if c.After != "" {
+		if c.after, err = parseDate(c.After, loc); err != nil {
+			return err
+		}
+	}
+
+	if c.Before != "" {
+		if c.before, err = parseDate(c.Before, loc); err != nil {
+			return err
+		}
+	}
+
+	if !c.after.IsZero() && !c.before.IsZero() && !c.after.Before(c.before) {
+		return fmt.Errorf("after %s is not before %s", c.After, c.Before)
+	}
+
+	return nil
+}
+
+// Validate fulfills ConditionValidator.
+func (c *DateRangeCondition) Validate(v *Validation) {
+	loc, err := loadLocation(c.Timezone)
+	if err != nil {
+		v.Errorf("timezone", "%v", err)
+		return
+	}
+
+	if c.After == "" && c.Before == "" {
+		v.Warnf("", "no after or before given, the condition always matches")
+	}
+
+	for _, b := range []struct {
+		field string
+		val   string
+	}{
+		{"after", c.After},
+		{"before", c.Before},
+	} {
+		if b.val == "" {
+			continue
+		}
+
+		if _, err := parseDate(b.val, loc); err != nil {
+			v.Errorf(b.field, "%v", err)
+		}
+	}
+}
+
+// Meets evaluates true when the time of val, or of the request, is within the range.
+func (c *DateRangeCondition) Meets(val interface{}, r *Request) bool {
+	return resultMeets(c.Evaluate(val, r))
+}
+
+// Evaluate fulfills ConditionEvaluator. A value that is not a time is an error.
+func (c *DateRangeCondition) Evaluate(val interface{}, r *Request) ConditionResult {
+	t, res, ok := evalTime(val, r, time.UTC)
+	if !ok {
+		return res
+	}
+
+	return timeResult(c.within(t), t, "in range")
+}
+
+func (c *DateRangeCondition) within(t time.Time) bool {
+	if !c.after.IsZero() && t.Before(c.after) {
+		return false
+	}
+
+	if !c.before.IsZero() && !t.Before(c.before) {
+		return false
+	}
+
+	return true
+}
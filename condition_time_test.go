@@ -0,0 +1,111 @@
+package redtape
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeConditions(t *testing.T) {
+	// monday 2021-06-07 23:30 UTC, tuesday 01:30 in Berlin
+	at := time.Date(2021, time.June, 7, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		val     interface{}
+		want    bool
+	}{
+		{"time of day", "time_of_day", map[string]interface{}{"start": "09:00", "end": "17:00"}, "2021-06-07T12:00:00Z", true},
+		{"time of day start inclusive", "time_of_day", map[string]interface{}{"start": "09:00", "end": "17:00"}, "2021-06-07T09:00:00Z", true},
+		{"time of day end exclusive", "time_of_day", map[string]interface{}{"start": "09:00", "end": "17:00"}, "2021-06-07T17:00:00Z", false},
+		{"time of day seconds", "time_of_day", map[string]interface{}{"start": "09:00", "end": "16:59:59"}, "2021-06-07T16:59:59Z", false},
+		{"time of day wraps midnight", "time_of_day", map[string]interface{}{"start": "22:00", "end": "06:00"}, at, true},
+		{"time of day wraps midnight outside", "time_of_day", map[string]interface{}{"start": "22:00", "end": "06:00"}, "2021-06-07T12:00:00Z", false},
+		{"time of day timezone", "time_of_day", map[string]interface{}{"start": "22:00", "end": "23:59", "timezone": "Europe/Berlin"}, at, false},
+		{"time of day unix", "time_of_day", map[string]interface{}{"start": "00:00", "end": "01:00"}, 60, true},
+		{"time of day json number", "time_of_day", map[string]interface{}{"start": "00:00", "end": "01:00"}, json.Number("3600"), false},
+		{"time of day invalid value", "time_of_day", map[string]interface{}{"start": "00:00", "end": "23:00"}, "noon", false},
+		{"day of week", "day_of_week", map[string]interface{}{"days": []string{"Monday"}}, at, true},
+		{"day of week abbreviation", "day_of_week", map[string]interface{}{"days": []string{"MON", "wed"}}, &at, true},
+		{"day of week timezone", "day_of_week", map[string]interface{}{"days": []string{"mon"}, "timezone": "Europe/Berlin"}, at, false},
+		{"date range", "date_range", map[string]interface{}{"after": "2021-06-01", "before": "2021-07-01"}, at, true},
+		{"date range before exclusive", "date_range", map[string]interface{}{"before": "2021-06-07T23:30:00Z"}, at, false},
+		{"date range after inclusive", "date_range", map[string]interface{}{"after": "2021-06-07T23:30:00Z"}, at, true},
+		{"date range timezone", "date_range", map[string]interface{}{"after": "2021-06-08", "timezone": "Europe/Berlin"}, at, true},
+		{"date range open", "date_range", map[string]interface{}{}, at, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ConditionOptions{{Name: "cond", Type: tt.typ, Options: tt.options}}
+
+			conds, err := NewConditions(opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := conds["cond"].Meets(tt.val, nil); got != tt.want {
+				t.Errorf("Condition.Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeConditionsClock(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "hours", Type: "time_of_day", Options: map[string]interface{}{"start": "09:00", "end": "17:00"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2021, time.June, 7, 10, 0, 0, 0, time.UTC)
+	r := NewRequest("orders/1", "refund", "support", "")
+	r.Context = NewRequestClockContext(r.Context, func() time.Time { return now })
+
+	if !conds["hours"].Meets(nil, r) {
+		t.Error("expected the request clock to be within business hours")
+	}
+
+	now = now.Add(-2 * time.Hour)
+
+	if conds["hours"].Meets(nil, r) {
+		t.Error("expected the request clock to be outside business hours")
+	}
+}
+
+func TestTimeConditionValidation(t *testing.T) {
+	v := ValidatePolicyOptions(NewPolicyOptions(
+		PolicyName("p"),
+		SetActions("refund"),
+		WithRoleRefs("support"),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "hours", Type: "time_of_day", Options: map[string]interface{}{
+			"start": "9am",
+			"end":   "17:00",
+		}}),
+		WithCondition(ConditionOptions{Name: "days", Type: "day_of_week", Options: map[string]interface{}{
+			"days":     []string{"mon"},
+			"timezone": "Mars/Olympus",
+		}}),
+		WithCondition(ConditionOptions{Name: "range", Type: "date_range", Options: map[string]interface{}{
+			"after":  "2021-07-01",
+			"before": "2021-06-01",
+		}}),
+	), nil)
+
+	fields := problemFields(v.Errors())
+	if !reflect.DeepEqual(fields, []string{"conditions[0]", "conditions[1]", "conditions[2]"}) {
+		t.Errorf("ValidatePolicyOptions() errors = %v", v.Errors())
+	}
+
+	c := &DayOfWeekCondition{Days: []string{"mon", "someday"}, Timezone: "Mars/Olympus"}
+	cv := NewValidation()
+	c.Validate(cv)
+
+	if fields := problemFields(cv.Errors()); !reflect.DeepEqual(fields, []string{"days[1]", "timezone"}) {
+		t.Errorf("Validate() errors = %v", cv.Errors())
+	}
+}
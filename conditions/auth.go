@@ -0,0 +1,381 @@
+package conditions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// AuthContext describes how the subject of a request authenticated. Its fields follow the OpenID Connect
+// claims: Methods are the authentication methods references (amr) such as pwd, otp or mfa, Time is the time
+// of authentication (auth_time), ACR is the authentication context class reference and Level an ordered
+// assurance level, higher being stronger.
+type AuthContext struct {
+	Methods []string  `json:"amr"`
+	Time    time.Time `json:"auth_time"`
+	ACR     string    `json:"acr"`
+	Level   int       `json:"level"`
+}
+
+// HasMethod reports whether m is one of the authentication methods.
+func (ac AuthContext) HasMethod(m string) bool {
+	for _, am := range ac.Methods {
+		if strings.EqualFold(am, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type authContextKey struct{}
+
+// WithAuthContext returns a copy of ctx carrying ac, for authentication middleware to hand the context to the
+// redtape HTTP middleware.
+func WithAuthContext(ctx context.Context, ac AuthContext) context.Context {
+	return context.WithValue(ctx, authContextKey{}, ac)
+}
+
+// AuthContextFromContext returns the AuthContext stored in ctx by WithAuthContext.
+func AuthContextFromContext(ctx context.Context) (AuthContext, bool) {
+	if ctx == nil {
+		return AuthContext{}, false
+	}
+
+	ac, ok := ctx.Value(authContextKey{}).(AuthContext)
+
+	return ac, ok
+}
+
+// AuthContextFromClaims builds an AuthContext from the claims of an ID or access token. The amr claim may be a
+// list or a space separated string, auth_time is in unix seconds, and a numeric acr or level claim sets Level.
+func AuthContextFromClaims(claims map[string]interface{}) (AuthContext, error) {
+	var ac AuthContext
+
+	if amr, ok := claims["amr"]; ok && amr != nil {
+		methods, ok := authMethods(amr)
+		if !ok {
+			return ac, fmt.Errorf("amr: %v (%T) is not a list of methods", amr, amr)
+		}
+
+		ac.Methods = methods
+	}
+
+	if at, ok := claims["auth_time"]; ok && at != nil {
+		t, err := authTime(at)
+		if err != nil {
+			return ac, fmt.Errorf("auth_time: %w", err)
+		}
+
+		ac.Time = t
+	}
+
+	if acr, ok := claims["acr"]; ok && acr != nil {
+		ac.ACR = fmt.Sprint(acr)
+
+		if l, err := authLevel(acr); err == nil {
+			ac.Level = l
+		}
+	}
+
+	if lv, ok := claims["level"]; ok && lv != nil {
+		l, err := authLevel(lv)
+		if err != nil {
+			return ac, fmt.Errorf("level: %w", err)
+		}
+
+		ac.Level = l
+	}
+
+	return ac, nil
+}
+
+// authContextValue reads an AuthContext from a condition value, which may be an AuthContext, a pointer to one
+// or a map of claims.
+func authContextValue(val interface{}) (AuthContext, error) {
+	switch v := val.(type) {
+	case AuthContext:
+		return v, nil
+	case *AuthContext:
+		if v == nil {
+			return AuthContext{}, errors.New("no authentication context")
+		}
+
+		return *v, nil
+	case map[string]interface{}:
+		return AuthContextFromClaims(v)
+	case redtape.RequestMetadata:
+		return AuthContextFromClaims(v)
+	}
+
+	return AuthContext{}, fmt.Errorf("%v (%T) is not an authentication context", val, val)
+}
+
+func authMethods(val interface{}) ([]string, bool) {
+	if s, ok := val.(string); ok {
+		return strings.Fields(s), true
+	}
+
+	switch v := val.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+
+			out = append(out, s)
+		}
+
+		return out, true
+	}
+
+	return nil, false
+}
+
+func authTime(val interface{}) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+	}
+
+	f, err := authNumber(val)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	sec, frac := math.Modf(f)
+
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+func authLevel(val interface{}) (int, error) {
+	f, err := authNumber(val)
+	if err != nil {
+		return 0, err
+	}
+
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("%v is not a whole number", val)
+	}
+
+	return int(f), nil
+}
+
+func authNumber(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+
+	return 0, fmt.Errorf("%v (%T) is not a number", val, val)
+}
+
+// evalAuth reads the authentication context of val and passes it to eval. A missing context is not met and
+// one that cannot be read is an error.
+func evalAuth(val interface{}, eval func(AuthContext) redtape.ConditionResult) redtape.ConditionResult {
+	if val == nil {
+		return redtape.ConditionResult{Reason: "not authenticated"}
+	}
+
+	ac, err := authContextValue(val)
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	return eval(ac)
+}
+
+// authClockSkew is how far in the future of the request time an authentication time may lie before it is
+// rejected, allowing for clocks of identity providers running slightly ahead.
+const authClockSkew = time.Minute
+
+// AuthMaxAgeCondition matches subjects that authenticated no longer than MaxAge ago, a duration such as 15m,
+// measured at the time of the request. An authentication time more than a minute in the future is not met.
+// Combine it with AuthMethodsCondition to require a recent MFA.
+//
+// The auth conditions read an AuthContext, or a map of the amr, auth_time, acr and level claims, from the
+// metadata value of the condition.
+type AuthMaxAgeCondition struct {
+	MaxAge string `json:"max_age"`
+	maxAge time.Duration
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AuthMaxAgeCondition) Name() string {
+	return "auth_max_age"
+}
+
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *AuthMaxAgeCondition) ProvidedValue() {}
+
+// Init fulfills redtape.ConditionInitializer by parsing MaxAge.
+func (c *AuthMaxAgeCondition) Init(_ redtape.ConditionRegistry) error {
+	d, err := time.ParseDuration(c.MaxAge)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid max_age %q", c.MaxAge)
+	}
+
+	c.maxAge = d
+
+	return nil
+}
+
+// Validate fulfills redtape.ConditionValidator.
+func (c *AuthMaxAgeCondition) Validate(v *redtape.Validation) {
+	if d, err := time.ParseDuration(c.MaxAge); err != nil || d <= 0 {
+		v.Errorf("max_age", "invalid duration %q", c.MaxAge)
+	}
+}
+
+// Meets evaluates true when the authentication in val is recent enough.
+func (c *AuthMaxAgeCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator.
+func (c *AuthMaxAgeCondition) Evaluate(val interface{}, r *redtape.Request) redtape.ConditionResult {
+	if c.maxAge <= 0 {
+		return redtape.ConditionResult{Err: errors.New("condition is not initialized")}
+	}
+
+	return evalAuth(val, func(ac AuthContext) redtape.ConditionResult {
+		if ac.Time.IsZero() {
+			return redtape.ConditionResult{Reason: "no authentication time"}
+		}
+
+		age := redtape.RequestTime(r).Sub(ac.Time)
+		if age < -authClockSkew {
+			return redtape.ConditionResult{Reason: fmt.Sprintf("authenticated %s in the future", (-age).Round(time.Second))}
+		}
+
+		if age > c.maxAge {
+			return redtape.ConditionResult{Reason: fmt.Sprintf("authenticated %s ago, more than %s", age.Round(time.Second), c.maxAge)}
+		}
+
+		return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("authenticated within %s", c.maxAge)}
+	})
+}
+
+// AuthMethodsCondition matches subjects that authenticated with every one of Methods, or with any of them when
+// Match is "any". Methods compare without regard to case.
+type AuthMethodsCondition struct {
+	Methods []string `json:"methods"`
+	Match   string   `json:"match"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AuthMethodsCondition) Name() string {
+	return "auth_methods"
+}
+
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *AuthMethodsCondition) ProvidedValue() {}
+
+// Validate fulfills redtape.ConditionValidator.
+func (c *AuthMethodsCondition) Validate(v *redtape.Validation) {
+	if len(c.Methods) == 0 {
+		v.Errorf("methods", "at least one method is required")
+	}
+
+	if c.Match != "" && c.Match != "any" && c.Match != "all" {
+		v.Errorf("match", "unknown match mode %q, expected any or all", c.Match)
+	}
+}
+
+// Meets evaluates true when the authentication in val used the required methods.
+func (c *AuthMethodsCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator.
+func (c *AuthMethodsCondition) Evaluate(val interface{}, _ *redtape.Request) redtape.ConditionResult {
+	return evalAuth(val, func(ac AuthContext) redtape.ConditionResult {
+		for _, m := range c.Methods {
+			has := ac.HasMethod(m)
+
+			if c.Match == "any" && has {
+				return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("authenticated with %s", m)}
+			}
+
+			if c.Match != "any" && !has {
+				return redtape.ConditionResult{Reason: fmt.Sprintf("did not authenticate with %s", m)}
+			}
+		}
+
+		if c.Match == "any" {
+			return redtape.ConditionResult{Reason: fmt.Sprintf("authenticated with none of %s", strings.Join(c.Methods, ", "))}
+		}
+
+		return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("authenticated with %s", strings.Join(c.Methods, ", "))}
+	})
+}
+
+// AuthLevelCondition matches subjects whose authentication reached an assurance level of at least MinLevel.
+type AuthLevelCondition struct {
+	MinLevel int `json:"min_level"`
+}
+
+// Name fulfills the Name method of Condition.
+func (c *AuthLevelCondition) Name() string {
+	return "auth_level"
+}
+
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *AuthLevelCondition) ProvidedValue() {}
+
+// Validate fulfills redtape.ConditionValidator.
+func (c *AuthLevelCondition) Validate(v *redtape.Validation) {
+	if c.MinLevel <= 0 {
+		v.Warnf("min_level", "is not positive, any authentication matches")
+	}
+}
+
+// Meets evaluates true when the authentication in val is strong enough.
+func (c *AuthLevelCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator.
+func (c *AuthLevelCondition) Evaluate(val interface{}, _ *redtape.Request) redtape.ConditionResult {
+	return evalAuth(val, func(ac AuthContext) redtape.ConditionResult {
+		if ac.Level < c.MinLevel {
+			return redtape.ConditionResult{Reason: fmt.Sprintf("assurance level %d is below %d", ac.Level, c.MinLevel)}
+		}
+
+		return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("assurance level %d is at least %d", ac.Level, c.MinLevel)}
+	})
+}
@@ -0,0 +1,161 @@
+package conditions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+func TestAuthContextFromClaims(t *testing.T) {
+	ac, err := AuthContextFromClaims(map[string]interface{}{
+		"amr":       []interface{}{"pwd", "otp"},
+		"auth_time": json.Number("1614589200"),
+		"acr":       "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ac.HasMethod("OTP") || ac.HasMethod("hwk") {
+		t.Errorf("Methods = %v", ac.Methods)
+	}
+
+	if !ac.Time.Equal(time.Unix(1614589200, 0)) {
+		t.Errorf("Time = %v", ac.Time)
+	}
+
+	if ac.ACR != "2" || ac.Level != 2 {
+		t.Errorf("ACR = %q, Level = %d", ac.ACR, ac.Level)
+	}
+
+	ac, err = AuthContextFromClaims(map[string]interface{}{"amr": "pwd mfa", "acr": "urn:loa:high", "level": 3.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ac.Methods) != 2 || ac.ACR != "urn:loa:high" || ac.Level != 3 {
+		t.Errorf("AuthContextFromClaims() = %+v", ac)
+	}
+
+	for name, claims := range map[string]map[string]interface{}{
+		"amr":       {"amr": []interface{}{"pwd", 1}},
+		"auth_time": {"auth_time": "yesterday"},
+		"level":     {"level": 1.5},
+	} {
+		if _, err := AuthContextFromClaims(claims); err == nil {
+			t.Errorf("%s: AuthContextFromClaims() error = nil", name)
+		}
+	}
+}
+
+func TestAuthConditions(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	ctx := redtape.NewRequestClockContext(context.Background(), func() time.Time { return now })
+	req := redtape.NewRequestWithContext(ctx, "", "", "", "")
+
+	recentMFA := AuthContext{Methods: []string{"pwd", "mfa"}, Time: now.Add(-10 * time.Minute), Level: 2}
+	staleMFA := &AuthContext{Methods: []string{"pwd", "mfa"}, Time: now.Add(-time.Hour), Level: 2}
+	claims := map[string]interface{}{"amr": []interface{}{"pwd"}, "auth_time": float64(now.Unix()), "acr": "1"}
+
+	reg := redtape.NewConditionRegistry(Builders())
+
+	tests := []struct {
+		name    string
+		typ     string
+		options map[string]interface{}
+		val     interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"recent", "auth_max_age", map[string]interface{}{"max_age": "15m"}, recentMFA, true, false},
+		{"stale", "auth_max_age", map[string]interface{}{"max_age": "15m"}, staleMFA, false, false},
+		{"claims age", "auth_max_age", map[string]interface{}{"max_age": "1s"}, claims, true, false},
+		{"skewed", "auth_max_age", map[string]interface{}{"max_age": "15m"}, AuthContext{Time: now.Add(30 * time.Second)}, true, false},
+		{"future", "auth_max_age", map[string]interface{}{"max_age": "15m"}, AuthContext{Time: now.Add(time.Hour)}, false, false},
+		{"no time", "auth_max_age", map[string]interface{}{"max_age": "15m"}, AuthContext{}, false, false},
+		{"unauthenticated", "auth_max_age", map[string]interface{}{"max_age": "15m"}, nil, false, false},
+		{"wrong type", "auth_max_age", map[string]interface{}{"max_age": "15m"}, "mfa", false, true},
+		{"methods all", "auth_methods", map[string]interface{}{"methods": []string{"pwd", "MFA"}}, recentMFA, true, false},
+		{"methods missing", "auth_methods", map[string]interface{}{"methods": []string{"pwd", "mfa"}}, claims, false, false},
+		{"methods any", "auth_methods", map[string]interface{}{"methods": []string{"hwk", "pwd"}, "match": "any"}, claims, true, false},
+		{"methods none", "auth_methods", map[string]interface{}{"methods": []string{"hwk"}, "match": "any"}, claims, false, false},
+		{"level", "auth_level", map[string]interface{}{"min_level": 2}, recentMFA, true, false},
+		{"level low", "auth_level", map[string]interface{}{"min_level": 2}, claims, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, err := redtape.NewConditions([]redtape.ConditionOptions{
+				{Name: "auth", Type: tt.typ, Options: tt.options},
+			}, reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := conds["auth"].(redtape.ConditionEvaluator).Evaluate(tt.val, req)
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.Met != tt.want {
+				t.Errorf("Evaluate() met = %v, want %v (%s)", res.Met, tt.want, res.Reason)
+			}
+		})
+	}
+
+	if _, err := redtape.NewConditions([]redtape.ConditionOptions{
+		{Name: "auth", Type: "auth_max_age", Options: map[string]interface{}{"max_age": "soon"}},
+	}, reg); err == nil {
+		t.Error("NewConditions() with an invalid max_age error = nil")
+	}
+}
+
+func TestRecentMFAPolicy(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	p, err := redtape.NewPolicy(
+		redtape.PolicyName("sensitive"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("admin")),
+		redtape.SetResources("keys"),
+		redtape.SetActions("rotate"),
+		redtape.SetConditionRegistry(redtape.SharedConditionRegistry()),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name: "mfa", Type: "auth_methods", Selector: "auth",
+			Options: map[string]interface{}{"methods": []string{"mfa"}},
+		}),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name: "recent", Type: "auth_max_age", Selector: "auth",
+			Options: map[string]interface{}{"max_age": "15m"},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := redtape.NewManager()
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm, redtape.EnforcerClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		ac   AuthContext
+		want bool
+	}{
+		"recent mfa": {AuthContext{Methods: []string{"mfa"}, Time: now.Add(-time.Minute)}, true},
+		"stale mfa":  {AuthContext{Methods: []string{"mfa"}, Time: now.Add(-time.Hour)}, false},
+		"password":   {AuthContext{Methods: []string{"pwd"}, Time: now.Add(-time.Minute)}, false},
+	} {
+		req := redtape.NewRequest("keys", "rotate", "admin", "", map[string]interface{}{"auth": tt.ac})
+		if err := e.Enforce(req); (err == nil) != tt.want {
+			t.Errorf("%s: Enforce() = %v, want allowed %v", name, err, tt.want)
+		}
+	}
+}
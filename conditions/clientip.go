@@ -0,0 +1,108 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client from a chain of hops ordered from the client to the last proxy,
+// as found in an X-Forwarded-For header followed by the remote address of the connection. Hops are read from
+// the right and skipped while they are within trusted, so the client is the first hop not added by a trusted
+// proxy. Forwarded addresses cannot be spoofed past a proxy that is not trusted. When every hop is trusted,
+// the leftmost is the client. An invalid hop reached on the way is an error.
+func ClientIP(chain []string, trusted *IPRanges) (net.IP, error) {
+	return clientIP(len(chain), func(i int) (net.IP, error) {
+		return parseHop(chain[i])
+	}, trusted)
+}
+
+// RequestClientIP returns the address of the client of an HTTP request, consulting the X-Forwarded-For
+// headers only as far as they were added by proxies in trusted. See ClientIP.
+func RequestClientIP(r *http.Request, trusted *IPRanges) (net.IP, error) {
+	var chain []string
+
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		chain = append(chain, strings.Split(h, ",")...)
+	}
+
+	return ClientIP(append(chain, r.RemoteAddr), trusted)
+}
+
+func clientIP(n int, hop func(int) (net.IP, error), trusted *IPRanges) (net.IP, error) {
+	if n == 0 {
+		return nil, errors.New("no address")
+	}
+
+	for i := n - 1; i > 0; i-- {
+		ip, err := hop(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if !trusted.Contains(ip) {
+			return ip, nil
+		}
+	}
+
+	return hop(0)
+}
+
+// parseHop parses an address, optionally followed by a port as in the remote address of a connection.
+func parseHop(s string) (net.IP, error) {
+	s = strings.TrimSpace(s)
+
+	if ip := net.ParseIP(s); ip != nil {
+		return ip, nil
+	}
+
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid address %q", s)
+}
+
+// valueIP returns the client address of a condition value: a string holding an address or a comma separated
+// X-Forwarded-For chain, a net.IP, or a list of addresses ordered from the client to the last proxy.
+func valueIP(val interface{}, trusted *IPRanges) (net.IP, error) {
+	switch v := val.(type) {
+	case net.IP:
+		if v == nil {
+			return nil, errors.New("no address")
+		}
+
+		return v, nil
+	case string:
+		return ClientIP(strings.Split(v, ","), trusted)
+	case []string:
+		return ClientIP(v, trusted)
+	case []net.IP:
+		return clientIP(len(v), func(i int) (net.IP, error) {
+			if v[i] == nil {
+				return nil, fmt.Errorf("invalid address at %d", i)
+			}
+
+			return v[i], nil
+		}, trusted)
+	case []interface{}:
+		return clientIP(len(v), func(i int) (net.IP, error) {
+			switch h := v[i].(type) {
+			case string:
+				return parseHop(h)
+			case net.IP:
+				if h != nil {
+					return h, nil
+				}
+			}
+
+			return nil, fmt.Errorf("%v (%T) is not an address", v[i], v[i])
+		}, trusted)
+	}
+
+	return nil, fmt.Errorf("%v (%T) is not an address", val, val)
+}
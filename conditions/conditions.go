@@ -0,0 +1,37 @@
+// Package conditions provides additional redtape conditions. Importing the package adds them to the shared
+// registry returned by redtape.SharedConditionRegistry.
+package conditions
+
+import "github.com/blushft/redtape"
+
+func init() {
+	redtape.RegisterConditions(Builders())
+}
+
+// Builders returns the ConditionBuilders of the conditions in this package, keyed by condition name, for use
+// with redtape.NewConditionRegistry.
+func Builders() map[string]redtape.ConditionBuilder {
+	return map[string]redtape.ConditionBuilder{
+		new(IPAllowCondition).Name(): func() redtape.Condition {
+			return new(IPAllowCondition)
+		},
+		new(IPDenyCondition).Name(): func() redtape.Condition {
+			return new(IPDenyCondition)
+		},
+		new(GeoCountryCondition).Name(): func() redtape.Condition {
+			return new(GeoCountryCondition)
+		},
+		new(RateLimitCondition).Name(): func() redtape.Condition {
+			return new(RateLimitCondition)
+		},
+		new(AuthMaxAgeCondition).Name(): func() redtape.Condition {
+			return new(AuthMaxAgeCondition)
+		},
+		new(AuthMethodsCondition).Name(): func() redtape.Condition {
+			return new(AuthMethodsCondition)
+		},
+		new(AuthLevelCondition).Name(): func() redtape.Condition {
+			return new(AuthLevelCondition)
+		},
+	}
+}
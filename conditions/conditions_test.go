@@ -0,0 +1,36 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/blushft/redtape"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedConditionRegistry(t *testing.T) {
+	opts := []redtape.PolicyOption{
+		redtape.PolicyName("office"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("staff")),
+		redtape.SetResources("*"),
+		redtape.SetActions("read"),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name: "remote_addr",
+			Type: "ip_allow",
+			Options: map[string]interface{}{
+				"networks": []string{"10.0.0.0/8"},
+			},
+		}),
+	}
+
+	_, err := redtape.NewPolicy(opts...)
+	assert.Error(t, err, "ip_allow is not in the default registry")
+
+	p, err := redtape.NewPolicy(append(opts, redtape.SetConditionRegistry(redtape.SharedConditionRegistry()))...)
+	require.NoError(t, err)
+	require.Contains(t, p.Conditions(), "remote_addr")
+
+	_, ok := p.Conditions()["remote_addr"].(*IPAllowCondition)
+	assert.True(t, ok)
+}
@@ -0,0 +1,478 @@
+package conditions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blushft/redtape"
+)
+
+// UnknownCountry is the country reported for addresses missing from a GeoDB. It can be listed in the allow or
+// deny lists of a GeoCountryCondition like any other code.
+const UnknownCountry = "ZZ"
+
+// geoMagic starts the binary format written by GeoDB#WriteTo.
+var geoMagic = []byte("RTGEO\x01")
+
+// geoRange maps the addresses from start to end, inclusive, in their 16 byte form to a country.
+type geoRange struct {
+	start   [16]byte
+	end     [16]byte
+	country [2]byte
+}
+
+// GeoDB maps address ranges to ISO 3166-1 alpha-2 country codes. Ranges are kept sorted so lookups are a
+// binary search.
+type GeoDB struct {
+	ranges []geoRange
+}
+
+// NewGeoDB returns an empty GeoDB. Ranges are added with Add and the database must be sealed with Build
+// before it is used.
+func NewGeoDB() *GeoDB {
+	return &GeoDB{}
+}
+
+// Add maps a network in CIDR notation, a single address, or a range of addresses written start-end to
+// country.
+func (db *GeoDB) Add(network, country string) error {
+	cc, err := parseCountry(country)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := parseGeoRange(network)
+	if err != nil {
+		return err
+	}
+
+	db.ranges = append(db.ranges, geoRange{start: start, end: end, country: cc})
+
+	return nil
+}
+
+// Build sorts the ranges and checks that none overlap.
+func (db *GeoDB) Build() error {
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+
+	for i := 1; i < len(db.ranges); i++ {
+		prev, cur := db.ranges[i-1], db.ranges[i]
+		if bytes.Compare(cur.start[:], prev.end[:]) <= 0 {
+			return fmt.Errorf("range %s-%s overlaps %s-%s",
+				net.IP(cur.start[:]), net.IP(cur.end[:]), net.IP(prev.start[:]), net.IP(prev.end[:]))
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of ranges.
+func (db *GeoDB) Len() int {
+	return len(db.ranges)
+}
+
+// Country returns the country of ip, or false when no range contains it.
+func (db *GeoDB) Country(ip net.IP) (string, bool) {
+	ip16 := ip.To16()
+	if db == nil || ip16 == nil {
+		return "", false
+	}
+
+	// the first range starting after ip, the one before it is the only candidate
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start[:], ip16) > 0
+	})
+
+	if i == 0 {
+		return "", false
+	}
+
+	r := db.ranges[i-1]
+	if bytes.Compare(ip16, r.end[:]) > 0 {
+		return "", false
+	}
+
+	return string(r.country[:]), true
+}
+
+// WriteTo writes the database in a compact binary format read by ReadGeoDB and LoadGeoDB: a magic header, a
+// big endian range count and 34 bytes per range.
+func (db *GeoDB) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+
+	var n int64
+
+	write := func(b []byte) error {
+		m, err := bw.Write(b)
+		n += int64(m)
+
+		return err
+	}
+
+	if err := write(geoMagic); err != nil {
+		return n, err
+	}
+
+	var count [4]byte
+	binary.BigEndian.PutUint32(count[:], uint32(len(db.ranges)))
+
+	if err := write(count[:]); err != nil {
+		return n, err
+	}
+
+	for _, r := range db.ranges {
+		for _, b := range [][]byte{r.start[:], r.end[:], r.country[:]} {
+			if err := write(b); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, bw.Flush()
+}
+
+// geoPreallocRanges bounds the ranges ReadGeoDB allocates room for before reading them.
+const geoPreallocRanges = 1 << 16
+
+// ReadGeoDB reads a database in the binary format written by GeoDB#WriteTo.
+func ReadGeoDB(r io.Reader) (*GeoDB, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(geoMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, geoMagic) {
+		return nil, errors.New("not a geo database")
+	}
+
+	var count [4]byte
+	if _, err := io.ReadFull(br, count[:]); err != nil {
+		return nil, err
+	}
+
+	// the count is not trusted with an allocation, a corrupt header fails once the records run out
+	n := binary.BigEndian.Uint32(count[:])
+	capacity := n
+	if capacity > geoPreallocRanges {
+		capacity = geoPreallocRanges
+	}
+
+	db := &GeoDB{ranges: make([]geoRange, 0, capacity)}
+
+	for i := uint32(0); i < n; i++ {
+		var rec [34]byte
+		if _, err := io.ReadFull(br, rec[:]); err != nil {
+			return nil, fmt.Errorf("range %d: %w", i, err)
+		}
+
+		var gr geoRange
+		copy(gr.start[:], rec[:16])
+		copy(gr.end[:], rec[16:32])
+		copy(gr.country[:], rec[32:])
+
+		db.ranges = append(db.ranges, gr)
+	}
+
+	return db, db.Build()
+}
+
+// ReadGeoCSV reads a database from CSV records of a network and a country code, or of the first and last
+// address of a range and a country code. Blank lines, lines starting with # and a header row, a first line
+// holding neither an address nor a country code, are skipped.
+func ReadGeoCSV(r io.Reader) (*GeoDB, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	db := NewGeoDB()
+
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		var network, country string
+
+		switch len(rec) {
+		case 2:
+			network, country = rec[0], rec[1]
+		case 3:
+			network, country = rec[0]+"-"+rec[1], rec[2]
+		default:
+			return nil, fmt.Errorf("line %d: expected 2 or 3 fields, got %d", line, len(rec))
+		}
+
+		if line == 1 && isGeoHeader(rec[0], country) {
+			continue
+		}
+
+		if err := db.Add(network, country); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return db, db.Build()
+}
+
+// isGeoHeader tells whether a record whose first field is addr names its columns rather than holding data.
+func isGeoHeader(addr, country string) bool {
+	if _, err := parseCountry(country); err == nil {
+		return false
+	}
+
+	addr = strings.TrimSpace(addr)
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		addr = addr[:i]
+	}
+
+	return net.ParseIP(addr) == nil
+}
+
+// LoadGeoDB reads a database file in the binary format of GeoDB#WriteTo or in the CSV format of ReadGeoCSV.
+func LoadGeoDB(path string) (*GeoDB, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(b, geoMagic) {
+		return ReadGeoDB(bytes.NewReader(b))
+	}
+
+	return ReadGeoCSV(bytes.NewReader(b))
+}
+
+var (
+	geoMu  sync.Mutex
+	geoDBs = make(map[string]*GeoDB)
+)
+
+// RegisterGeoDB makes db available to geo_country conditions under name.
+func RegisterGeoDB(name string, db *GeoDB) {
+	geoMu.Lock()
+	defer geoMu.Unlock()
+
+	geoDBs[name] = db
+}
+
+// geoDB returns the database registered under name, loading it from the file name on first use.
+func geoDB(name string) (*GeoDB, error) {
+	geoMu.Lock()
+	defer geoMu.Unlock()
+
+	if db, ok := geoDBs[name]; ok {
+		return db, nil
+	}
+
+	db, err := LoadGeoDB(name)
+	if err != nil {
+		return nil, err
+	}
+
+	geoDBs[name] = db
+
+	return db, nil
+}
+
+func parseGeoRange(s string) (start, end [16]byte, err error) {
+	if i := strings.Index(s, "-"); i >= 0 {
+		first := net.ParseIP(strings.TrimSpace(s[:i]))
+		last := net.ParseIP(strings.TrimSpace(s[i+1:]))
+
+		if first == nil || last == nil {
+			return start, end, fmt.Errorf("invalid range %q", s)
+		}
+
+		copy(start[:], first.To16())
+		copy(end[:], last.To16())
+
+		if bytes.Compare(start[:], end[:]) > 0 {
+			return start, end, fmt.Errorf("invalid range %q, start is after end", s)
+		}
+
+		return start, end, nil
+	}
+
+	n, err := parseNetwork(s)
+	if err != nil {
+		return start, end, err
+	}
+
+	ip := n.IP.To16()
+	copy(start[:], ip)
+	copy(end[:], ip)
+
+	// the mask of an IPv4 network covers the last 4 bytes of the 16 byte form
+	mask := n.Mask
+	off := 16 - len(mask)
+
+	for i := range mask {
+		end[off+i] |= ^mask[i]
+	}
+
+	return start, end, nil
+}
+
+func parseCountry(s string) ([2]byte, error) {
+	var cc [2]byte
+
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 2 || s[0] < 'A' || s[0] > 'Z' || s[1] < 'A' || s[1] > 'Z' {
+		return cc, fmt.Errorf("invalid country code %q", s)
+	}
+
+	copy(cc[:], s)
+
+	return cc, nil
+}
+
+// GeoCountryCondition restricts access by the country of the client address, looked up in Database: the name
+// of a database registered with RegisterGeoDB, or the path of a database file, which is loaded once. The
+// condition is met when the country is in Allow, if given, and not in Deny. Addresses missing from the
+// database have the country UnknownCountry.
+//
+// The value is read as by IPAllowCondition, including X-Forwarded-For chains and TrustedProxies.
+type GeoCountryCondition struct {
+	Database       string   `json:"database"`
+	Allow          []string `json:"allow,omitempty"`
+	Deny           []string `json:"deny,omitempty"`
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	db             *GeoDB
+	allow          map[string]bool
+	deny           map[string]bool
+	trusted        *IPRanges
+}
+
+// Name fulfills the Name method of Condition.
+func (c *GeoCountryCondition) Name() string {
+	return "geo_country"
+}
+
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *GeoCountryCondition) ProvidedValue() {}
+
+// Init fulfills redtape.ConditionInitializer by loading the database and parsing the lists.
+func (c *GeoCountryCondition) Init(_ redtape.ConditionRegistry) (err error) {
+	if c.db, err = geoDB(c.Database); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+
+	if c.allow, err = countrySet(c.Allow); err != nil {
+		return fmt.Errorf("allow: %w", err)
+	}
+
+	if c.deny, err = countrySet(c.Deny); err != nil {
+		return fmt.Errorf("deny: %w", err)
+	}
+
+	if c.trusted, err = ParseIPRanges(c.TrustedProxies...); err != nil {
+		return fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	return nil
+}
+
+// Validate fulfills redtape.ConditionValidator.
+func (c *GeoCountryCondition) Validate(v *redtape.Validation) {
+	if c.Database == "" {
+		v.Errorf("database", "is required")
+	}
+
+	if len(c.Allow) == 0 && len(c.Deny) == 0 {
+		v.Warnf("", "no allow or deny list given, the condition always matches")
+	}
+
+	for _, l := range []struct {
+		field string
+		codes []string
+	}{
+		{"allow", c.Allow},
+		{"deny", c.Deny},
+	} {
+		for i, cc := range l.codes {
+			if _, err := parseCountry(cc); err != nil {
+				v.Errorf(fmt.Sprintf("%s[%d]", l.field, i), "%v", err)
+			}
+		}
+	}
+
+	for i, ns := range c.TrustedProxies {
+		if _, err := parseNetwork(ns); err != nil {
+			v.Errorf(fmt.Sprintf("trusted_proxies[%d]", i), "%v", err)
+		}
+	}
+}
+
+// Meets evaluates true when the country of the client address in val is allowed.
+func (c *GeoCountryCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator. A missing address is an error when a deny list is given, so it
+// cannot pass the condition unchecked, and a value that is not an address is always an error.
+func (c *GeoCountryCondition) Evaluate(val interface{}, _ *redtape.Request) redtape.ConditionResult {
+	if c.db == nil {
+		return redtape.ConditionResult{Err: errors.New("condition is not initialized")}
+	}
+
+	if val == nil {
+		if len(c.deny) > 0 {
+			return redtape.ConditionResult{Err: errors.New("no address")}
+		}
+
+		return redtape.ConditionResult{Reason: "no address"}
+	}
+
+	ip, err := valueIP(val, c.trusted)
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	country, ok := c.db.Country(ip)
+	if !ok {
+		country = UnknownCountry
+	}
+
+	if c.deny[country] {
+		return redtape.ConditionResult{Reason: fmt.Sprintf("%s is in denied country %s", ip, country)}
+	}
+
+	if len(c.allow) > 0 && !c.allow[country] {
+		return redtape.ConditionResult{Reason: fmt.Sprintf("%s is in country %s, which is not allowed", ip, country)}
+	}
+
+	return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("%s is in allowed country %s", ip, country)}
+}
+
+func countrySet(codes []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(codes))
+
+	for _, s := range codes {
+		cc, err := parseCountry(s)
+		if err != nil {
+			return nil, err
+		}
+
+		set[string(cc[:])] = true
+	}
+
+	return set, nil
+}
@@ -0,0 +1,184 @@
+package conditions
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+const testGeoCSV = `# test dataset
+network,country
+192.0.2.0/24,fr
+198.51.100.0,198.51.100.127,de
+198.51.100.128/25,US
+2001:db8::/32,JP
+203.0.113.7,CA
+`
+
+func TestGeoDBLookup(t *testing.T) {
+	db, err := ReadGeoCSV(strings.NewReader(testGeoCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Len() != 5 {
+		t.Fatalf("Len() = %d, want 5", db.Len())
+	}
+
+	var buf bytes.Buffer
+	if _, err := db.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := ReadGeoDB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.0", "FR"},
+		{"192.0.2.255", "FR"},
+		{"192.0.3.0", ""},
+		{"198.51.100.127", "DE"},
+		{"198.51.100.128", "US"},
+		{"198.51.100.255", "US"},
+		{"2001:db8:ffff::1", "JP"},
+		{"2001:db9::", ""},
+		{"203.0.113.7", "CA"},
+		{"203.0.113.8", ""},
+		{"::ffff:192.0.2.1", "FR"},
+		{"1.1.1.1", ""},
+	}
+	for _, tt := range tests {
+		for name, d := range map[string]*GeoDB{"csv": db, "binary": bin} {
+			got, _ := d.Country(net.ParseIP(tt.ip))
+			if got != tt.want {
+				t.Errorf("%s Country(%s) = %q, want %q", name, tt.ip, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestGeoDBErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"overlap":  "10.0.0.0/8,FR\n10.1.0.0/16,DE\n",
+		"country":  "10.0.0.0/8,FR\n11.0.0.0/8,France\n",
+		"reversed": "10.0.0.0/8,FR\n11.0.0.9,11.0.0.1,DE\n",
+		"fields":   "10.0.0.0/8,FR\n11.0.0.0/8\n",
+		"network":  "10.0.0.0/8,FR\n11.0.0.0/33,DE\n",
+		// a malformed first row is data, not a header
+		"first country": "10.0.0.0/8,France\n11.0.0.0/8,DE\n",
+		"first network": "10.0.0.0/33,FR\n11.0.0.0/8,DE\n",
+	} {
+		if _, err := ReadGeoCSV(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: ReadGeoCSV() error = nil", name)
+		}
+	}
+
+	if _, err := ReadGeoDB(strings.NewReader("RTGEO\x01\x00\x00\x00\x02")); err == nil {
+		t.Error("ReadGeoDB() of a truncated database error = nil")
+	}
+
+	if _, err := ReadGeoDB(strings.NewReader("RTGEO\x01\xff\xff\xff\xff")); err == nil {
+		t.Error("ReadGeoDB() of a database with a corrupt count error = nil")
+	}
+}
+
+func TestGeoCountryCondition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	if err := os.WriteFile(path, []byte(testGeoCSV), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := redtape.NewConditionRegistry(Builders())
+
+	conds, err := redtape.NewConditions([]redtape.ConditionOptions{
+		{
+			Name: "allow",
+			Type: "geo_country",
+			Options: map[string]interface{}{
+				"database": path,
+				"allow":    []string{"fr", "de"},
+			},
+		},
+		{
+			Name: "deny",
+			Type: "geo_country",
+			Options: map[string]interface{}{
+				"database":        path,
+				"deny":            []string{"US", UnknownCountry},
+				"trusted_proxies": []string{"10.0.0.0/8"},
+			},
+		},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allow := conds["allow"].(*GeoCountryCondition)
+	deny := conds["deny"].(*GeoCountryCondition)
+
+	tests := []struct {
+		name    string
+		cond    *GeoCountryCondition
+		val     interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"allowed", allow, "192.0.2.1", true, false},
+		{"allowed range", allow, net.ParseIP("198.51.100.1"), true, false},
+		{"not allowed", allow, "2001:db8::1", false, false},
+		{"unknown not allowed", allow, "1.1.1.1", false, false},
+		{"allow missing", allow, nil, false, false},
+		{"allow invalid", allow, "nowhere", false, true},
+		{"denied", deny, "198.51.100.200", false, false},
+		{"denied unknown", deny, "1.1.1.1", false, false},
+		{"not denied", deny, "203.0.113.7", true, false},
+		{"denied through proxy", deny, "198.51.100.200, 10.0.0.1", false, false},
+		{"deny missing", deny, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.cond.Evaluate(tt.val, nil)
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.Err == nil && res.Met != tt.want {
+				t.Errorf("Evaluate() met = %v, want %v (%s)", res.Met, tt.want, res.Reason)
+			}
+		})
+	}
+
+	if _, err := redtape.NewConditions([]redtape.ConditionOptions{
+		{Name: "missing", Type: "geo_country", Options: map[string]interface{}{
+			"database": filepath.Join(t.TempDir(), "missing.csv"),
+		}},
+	}, reg); err == nil {
+		t.Error("NewConditions() with a missing database error = nil")
+	}
+}
+
+func TestGeoCountryValidation(t *testing.T) {
+	c := &GeoCountryCondition{Allow: []string{"FR", "FRA"}, Deny: []string{"1x"}}
+
+	v := redtape.NewValidation()
+	c.Validate(v)
+
+	fields := make([]string, 0)
+	for _, e := range v.Errors() {
+		fields = append(fields, e.Field)
+	}
+
+	if strings.Join(fields, ",") != "database,allow[1],deny[0]" {
+		t.Errorf("Validate() fields = %v", fields)
+	}
+}
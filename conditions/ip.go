@@ -1,15 +1,84 @@
 package conditions
 
 import (
+	"errors"
+	"fmt"
 	"net"
 
 	"github.com/blushft/redtape"
 )
 
-// IPAllowCondition performs CIDR matching for a range of Networks against a provided value and
-// allows access on match.
+// ipMatcher holds the parsed ranges of an IP condition.
+type ipMatcher struct {
+	networks *IPRanges
+	trusted  *IPRanges
+}
+
+func newIPMatcher(networks, ranges, trusted []string) (*ipMatcher, error) {
+	nets, err := ParseIPRanges(networks...)
+	if err != nil {
+		return nil, fmt.Errorf("networks: %w", err)
+	}
+
+	named, err := lookupIPRanges(ranges)
+	if err != nil {
+		return nil, fmt.Errorf("ranges: %w", err)
+	}
+
+	proxies, err := ParseIPRanges(trusted...)
+	if err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	return &ipMatcher{
+		networks: nets.merge(named),
+		trusted:  proxies,
+	}, nil
+}
+
+// match returns the client address of val along with the first network containing it, or an empty network
+// when none does.
+func (m *ipMatcher) match(val interface{}) (net.IP, string, error) {
+	ip, err := valueIP(val, m.trusted)
+	if err != nil {
+		return nil, "", err
+	}
+
+	network, _ := m.networks.Match(ip)
+
+	return ip, network, nil
+}
+
+func validateIPOptions(v *redtape.Validation, networks, ranges, trusted []string) {
+	for i, ns := range networks {
+		if _, err := parseNetwork(ns); err != nil {
+			v.Errorf(fmt.Sprintf("networks[%d]", i), "%v", err)
+		}
+	}
+
+	for i, name := range ranges {
+		if _, ok := NamedIPRanges(name); !ok {
+			v.Errorf(fmt.Sprintf("ranges[%d]", i), "unknown ip ranges %q", name)
+		}
+	}
+
+	for i, ns := range trusted {
+		if _, err := parseNetwork(ns); err != nil {
+			v.Errorf(fmt.Sprintf("trusted_proxies[%d]", i), "%v", err)
+		}
+	}
+}
+
+// IPAllowCondition allows access when the client address is within one of Networks or of the named lists in
+// Ranges. Networks are given in CIDR notation or as single addresses, in IPv4 or IPv6.
+//
+// The value may be an address, a net.IP, or an X-Forwarded-For chain given as a comma separated string or a
+// list. The client address of a chain is found by skipping the hops added by TrustedProxies, see ClientIP.
 type IPAllowCondition struct {
-	Networks []string `json:"networks"`
+	Networks       []string `json:"networks"`
+	Ranges         []string `json:"ranges,omitempty"`
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	m              *ipMatcher
 }
 
 // Name fulfills the Name method of Condition.
@@ -17,21 +86,71 @@ func (c *IPAllowCondition) Name() string {
 	return "ip_allow"
 }
 
-// Meets evaluates true when the network address in val is contained within
-// one of the CIDR ranges of IPAllowCondition#Networks.
-func (c *IPAllowCondition) Meets(val interface{}, _ *redtape.Request) bool {
-	ip, ok := val.(string)
-	if !ok {
-		return false
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *IPAllowCondition) ProvidedValue() {}
+
+// Init fulfills redtape.ConditionInitializer by parsing the networks once.
+func (c *IPAllowCondition) Init(_ redtape.ConditionRegistry) (err error) {
+	c.m, err = newIPMatcher(c.Networks, c.Ranges, c.TrustedProxies)
+	return err
+}
+
+// Meets evaluates true when the client address in val is contained within one of the allowed networks.
+func (c *IPAllowCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator. A value that is not an address and an invalid network are
+// errors.
+func (c *IPAllowCondition) Evaluate(val interface{}, _ *redtape.Request) redtape.ConditionResult {
+	if val == nil {
+		return redtape.ConditionResult{Reason: "no address"}
 	}
 
-	return matchIP(ip, c.Networks)
+	m, err := c.matcher()
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	ip, network, err := m.match(val)
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	if network == "" {
+		return redtape.ConditionResult{Reason: fmt.Sprintf("%s is not in an allowed network", ip)}
+	}
+
+	return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("%s is in %s", ip, network)}
+}
+
+// matcher returns the ranges parsed by Init, parsing them on every call for conditions built without it.
+func (c *IPAllowCondition) matcher() (*ipMatcher, error) {
+	if c.m != nil {
+		return c.m, nil
+	}
+
+	return newIPMatcher(c.Networks, c.Ranges, c.TrustedProxies)
+}
+
+// Validate fulfills redtape.ConditionValidator by checking every network, range list and proxy.
+func (c *IPAllowCondition) Validate(v *redtape.Validation) {
+	if len(c.Networks) == 0 && len(c.Ranges) == 0 {
+		v.Warnf("networks", "no networks given, the condition never matches")
+	}
+
+	validateIPOptions(v, c.Networks, c.Ranges, c.TrustedProxies)
 }
 
-// IPDenyCondition performs CIDR matching for a range of Networks against a provided value and denys.
-// access on match.
+// IPDenyCondition denies access when the client address is within one of Networks or of the named lists in
+// Ranges. It accepts the same options and values as IPAllowCondition.
 type IPDenyCondition struct {
-	Networks []string `json:"networks"`
+	Networks       []string `json:"networks"`
+	Ranges         []string `json:"ranges,omitempty"`
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	m              *ipMatcher
 }
 
 // Name fulfills the Name method of Condition.
@@ -39,33 +158,60 @@ func (c *IPDenyCondition) Name() string {
 	return "ip_deny"
 }
 
-// Meets evaluates true when the network address in val is contained within
-// one of the CIDR ranges of IPDenyCondition#Networks.
-func (c *IPDenyCondition) Meets(val interface{}, _ *redtape.Request) bool {
-	ip, ok := val.(string)
-	if !ok {
-		return false
+// ProvidedValue fulfills redtape.ProvidedValueCondition.
+func (c *IPDenyCondition) ProvidedValue() {}
+
+// Init fulfills redtape.ConditionInitializer by parsing the networks once.
+func (c *IPDenyCondition) Init(_ redtape.ConditionRegistry) (err error) {
+	c.m, err = newIPMatcher(c.Networks, c.Ranges, c.TrustedProxies)
+	return err
+}
+
+// Meets evaluates true when the client address in val is not contained within any of the denied networks.
+func (c *IPDenyCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator. A missing address, a value that is not an address and an
+// invalid network are errors, so that they never pass the condition by not matching a denied network.
+func (c *IPDenyCondition) Evaluate(val interface{}, _ *redtape.Request) redtape.ConditionResult {
+	if val == nil {
+		return redtape.ConditionResult{Err: errors.New("no address")}
+	}
+
+	m, err := c.matcher()
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	ip, network, err := m.match(val)
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
 	}
 
-	return !matchIP(ip, c.Networks)
+	if network != "" {
+		return redtape.ConditionResult{Reason: fmt.Sprintf("%s is in denied network %s", ip, network)}
+	}
+
+	return redtape.ConditionResult{Met: true, Reason: fmt.Sprintf("%s is not in a denied network", ip)}
 }
 
-func matchIP(val string, nets []string) bool {
-	for _, ns := range nets {
-		_, cidr, err := net.ParseCIDR(ns)
-		if err != nil {
-			return false
-		}
+// matcher returns the ranges parsed by Init, parsing them on every call for conditions built without it.
+func (c *IPDenyCondition) matcher() (*ipMatcher, error) {
+	if c.m != nil {
+		return c.m, nil
+	}
 
-		tip := net.ParseIP(val)
-		if tip == nil {
-			return false
-		}
+	return newIPMatcher(c.Networks, c.Ranges, c.TrustedProxies)
+}
 
-		if cidr.Contains(tip) {
-			return true
-		}
+// Validate fulfills redtape.ConditionValidator by checking every network, range list and proxy.
+func (c *IPDenyCondition) Validate(v *redtape.Validation) {
+	if len(c.Networks) == 0 && len(c.Ranges) == 0 {
+		v.Warnf("networks", "no networks given, the condition always matches")
 	}
 
-	return false
+	validateIPOptions(v, c.Networks, c.Ranges, c.TrustedProxies)
 }
@@ -1,6 +1,9 @@
 package conditions
 
 import (
+	"net"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/blushft/redtape"
@@ -8,14 +11,7 @@ import (
 )
 
 func TestIPConditions(t *testing.T) {
-	reg := redtape.NewConditionRegistry(map[string]redtape.ConditionBuilder{
-		new(IPAllowCondition).Name(): func() redtape.Condition {
-			return new(IPAllowCondition)
-		},
-		new(IPDenyCondition).Name(): func() redtape.Condition {
-			return new(IPDenyCondition)
-		},
-	})
+	reg := redtape.NewConditionRegistry(Builders())
 
 	type args struct {
 		opts []redtape.ConditionOptions
@@ -65,3 +61,204 @@ func TestIPConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestIPConditionValidation(t *testing.T) {
+	c := &IPAllowCondition{Networks: []string{"10.0.0.0/8", "10.0.0.300/8", "office"}}
+
+	v := redtape.NewValidation()
+	c.Validate(v.Field("options"))
+
+	errs := v.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("Validate() errors = %v, want 2", errs)
+	}
+
+	if errs[0].Field != "options.networks[1]" || errs[1].Field != "options.networks[2]" {
+		t.Errorf("Validate() fields = %s, %s", errs[0].Field, errs[1].Field)
+	}
+}
+
+func TestIPConditionEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cond    redtape.ConditionEvaluator
+		val     interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"allow match", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, "10.1.2.3", true, false},
+		{"allow no match", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, "192.168.1.1", false, false},
+		{"allow missing", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, nil, false, false},
+		{"allow invalid address", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, "10.1.2", false, true},
+		{"allow wrong type", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, 10, false, true},
+		{"deny match", &IPDenyCondition{Networks: []string{"10.0.0.0/8"}}, "10.1.2.3", false, false},
+		{"deny no match", &IPDenyCondition{Networks: []string{"10.0.0.0/8"}}, "192.168.1.1", true, false},
+		{"deny missing", &IPDenyCondition{Networks: []string{"10.0.0.0/8"}}, nil, false, true},
+		{"deny malformed network", &IPDenyCondition{Networks: []string{"10.0.0.0/33"}}, "192.168.1.1", false, true},
+		{"allow ipv6", &IPAllowCondition{Networks: []string{"2001:db8::/32"}}, "2001:db8::1", true, false},
+		{"allow ipv6 no match", &IPAllowCondition{Networks: []string{"2001:db8::/32"}}, "2001:db9::1", false, false},
+		{"allow single address", &IPAllowCondition{Networks: []string{"10.1.2.3"}}, "10.1.2.3", true, false},
+		{"allow single address no match", &IPAllowCondition{Networks: []string{"10.1.2.3"}}, "10.1.2.4", false, false},
+		{"allow mapped ipv4", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, "::ffff:10.1.2.3", true, false},
+		{"allow net.IP", &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}, net.ParseIP("10.1.2.3"), true, false},
+		{"allow named range", &IPAllowCondition{Ranges: []string{"private"}}, "fd00::1", true, false},
+		{"allow unknown range", &IPAllowCondition{Ranges: []string{"unregistered"}}, "10.1.2.3", false, true},
+		{
+			"allow chain without trusted proxies",
+			&IPAllowCondition{Networks: []string{"10.0.0.0/8"}},
+			"10.1.2.3, 203.0.113.9",
+			false, false,
+		},
+		{
+			"allow chain through trusted proxy",
+			&IPAllowCondition{Networks: []string{"10.0.0.0/8"}, TrustedProxies: []string{"203.0.113.0/24"}},
+			"10.1.2.3, 203.0.113.9",
+			true, false,
+		},
+		{
+			"allow list through trusted proxy",
+			&IPAllowCondition{Networks: []string{"10.0.0.0/8"}, TrustedProxies: []string{"203.0.113.0/24"}},
+			[]string{"10.1.2.3", "203.0.113.9"},
+			true, false,
+		},
+		{
+			"deny spoofed chain",
+			&IPDenyCondition{Networks: []string{"198.51.100.0/24"}, TrustedProxies: []string{"203.0.113.0/24"}},
+			[]interface{}{"10.1.2.3", "198.51.100.7", "203.0.113.9"},
+			false, false,
+		},
+		{
+			"deny invalid hop",
+			&IPDenyCondition{Networks: []string{"198.51.100.0/24"}, TrustedProxies: []string{"203.0.113.0/24"}},
+			"10.1.2.3, garbage, 203.0.113.9",
+			false, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.cond.Evaluate(tt.val, nil)
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+
+			if res.Err == nil && res.Met != tt.want {
+				t.Errorf("Evaluate() met = %v, want %v (%s)", res.Met, tt.want, res.Reason)
+			}
+
+			if res.Err == nil && res.Reason == "" {
+				t.Error("Evaluate() gave no reason")
+			}
+
+			if met := tt.cond.(redtape.Condition).Meets(tt.val, nil); met != (tt.want && !tt.wantErr) {
+				t.Errorf("Meets() = %v", met)
+			}
+		})
+	}
+}
+
+func TestIPConditionInit(t *testing.T) {
+	c := &IPAllowCondition{Networks: []string{"10.0.0.0/8", "10.0.0.300/8", "2001:db8::/129"}}
+
+	err := c.Init(nil)
+	if err == nil {
+		t.Fatal("Init() error = nil")
+	}
+
+	for _, want := range []string{"10.0.0.300/8", "2001:db8::/129"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Init() error = %v, want it to name %s", err, want)
+		}
+	}
+
+	c = &IPAllowCondition{Networks: []string{"10.0.0.0/8"}}
+	if err := c.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Networks = []string{"invalid"}
+	if res := c.Evaluate("10.1.2.3", nil); res.Err != nil || !res.Met {
+		t.Errorf("Evaluate() = %+v, want the networks parsed by Init", res)
+	}
+}
+
+// unregisterIPRanges removes the named ranges registered by a test once it completes.
+func unregisterIPRanges(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		namedMu.Lock()
+		defer namedMu.Unlock()
+
+		for _, n := range names {
+			delete(namedRanges, n)
+		}
+	})
+}
+
+func TestRegisterIPRanges(t *testing.T) {
+	unregisterIPRanges(t, "test_office", "test_broken")
+
+	if err := RegisterIPRanges("test_office", "192.0.2.0/24", "2001:db8:1::/48"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterIPRanges("test_broken", "192.0.2.0/33"); err == nil {
+		t.Error("RegisterIPRanges() error = nil")
+	}
+
+	c := &IPDenyCondition{Ranges: []string{"test_office", "loopback"}}
+	if err := c.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for val, want := range map[string]bool{
+		"192.0.2.10":      false,
+		"2001:db8:1::abc": false,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"198.51.100.1":    true,
+	} {
+		if res := c.Evaluate(val, nil); res.Err != nil || res.Met != want {
+			t.Errorf("Evaluate(%s) = %+v, want %v", val, res, want)
+		}
+	}
+}
+
+func TestRequestClientIP(t *testing.T) {
+	trusted := MustParseIPRanges("10.0.0.0/8")
+
+	tests := []struct {
+		name    string
+		remote  string
+		xff     []string
+		want    string
+		wantErr bool
+	}{
+		{"remote only", "198.51.100.1:4321", nil, "198.51.100.1", false},
+		{"untrusted remote ignores header", "198.51.100.1:4321", []string{"192.0.2.1"}, "198.51.100.1", false},
+		{"trusted remote", "10.0.0.1:4321", []string{"192.0.2.1"}, "192.0.2.1", false},
+		{"trusted chain", "10.0.0.1:4321", []string{"192.0.2.1, 10.0.0.2"}, "192.0.2.1", false},
+		{"spoofed entries", "10.0.0.1:4321", []string{"10.9.9.9, 192.0.2.1"}, "192.0.2.1", false},
+		{"multiple headers", "10.0.0.1:4321", []string{"192.0.2.1", "10.0.0.2"}, "192.0.2.1", false},
+		{"all trusted", "10.0.0.1:4321", []string{"10.0.0.3"}, "10.0.0.3", false},
+		{"ipv6 remote", "[2001:db8::1]:443", nil, "2001:db8::1", false},
+		{"invalid forwarded", "10.0.0.1:4321", []string{"unknown"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+
+			for _, h := range tt.xff {
+				r.Header.Add("X-Forwarded-For", h)
+			}
+
+			ip, err := RequestClientIP(r, trusted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestClientIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && ip.String() != tt.want {
+				t.Errorf("RequestClientIP() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
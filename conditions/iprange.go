@@ -0,0 +1,181 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ipNetwork is a parsed network along with the entry it was parsed from.
+type ipNetwork struct {
+	*net.IPNet
+	src string
+}
+
+// IPRanges is a parsed list of IPv4 and IPv6 networks. The zero value contains no addresses.
+type IPRanges struct {
+	nets []ipNetwork
+}
+
+// ParseIPRanges parses entries in CIDR notation, such as 10.0.0.0/8 or 2001:db8::/32, or single addresses,
+// which match only themselves. Every invalid entry is reported in the returned error.
+func ParseIPRanges(entries ...string) (*IPRanges, error) {
+	r := &IPRanges{nets: make([]ipNetwork, 0, len(entries))}
+
+	var bad []string
+
+	for _, e := range entries {
+		n, err := parseNetwork(e)
+		if err != nil {
+			bad = append(bad, err.Error())
+			continue
+		}
+
+		r.nets = append(r.nets, ipNetwork{IPNet: n, src: e})
+	}
+
+	if len(bad) > 0 {
+		return nil, errors.New(strings.Join(bad, "; "))
+	}
+
+	return r, nil
+}
+
+// MustParseIPRanges is like ParseIPRanges but panics on invalid entries.
+func MustParseIPRanges(entries ...string) *IPRanges {
+	r, err := ParseIPRanges(entries...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func parseNetwork(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+
+		return n, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Contains reports whether ip is within one of the ranges.
+func (r *IPRanges) Contains(ip net.IP) bool {
+	_, ok := r.Match(ip)
+	return ok
+}
+
+// Match returns the entry of the first range containing ip.
+func (r *IPRanges) Match(ip net.IP) (string, bool) {
+	if r == nil || ip == nil {
+		return "", false
+	}
+
+	for _, n := range r.nets {
+		if n.Contains(ip) {
+			return n.src, true
+		}
+	}
+
+	return "", false
+}
+
+// Len returns the number of ranges.
+func (r *IPRanges) Len() int {
+	if r == nil {
+		return 0
+	}
+
+	return len(r.nets)
+}
+
+// merge returns the ranges of r followed by those of others.
+func (r *IPRanges) merge(others ...*IPRanges) *IPRanges {
+	m := &IPRanges{}
+	for _, o := range append([]*IPRanges{r}, others...) {
+		if o != nil {
+			m.nets = append(m.nets, o.nets...)
+		}
+	}
+
+	return m
+}
+
+var (
+	namedMu     sync.RWMutex
+	namedRanges = map[string]*IPRanges{
+		"loopback":   MustParseIPRanges("127.0.0.0/8", "::1/128"),
+		"private":    MustParseIPRanges("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"),
+		"link_local": MustParseIPRanges("169.254.0.0/16", "fe80::/10"),
+	}
+)
+
+// RegisterIPRanges adds a named list of ranges that IP conditions can refer to through their ranges option,
+// replacing any list registered under the same name. The lists loopback, private and link_local are
+// registered by default.
+func RegisterIPRanges(name string, entries ...string) error {
+	r, err := ParseIPRanges(entries...)
+	if err != nil {
+		return fmt.Errorf("ip ranges %s: %w", name, err)
+	}
+
+	namedMu.Lock()
+	defer namedMu.Unlock()
+
+	namedRanges[name] = r
+
+	return nil
+}
+
+// NamedIPRanges returns the ranges registered under name.
+func NamedIPRanges(name string) (*IPRanges, bool) {
+	namedMu.RLock()
+	defer namedMu.RUnlock()
+
+	r, ok := namedRanges[name]
+
+	return r, ok
+}
+
+func lookupIPRanges(names []string) (*IPRanges, error) {
+	var (
+		rs      []*IPRanges
+		missing []string
+	)
+
+	for _, n := range names {
+		r, ok := NamedIPRanges(n)
+		if !ok {
+			missing = append(missing, n)
+			continue
+		}
+
+		rs = append(rs, r)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unknown ip ranges %s", strings.Join(missing, ", "))
+	}
+
+	return (&IPRanges{}).merge(rs...), nil
+}
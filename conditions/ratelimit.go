@@ -0,0 +1,341 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// Rate limiting modes of RateLimitCondition.
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimit describes a limit of Limit requests per Window. In RateLimitTokenBucket mode a bucket of Limit
+// tokens is refilled evenly over Window, allowing bursts of up to Limit requests. In RateLimitSlidingWindow
+// mode at most Limit requests are allowed within any period of Window.
+type RateLimit struct {
+	Mode   string
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitStore keeps the state of rate limits. Take records a request for key at now when the limit allows
+// it, reporting whether it did and how many requests remain. Implementations must be safe for concurrent use
+// and apply Take atomically.
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) (allowed bool, remaining int, err error)
+}
+
+// rateEntry is the state of one key of the memory store: the tokens of a bucket or the request times of a
+// sliding window, oldest first.
+type rateEntry struct {
+	tokens float64
+	last   time.Time
+	hits   []time.Time
+	window time.Duration
+}
+
+// memoryRateLimitStore is a RateLimitStore held in memory.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateEntry
+	takes   int
+}
+
+// sweepEvery is the number of takes between removals of idle entries from a memory store.
+const sweepEvery = 1024
+
+// NewMemoryRateLimitStore returns a RateLimitStore held in memory, suitable for a single process.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		entries: make(map[string]*rateEntry),
+	}
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (bool, int, error) {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		return false, 0, errors.New("limit and window must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateEntry{tokens: float64(limit.Limit), last: now}
+		s.entries[key] = e
+	}
+
+	e.window = limit.Window
+
+	switch limit.Mode {
+	case RateLimitSlidingWindow:
+		return e.slide(limit, now)
+	case RateLimitTokenBucket, "":
+		return e.bucket(limit, now)
+	}
+
+	return false, 0, fmt.Errorf("unknown rate limit mode %q", limit.Mode)
+}
+
+func (e *rateEntry) bucket(limit RateLimit, now time.Time) (bool, int, error) {
+	if elapsed := now.Sub(e.last); elapsed > 0 {
+		e.tokens += elapsed.Seconds() * float64(limit.Limit) / limit.Window.Seconds()
+		if e.tokens > float64(limit.Limit) {
+			e.tokens = float64(limit.Limit)
+		}
+
+		e.last = now
+	}
+
+	if e.tokens < 1 {
+		return false, 0, nil
+	}
+
+	e.tokens--
+
+	return true, int(e.tokens), nil
+}
+
+func (e *rateEntry) slide(limit RateLimit, now time.Time) (bool, int, error) {
+	e.expire(now)
+
+	if len(e.hits) >= limit.Limit {
+		return false, 0, nil
+	}
+
+	e.hits = append(e.hits, now)
+	e.last = now
+
+	return true, limit.Limit - len(e.hits), nil
+}
+
+// expire drops the hits that left the window.
+func (e *rateEntry) expire(now time.Time) {
+	start := now.Add(-e.window)
+
+	i := 0
+	for i < len(e.hits) && !e.hits[i].After(start) {
+		i++
+	}
+
+	e.hits = e.hits[i:]
+}
+
+// sweep removes entries untouched for a whole window, whose state is the same as a new entry.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	for k, e := range s.entries {
+		if now.Sub(e.last) >= e.window {
+			delete(s.entries, k)
+		}
+	}
+}
+
+var (
+	storeMu    sync.RWMutex
+	rateStores = map[string]RateLimitStore{
+		"memory": NewMemoryRateLimitStore(),
+	}
+)
+
+// RegisterRateLimitStore makes store available to rate_limit conditions under name. The store "memory", a
+// store returned by NewMemoryRateLimitStore, is used when a condition names none.
+func RegisterRateLimitStore(name string, store RateLimitStore) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	rateStores[name] = store
+}
+
+func rateLimitStore(name string) (RateLimitStore, bool) {
+	if name == "" {
+		name = "memory"
+	}
+
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+
+	s, ok := rateStores[name]
+
+	return s, ok
+}
+
+// rateKeyFields are the parts of a rate limit key read from the request rather than its attributes.
+var rateKeyFields = map[string]func(*redtape.Request) string{
+	"role":     func(r *redtape.Request) string { return r.Role },
+	"resource": func(r *redtape.Request) string { return r.Resource },
+	"action":   func(r *redtape.Request) string { return r.Action },
+	"scope":    func(r *redtape.Request) string { return r.Scope },
+}
+
+// RateLimitCondition limits how often requests are allowed, for example "role support may call export at most
+// 10 times per hour". Requests are counted per key, built from the parts in Key: role, resource, action and
+// scope name fields of the request. Any other part, such as subject, names a request attribute held in the
+// metadata or supplied by an attribute provider, since requests carry no subject of their own. Mode is
+// token_bucket, the default, or sliding_window, and Window a duration such as 1h.
+//
+// Counts are kept in the store registered under Store, in memory by default. Conditions share the counts of a
+// key when they have the same Namespace, which defaults to their mode, limit, window and key parts.
+//
+// Every evaluation that is allowed counts against the limit. The enforcer evaluates the condition after the
+// other conditions of its policy and only when they are all met, so requests they deny are not counted.
+type RateLimitCondition struct {
+	Mode      string   `json:"mode"`
+	Limit     int      `json:"limit"`
+	Window    string   `json:"window"`
+	Key       []string `json:"key"`
+	Store     string   `json:"store,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	limit     RateLimit
+	store     RateLimitStore
+	ns        string
+}
+
+// Name fulfills the Name method of Condition.
+func (c *RateLimitCondition) Name() string {
+	return "rate_limit"
+}
+
+// ConsumesOnEvaluation fulfills redtape.ConsumingCondition.
+func (c *RateLimitCondition) ConsumesOnEvaluation() {}
+
+// Init fulfills redtape.ConditionInitializer by parsing the limit and finding the store.
+func (c *RateLimitCondition) Init(_ redtape.ConditionRegistry) error {
+	mode := c.Mode
+	if mode == "" {
+		mode = RateLimitTokenBucket
+	}
+
+	if mode != RateLimitTokenBucket && mode != RateLimitSlidingWindow {
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+
+	if c.Limit <= 0 {
+		return fmt.Errorf("limit must be positive, got %d", c.Limit)
+	}
+
+	window, err := time.ParseDuration(c.Window)
+	if err != nil || window <= 0 {
+		return fmt.Errorf("invalid window %q", c.Window)
+	}
+
+	store, ok := rateLimitStore(c.Store)
+	if !ok {
+		return fmt.Errorf("unknown store %q", c.Store)
+	}
+
+	c.limit = RateLimit{Mode: mode, Limit: c.Limit, Window: window}
+	c.store = store
+
+	c.ns = c.Namespace
+	if c.ns == "" {
+		c.ns = fmt.Sprintf("%s/%d/%s/%s", mode, c.Limit, window, strings.Join(c.Key, ","))
+	}
+
+	return nil
+}
+
+// Validate fulfills redtape.ConditionValidator.
+func (c *RateLimitCondition) Validate(v *redtape.Validation) {
+	if c.Mode != "" && c.Mode != RateLimitTokenBucket && c.Mode != RateLimitSlidingWindow {
+		v.Errorf("mode", "unknown mode %q, expected %s or %s", c.Mode, RateLimitTokenBucket, RateLimitSlidingWindow)
+	}
+
+	if c.Limit <= 0 {
+		v.Errorf("limit", "must be positive")
+	}
+
+	if w, err := time.ParseDuration(c.Window); err != nil || w <= 0 {
+		v.Errorf("window", "invalid duration %q", c.Window)
+	}
+
+	if len(c.Key) == 0 {
+		v.Warnf("key", "no key given, all requests share one limit")
+	}
+
+	for i, k := range c.Key {
+		if k == "" {
+			v.Errorf(fmt.Sprintf("key[%d]", i), "is empty")
+		}
+	}
+
+	if _, ok := rateLimitStore(c.Store); !ok {
+		v.Errorf("store", "unknown store %q", c.Store)
+	}
+}
+
+// Meets evaluates true when the request is within the limit of its key, counting it against the limit.
+func (c *RateLimitCondition) Meets(val interface{}, r *redtape.Request) bool {
+	res := c.Evaluate(val, r)
+
+	return res.Err == nil && res.Met
+}
+
+// Evaluate fulfills redtape.ConditionEvaluator. The val parameter is not used. A missing key part and a
+// failing store are errors.
+func (c *RateLimitCondition) Evaluate(_ interface{}, r *redtape.Request) redtape.ConditionResult {
+	if c.store == nil {
+		return redtape.ConditionResult{Err: errors.New("condition is not initialized")}
+	}
+
+	key, err := c.key(r)
+	if err != nil {
+		return redtape.ConditionResult{Err: err}
+	}
+
+	ok, remaining, err := c.store.Take(key, c.limit, redtape.RequestTime(r))
+	if err != nil {
+		return redtape.ConditionResult{Err: fmt.Errorf("rate limit store: %w", err)}
+	}
+
+	if !ok {
+		return redtape.ConditionResult{Reason: fmt.Sprintf("rate limit of %d per %s exceeded", c.limit.Limit, c.limit.Window)}
+	}
+
+	return redtape.ConditionResult{
+		Met:    true,
+		Reason: fmt.Sprintf("within rate limit of %d per %s, %d remaining", c.limit.Limit, c.limit.Window, remaining),
+	}
+}
+
+// key returns the store key of r, quoting each part so that parts containing separators cannot collide.
+func (c *RateLimitCondition) key(r *redtape.Request) (string, error) {
+	if r == nil {
+		return "", errors.New("no request")
+	}
+
+	parts := make([]string, 0, len(c.Key)+1)
+	parts = append(parts, strconv.Quote(c.ns))
+
+	for _, k := range c.Key {
+		if f, ok := rateKeyFields[k]; ok {
+			parts = append(parts, strconv.Quote(f(r)))
+			continue
+		}
+
+		v, ok, err := redtape.RequestAttribute(r, k)
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", k, err)
+		}
+
+		if !ok || v == nil {
+			return "", fmt.Errorf("key %s is not set", k)
+		}
+
+		parts = append(parts, strconv.Quote(fmt.Sprint(v)))
+	}
+
+	return strings.Join(parts, ":"), nil
+}
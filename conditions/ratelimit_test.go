@@ -0,0 +1,258 @@
+package conditions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rateClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *rateClock) time() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *rateClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func rateRequest(clock *rateClock, role string, meta map[string]interface{}) *redtape.Request {
+	ctx := redtape.NewRequestClockContext(context.Background(), clock.time)
+	return redtape.NewRequestWithContext(ctx, "reports", "export", role, "", meta)
+}
+
+func newRateLimit(t *testing.T, opts map[string]interface{}) *RateLimitCondition {
+	t.Helper()
+
+	conds, err := redtape.NewConditions([]redtape.ConditionOptions{
+		{Name: "limit", Type: "rate_limit", Options: opts},
+	}, redtape.NewConditionRegistry(Builders()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conds["limit"].(*RateLimitCondition)
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	RegisterRateLimitStore("bucket_test", NewMemoryRateLimitStore())
+
+	c := newRateLimit(t, map[string]interface{}{
+		"limit":  10,
+		"window": "1h",
+		"key":    []string{"role"},
+		"store":  "bucket_test",
+	})
+
+	clock := &rateClock{now: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)}
+
+	for i := 0; i < 10; i++ {
+		if !c.Meets(nil, rateRequest(clock, "support", nil)) {
+			t.Fatalf("request %d denied within the burst", i)
+		}
+	}
+
+	if c.Meets(nil, rateRequest(clock, "support", nil)) {
+		t.Fatal("request allowed past the limit")
+	}
+
+	if !c.Meets(nil, rateRequest(clock, "admin", nil)) {
+		t.Fatal("other role shares the limit")
+	}
+
+	// one token is refilled every six minutes
+	clock.advance(5 * time.Minute)
+	if c.Meets(nil, rateRequest(clock, "support", nil)) {
+		t.Fatal("request allowed before a token was refilled")
+	}
+
+	clock.advance(time.Minute)
+	if !c.Meets(nil, rateRequest(clock, "support", nil)) {
+		t.Fatal("request denied after a token was refilled")
+	}
+
+	if c.Meets(nil, rateRequest(clock, "support", nil)) {
+		t.Fatal("refill allowed more than one request")
+	}
+
+	clock.advance(24 * time.Hour)
+	for i := 0; i < 10; i++ {
+		if !c.Meets(nil, rateRequest(clock, "support", nil)) {
+			t.Fatalf("request %d denied after a full refill", i)
+		}
+	}
+
+	if c.Meets(nil, rateRequest(clock, "support", nil)) {
+		t.Fatal("bucket refilled past its capacity")
+	}
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	RegisterRateLimitStore("window_test", NewMemoryRateLimitStore())
+
+	c := newRateLimit(t, map[string]interface{}{
+		"mode":   "sliding_window",
+		"limit":  3,
+		"window": "1m",
+		"key":    []string{"role", "subject"},
+		"store":  "window_test",
+	})
+
+	clock := &rateClock{now: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)}
+	alice := map[string]interface{}{"subject": "alice"}
+
+	for i := 0; i < 3; i++ {
+		if !c.Meets(nil, rateRequest(clock, "support", alice)) {
+			t.Fatalf("request %d denied", i)
+		}
+
+		clock.advance(20 * time.Second)
+	}
+
+	// the first request is exactly one window old and no longer counts
+	if !c.Meets(nil, rateRequest(clock, "support", alice)) {
+		t.Fatal("request denied once the oldest left the window")
+	}
+
+	if c.Meets(nil, rateRequest(clock, "support", alice)) {
+		t.Fatal("request allowed past the limit")
+	}
+
+	if !c.Meets(nil, rateRequest(clock, "support", map[string]interface{}{"subject": "bob"})) {
+		t.Fatal("other subject shares the limit")
+	}
+
+	res := c.Evaluate(nil, rateRequest(clock, "support", nil))
+	if res.Err == nil {
+		t.Error("Evaluate() without the subject attribute error = nil")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(string, RateLimit, time.Time) (bool, int, error) {
+	return false, 0, errors.New("unavailable")
+}
+
+func TestRateLimitStoreErrors(t *testing.T) {
+	RegisterRateLimitStore("failing_test", failingStore{})
+
+	c := newRateLimit(t, map[string]interface{}{
+		"limit":  1,
+		"window": "1s",
+		"store":  "failing_test",
+	})
+
+	clock := &rateClock{now: time.Now()}
+	if res := c.Evaluate(nil, rateRequest(clock, "support", nil)); res.Err == nil {
+		t.Error("Evaluate() with a failing store error = nil")
+	}
+
+	for name, opts := range map[string]map[string]interface{}{
+		"mode":   {"mode": "leaky", "limit": 1, "window": "1s"},
+		"limit":  {"limit": 0, "window": "1s"},
+		"window": {"limit": 1, "window": "soon"},
+		"store":  {"limit": 1, "window": "1s", "store": "missing"},
+	} {
+		_, err := redtape.NewConditions([]redtape.ConditionOptions{
+			{Name: "limit", Type: "rate_limit", Options: opts},
+		}, redtape.NewConditionRegistry(Builders()))
+		if err == nil {
+			t.Errorf("%s: NewConditions() error = nil", name)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreConcurrency(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	now := time.Now()
+
+	for _, mode := range []string{RateLimitTokenBucket, RateLimitSlidingWindow} {
+		limit := RateLimit{Mode: mode, Limit: 50, Window: time.Hour}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 10; j++ {
+					ok, _, err := s.Take(mode, limit, now)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					if ok {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if allowed != limit.Limit {
+			t.Errorf("%s allowed %d requests, want %d", mode, allowed, limit.Limit)
+		}
+	}
+}
+
+func TestRateLimitEvaluatedLast(t *testing.T) {
+	RegisterRateLimitStore("order_test", NewMemoryRateLimitStore())
+
+	pm := redtape.NewManager()
+	require.NoError(t, pm.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("exports"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("support")),
+		redtape.SetActions("export"),
+		redtape.SetConditionRegistry(redtape.SharedConditionRegistry()),
+		// named to sort before the condition it must wait for
+		redtape.WithCondition(redtape.ConditionOptions{Name: "a_limit", Type: "rate_limit", Options: map[string]interface{}{
+			"limit": 2, "window": "1h", "key": []string{"role"}, "store": "order_test",
+		}}),
+		redtape.WithCondition(redtape.ConditionOptions{Name: "approved", Type: "bool", Options: map[string]interface{}{
+			"value": true,
+		}}),
+	)))
+
+	e, err := redtape.NewEnforcer(pm, redtape.NewMatcher(), redtape.NewConsoleAuditor(redtape.AuditNone))
+	require.NoError(t, err)
+
+	clock := &rateClock{now: time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)}
+
+	for i := 0; i < 5; i++ {
+		assert.Error(t, e.Enforce(rateRequest(clock, "support", map[string]interface{}{"approved": false})))
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, e.Enforce(rateRequest(clock, "support", map[string]interface{}{"approved": true})),
+			"denied requests should not have used up the limit")
+	}
+
+	assert.Error(t, e.Enforce(rateRequest(clock, "support", map[string]interface{}{"approved": true})))
+}
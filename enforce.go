@@ -1,7 +1,10 @@
 package redtape
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 // Enforcer interface provides methods to enforce policies against a request.
@@ -13,59 +16,137 @@ type enforcer struct {
 	manager PolicyManager
 	matcher Matcher
 	auditor Auditor
+	options EnforcerOptions
 }
 
-// NewEnforcer returns a default Enforcer combining a PolicyManager, Matcher, and Auditor.
-func NewEnforcer(manager PolicyManager, matcher Matcher, auditor Auditor) (Enforcer, error) {
+// NewEnforcer returns a default Enforcer combining a PolicyManager, Matcher, and Auditor. A nil Matcher is
+// replaced by the one set with EnforcerMatcher, or the DefaultMatcher.
+func NewEnforcer(manager PolicyManager, matcher Matcher, auditor Auditor, opts ...EnforcerOption) (Enforcer, error) {
+	options := NewEnforcerOptions(opts...)
+
+	if matcher == nil {
+		matcher = options.Matcher
+	}
+
+	if matcher == nil {
+		matcher = DefaultMatcher
+	}
+
 	return &enforcer{
 		manager: manager,
 		matcher: matcher,
 		auditor: auditor,
+		options: options,
 	}, nil
 }
 
-func NewDefaultEnforcer(manager PolicyManager) (Enforcer, error) {
-	return NewEnforcer(manager, DefaultMatcher, NewConsoleAuditor(AuditAll))
+// NewDefaultEnforcer returns an Enforcer using a console Auditor and the DefaultMatcher, unless another is set
+// with EnforcerMatcher.
+func NewDefaultEnforcer(manager PolicyManager, opts ...EnforcerOption) (Enforcer, error) {
+	return NewEnforcer(manager, nil, NewConsoleAuditor(AuditAll), opts...)
+}
+
+// EnforcerOptions holds optional collaborators of the default Enforcer.
+type EnforcerOptions struct {
+	Matcher            Matcher
+	RoleManager        RoleManager
+	Clock              Clock
+	AttributeProviders []AttributeProvider
+	AttributeTimeout   time.Duration
+}
+
+// EnforcerOption is a typed function allowing updates to EnforcerOptions through functional options.
+type EnforcerOption func(*EnforcerOptions)
+
+// NewEnforcerOptions returns EnforcerOptions configured with the provided functional options.
+func NewEnforcerOptions(opts ...EnforcerOption) EnforcerOptions {
+	options := EnforcerOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// EnforcerMatcher sets the Matcher used to match the actions, resources, roles and scopes of policies, such as
+// NewGlobMatcher or NewRegexMatcher.
+func EnforcerMatcher(m Matcher) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Matcher = m
+	}
+}
+
+// EnforcerRoleManager sets the RoleManager used to resolve the role references of policies at evaluation time.
+func EnforcerRoleManager(rm RoleManager) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.RoleManager = rm
+	}
+}
+
+// EnforcerClock sets the Clock time based conditions read the current time from.
+func EnforcerClock(clock Clock) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Clock = clock
+	}
+}
+
+// EnforcerAttributeProvider adds AttributeProviders consulted, in order, for attributes missing from the
+// request metadata.
+func EnforcerAttributeProvider(providers ...AttributeProvider) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.AttributeProviders = append(o.AttributeProviders, providers...)
+	}
+}
+
+// EnforcerAttributeTimeout bounds the time each call to an AttributeProvider may take.
+func EnforcerAttributeTimeout(d time.Duration) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.AttributeTimeout = d
+	}
 }
 
 // Enforce fulfills the Enforce method of Enforcer. The default implementation matches the Request against
-// the range of stored Policies and evaluating each.
+// the range of stored Policies and PolicySets and evaluating each.
 // Polices are matched first by Action, then Role, Resource, Scope and finally Condition. If a match is found, the
-// configured Policy Effect is applied.
+// configured Policy Effect is applied. PolicySets are only evaluated when their Target matches the Request and
+// combine the effects of their members with their own CombiningAlgorithm.
+// At the top level, a deny from any policy or set overrides all allows. A policy whose templates could not be
+// expanded or whose conditions could not be evaluated is indeterminate. An indeterminate deny policy denies the request with an error, while an
+// indeterminate allow policy only does so when no other policy allows or denies the request.
 // TODO: return explicit PolicyEffect and use error to indicate processing failures
 func (e *enforcer) Enforce(r *Request) error {
-	allow := false
-	matched := []Policy{}
-
 	e.auditReq(r)
 
+	r = e.prepareRequest(r)
+
 	pol, err := e.manager.FindByRequest(r)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range pol {
-		match, err := e.evalPolicy(r, p)
-		if err != nil {
-			return err
-		}
-
-		if !match {
-			continue
-		}
+	sets, err := e.manager.FindSetsByRequest(r)
+	if err != nil {
+		return err
+	}
 
-		matched = append(matched, p)
+	tr := e.newTrace()
 
-		// deny overrides all
-		if p.Effect() == PolicyEffectDeny {
-			e.auditEffect(r, PolicyEffectDeny)
-			return NewErrRequestDeniedExplicit(p)
-		}
+	ev, err := e.evalMembers(r, DenyOverrides, pol, sets, 0, tr)
+	e.auditConditions(r, tr)
 
-		allow = true
+	if err != nil {
+		return err
 	}
 
-	if !allow && DefaultPolicyEffect == PolicyEffectDeny {
+	switch {
+	case ev.decision == decisionIndeterminate:
+		e.auditEffect(r, PolicyEffectDeny)
+		return NewErrRequestIndeterminate(ev.policy, ev.err)
+	case ev.decision == decisionDeny:
+		e.auditEffect(r, PolicyEffectDeny)
+		return NewErrRequestDeniedExplicit(ev.policy)
+	case ev.decision != decisionAllow && DefaultPolicyEffect == PolicyEffectDeny:
 		e.auditEffect(r, PolicyEffectDeny)
 		return NewErrRequestDeniedImplicit(errors.New("access denied because no policy allowed access"))
 	}
@@ -75,34 +156,151 @@ func (e *enforcer) Enforce(r *Request) error {
 	return nil
 }
 
-func (e *enforcer) checkConditions(p Policy, r *Request) bool {
-	for key, cond := range p.Conditions() {
-		meta := RequestMetadataFromContext(r.Context)
-		if pass := cond.Meets(meta[key], r); !pass {
-			return false
+// evalMembers evaluates policies followed by sets in order, reducing their decisions with alg.
+func (e *enforcer) evalMembers(
+	r *Request, alg CombiningAlgorithm, pol []Policy, sets []PolicySet, depth int, tr *conditionTrace,
+) (evaluation, error) {
+	return combine(alg, len(pol)+len(sets), func(i int) (evaluation, error) {
+		if i < len(pol) {
+			return e.evalPolicyEffect(r, pol[i], tr)
+		}
+
+		return e.evalSet(r, sets[i-len(pol)], depth+1, tr)
+	})
+}
+
+func (e *enforcer) evalPolicyEffect(r *Request, p Policy, tr *conditionTrace) (evaluation, error) {
+	r, match, err := e.evalPolicy(r, p)
+
+	var te *templateError
+	if errors.As(err, &te) {
+		return indeterminateEvaluation(p, err), nil
+	}
+
+	if err != nil || !match {
+		return evaluation{}, err
+	}
+
+	met, err := e.checkConditions(p, r, tr)
+	if err != nil {
+		return indeterminateEvaluation(p, err), nil
+	}
+
+	if !met {
+		return evaluation{}, nil
+	}
+
+	if p.Effect() == PolicyEffectAllow {
+		return evaluation{decision: decisionAllow, policy: p}, nil
+	}
+
+	return evaluation{decision: decisionDeny, policy: p}, nil
+}
+
+func (e *enforcer) evalSet(r *Request, s PolicySet, depth int, tr *conditionTrace) (evaluation, error) {
+	if depth > maxIterDepth {
+		return evaluation{}, fmt.Errorf("policy set %s exceeds maximum nesting depth", s.ID())
+	}
+
+	match, err := e.matchTarget(s.Target(), r)
+	if err != nil || !match {
+		return evaluation{}, err
+	}
+
+	return e.evalMembers(r, s.Algorithm(), s.Policies(), s.Sets(), depth, tr)
+}
+
+func (e *enforcer) matchTarget(t Target, r *Request) (bool, error) {
+	for _, m := range []struct {
+		def []string
+		val string
+	}{
+		{t.Actions, r.Action},
+		{t.Resources, r.Resource},
+		{t.Scopes, r.Scope},
+	} {
+		if len(m.def) == 0 {
+			continue
+		}
+
+		match, err := e.matcher.MatchPolicy(nil, m.def, m.val)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// checkConditions evaluates the conditions of p in name order, so that attribute lookups are reproducible. A
+// condition that is not met decides the outcome on its own; otherwise the first condition that failed to
+// evaluate is returned as an error. Conditions consuming state, such as rate limits, are only evaluated once
+// all the others are met.
+func (e *enforcer) checkConditions(p Policy, r *Request, tr *conditionTrace) (bool, error) {
+	conds := p.Conditions()
+
+	var plain, consuming []string
+
+	for _, key := range conds.Names() {
+		if isConsumingCondition(conds[key]) {
+			consuming = append(consuming, key)
+		} else {
+			plain = append(plain, key)
+		}
+	}
+
+	met, err := e.checkConditionKeys(p, conds, plain, r, tr)
+	if err != nil || !met {
+		return false, err
+	}
+
+	return e.checkConditionKeys(p, conds, consuming, r, tr)
+}
+
+func (e *enforcer) checkConditionKeys(p Policy, conds Conditions, keys []string, r *Request, tr *conditionTrace) (bool, error) {
+	var cerr error
+
+	for _, key := range keys {
+		res := evaluateCondition(key, conds[key], r)
+		tr.add(p, key, res)
+
+		switch {
+		case res.Err != nil:
+			if cerr == nil {
+				cerr = fmt.Errorf("policy %s: condition %s: %w", p.ID(), key, res.Err)
+			}
+		case !res.Met:
+			return false, nil
 		}
 	}
 
-	return true
+	return cerr == nil, cerr
 }
 
-func (e *enforcer) evalPolicy(r *Request, p Policy) (bool, error) {
+// evalPolicy matches r against p, returning the request its conditions are evaluated with. That request carries
+// the captures of the matches, which templates in the later patterns of p may refer to.
+func (e *enforcer) evalPolicy(r *Request, p Policy) (*Request, bool, error) {
 	// match actions
-	am, err := e.matcher.MatchPolicy(p, p.Actions(), r.Action)
+	r, am, err := e.matchField(r, p, "action", p.Actions(), r.Action)
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 
 	if !am {
-		return false, nil
+		return nil, false, nil
+	}
+
+	roles, err := e.policyRoles(p)
+	if err != nil {
+		return nil, false, err
 	}
 
 	rm := false
 	// match roles
-	for _, role := range p.Roles() {
+	for _, role := range roles {
 		b, err := e.matcher.MatchRole(role, r.Role)
 		if err != nil {
-			return false, err
+			return nil, false, err
 		}
 
 		if b {
@@ -112,33 +310,118 @@ func (e *enforcer) evalPolicy(r *Request, p Policy) (bool, error) {
 	}
 
 	if !rm {
-		return false, nil
+		return nil, false, nil
 	}
 
 	// match resources
-	resm, err := e.matcher.MatchPolicy(p, p.Resources(), r.Resource)
+	r, resm, err := e.matchField(r, p, "resource", p.Resources(), r.Resource)
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 	if !resm {
-		return false, nil
+		return nil, false, nil
 	}
 
 	// match scopes
-	scm, err := e.matcher.MatchPolicy(p, p.Scopes(), r.Scope)
+	r, scm, err := e.matchField(r, p, "scope", p.Scopes(), r.Scope)
 	if err != nil {
-		return false, err
+		return nil, false, err
 	}
 	if !scm {
-		return false, nil
+		return nil, false, nil
+	}
+
+	return r, true, nil
+}
+
+// matchField expands the templates of def and matches val against it. When the matcher reports captures, they
+// are added to the returned request under field.
+func (e *enforcer) matchField(r *Request, p Policy, field string, def []string, val string) (*Request, bool, error) {
+	def, err := expandTemplates(def, r)
+	if err != nil {
+		return r, false, &templateError{err: fmt.Errorf("policy %s: %w", p.ID(), err)}
 	}
 
-	// check all conditions
-	if !e.checkConditions(p, r) {
-		return false, nil
+	cm, ok := e.matcher.(CaptureMatcher)
+	if !ok {
+		match, err := e.matcher.MatchPolicy(p, def, val)
+		return r, match, err
 	}
 
-	return true, nil
+	match, caps, err := cm.MatchPolicyCaptures(p, def, val)
+	if err != nil || !match || len(caps) == 0 {
+		return r, match, err
+	}
+
+	return withCaptures(r, field, caps), true, nil
+}
+
+// policyRoles returns the embedded roles of p along with its role references, which are resolved through the
+// configured RoleManager. References cannot be resolved without one, which is an error.
+func (e *enforcer) policyRoles(p Policy) ([]*Role, error) {
+	refs := p.RoleRefs()
+	if len(refs) == 0 {
+		return p.Roles(), nil
+	}
+
+	if e.options.RoleManager == nil {
+		return nil, fmt.Errorf("policy %s: role references %v need a RoleManager to be resolved", p.ID(), refs)
+	}
+
+	roles := append([]*Role{}, p.Roles()...)
+
+	resolved, err := ResolveRoleRefs(e.options.RoleManager, refs)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", p.ID(), err)
+	}
+
+	return append(roles, resolved...), nil
+}
+
+// prepareRequest returns a copy of r whose context carries the clock and a fresh attribute cache of the
+// enforcer, leaving the caller's request untouched.
+func (e *enforcer) prepareRequest(r *Request) *Request {
+	if e.options.Clock == nil && len(e.options.AttributeProviders) == 0 {
+		return r
+	}
+
+	rc := *r
+
+	if e.options.Clock != nil {
+		rc.Context = NewRequestClockContext(rc.Context, e.options.Clock)
+	}
+
+	if len(e.options.AttributeProviders) > 0 {
+		ar := newAttributeResolver(e.options.AttributeProviders, e.options.AttributeTimeout)
+		rc.Context = context.WithValue(nonNilContext(rc.Context), attributeResolverKey{}, ar)
+	}
+
+	return &rc
+}
+
+// conditionTrace collects condition evaluations for a ConditionAuditor. A nil trace records nothing.
+type conditionTrace struct {
+	evals []ConditionEvaluation
+}
+
+func (t *conditionTrace) add(p Policy, name string, res ConditionResult) {
+	if t != nil {
+		t.evals = append(t.evals, ConditionEvaluation{Policy: p.ID(), Condition: name, ConditionResult: res})
+	}
+}
+
+func (e *enforcer) newTrace() *conditionTrace {
+	if _, ok := e.auditor.(ConditionAuditor); ok {
+		return &conditionTrace{}
+	}
+
+	return nil
+}
+
+func (e *enforcer) auditConditions(req *Request, tr *conditionTrace) {
+	if ca, ok := e.auditor.(ConditionAuditor); ok && tr != nil && len(tr.evals) > 0 {
+		ca.LogConditions(req, tr.evals)
+	}
 }
 
 func (e *enforcer) auditReq(req *Request) {
@@ -59,3 +59,19 @@ func NewErrRequestDeniedImplicit(err error) error {
 		reason: "request denied because no matching policy was found",
 	})
 }
+
+// NewErrRequestIndeterminate returns an error for requests denied because a condition of an applicable policy
+// could not be evaluated.
+func NewErrRequestIndeterminate(p Policy, err error) error {
+	if err == nil {
+		err = errors.New("indeterminate")
+	}
+
+	return errors.WithStack(&Error{
+		error:  errors.Wrap(err, "access denied"),
+		id:     p.ID(),
+		code:   http.StatusForbidden,
+		status: http.StatusText(http.StatusForbidden),
+		reason: "request denied because a policy could not be evaluated",
+	})
+}
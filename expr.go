@@ -0,0 +1,1065 @@
+package redtape
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The expression language evaluated by ExprCondition. Expressions are side effect free: they can read
+// attributes, compare, combine and compute values, but cannot loop or call anything beyond a few pure
+// builtins. Any type error or reference to an undefined attribute fails the evaluation.
+//
+// Precedence from lowest to highest:
+//
+//	|| or
+//	&& and
+//	== != < <= > >= in (not in) contains startsWith endsWith matches
+//	+ -
+//	* / %
+//	! not - (unary)
+//	.member [index] builtin(args)
+
+type exprTokenKind int
+
+const (
+	exprTokEOF exprTokenKind = iota
+	exprTokNumber
+	exprTokString
+	exprTokIdent
+	exprTokOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+var exprOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")",
+	"[", "]", ".", ","}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+
+	for i := 0; i < len(src); {
+		c, w := utf8.DecodeRuneInString(src[i:])
+
+		switch {
+		case unicode.IsSpace(c):
+			i += w
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", src[i:j], i)
+			}
+
+			toks = append(toks, exprToken{kind: exprTokNumber, text: src[i:j], num: n, pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			s, n, err := lexExprString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, i)
+			}
+
+			toks = append(toks, exprToken{kind: exprTokString, text: s, pos: i})
+			i += n
+		case isIdentRune(c, false):
+			j := i + w
+
+			for j < len(src) {
+				r, rw := utf8.DecodeRuneInString(src[j:])
+				if !isIdentRune(r, true) {
+					break
+				}
+
+				j += rw
+			}
+
+			toks = append(toks, exprToken{kind: exprTokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+
+			toks = append(toks, exprToken{kind: exprTokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(toks, exprToken{kind: exprTokEOF, pos: len(src)}), nil
+}
+
+// isIdentRune reports whether c may appear in an identifier, digits only past its first rune.
+func isIdentRune(c rune, digits bool) bool {
+	return c == '_' || unicode.IsLetter(c) || digits && unicode.IsDigit(c)
+}
+
+// lexExprString reads a quoted string from the start of src and returns its value and quoted length.
+func lexExprString(src string) (string, int, error) {
+	quote := src[0]
+
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			raw := src[:i+1]
+			if quote == '\'' {
+				raw = `"` + strings.ReplaceAll(strings.ReplaceAll(raw[1:i], `\'`, `'`), `"`, `\"`) + `"`
+			}
+
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid string %s", src[:i+1])
+			}
+
+			return s, i + 1, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// exprNode is a node of a compiled expression.
+type exprNode interface {
+	eval(env exprEnv) (interface{}, error)
+	String() string
+}
+
+// exprEnv resolves the root identifiers of an expression.
+type exprEnv interface {
+	resolve(name string) (interface{}, bool, error)
+}
+
+// requestEnv resolves expression roots with resolveAttribute.
+type requestEnv struct {
+	req *Request
+}
+
+func (e requestEnv) resolve(name string) (interface{}, bool, error) {
+	return resolveAttribute(e.req, name)
+}
+
+// compileExpr parses src into an expression tree.
+func compileExpr(src string) (exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{toks: toks}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != exprTokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	return n, nil
+}
+
+// maxExprDepth bounds the nesting of expressions, counting parentheses, operands of unary operators and the
+// operators chained at one level, so that the recursive parser and evaluator cannot exhaust the stack.
+const maxExprDepth = 256
+
+type exprParser struct {
+	toks  []exprToken
+	pos   int
+	depth int
+}
+
+// descend records one more level of nesting, failing once the expression nests deeper than maxExprDepth.
+// Callers restore the depth they started at when they return.
+func (p *exprParser) descend() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return fmt.Errorf("expression nests deeper than %d levels at %d", maxExprDepth, p.peek().pos)
+	}
+
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.toks[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != exprTokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// accept consumes the next token when it is one of the given operators or keywords.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != exprTokOp && t.kind != exprTokIdent {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d, found %q", op, t.pos, t.text)
+	}
+
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	if err := p.descend(); err != nil {
+		return nil, err
+	}
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+
+		if err := p.descend(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &exprLogical{op: "||", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+
+		if err := p.descend(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &exprLogical{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in", "contains", "startsWith", "endsWith", "matches")
+	if !ok {
+		if t := p.peek(); t.kind == exprTokIdent && t.text == "not" && p.toks[p.pos+1].text == "in" {
+			p.pos += 2
+			op, ok = "not in", true
+		}
+	}
+
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &exprBinary{op: op, left: left, right: right}
+
+	if lit, isLit := right.(*exprLiteral); isLit && op == "matches" {
+		s, isStr := lit.val.(string)
+		if !isStr {
+			return nil, fmt.Errorf("matches requires a string pattern")
+		}
+
+		if n.re, err = regexp.Compile(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+
+		if err := p.descend(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+
+		if err := p.descend(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	op, ok := p.accept("!", "not", "-")
+	if !ok {
+		return p.parsePostfix()
+	}
+
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	if err := p.descend(); err != nil {
+		return nil, err
+	}
+
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "not" {
+		op = "!"
+	}
+
+	return &exprUnary{op: op, operand: operand}, nil
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if t := p.peek(); t.kind == exprTokOp && (t.text == "." || t.text == "[") {
+			if err := p.descend(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case p.peek().text == "." && p.peek().kind == exprTokOp:
+			p.next()
+
+			t := p.next()
+			if t.kind != exprTokIdent {
+				return nil, fmt.Errorf("expected member name at %d", t.pos)
+			}
+
+			n = &exprMember{object: n, name: t.text}
+		case p.peek().text == "[" && p.peek().kind == exprTokOp:
+			p.next()
+
+			idx, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+
+			n = &exprIndex{object: n, index: idx}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case exprTokNumber:
+		return &exprLiteral{val: t.num, text: t.text}, nil
+	case exprTokString:
+		return &exprLiteral{val: t.text, text: strconv.Quote(t.text)}, nil
+	case exprTokIdent:
+		switch t.text {
+		case "true":
+			return &exprLiteral{val: true, text: t.text}, nil
+		case "false":
+			return &exprLiteral{val: false, text: t.text}, nil
+		case "null", "nil":
+			return &exprLiteral{val: nil, text: t.text}, nil
+		case "and", "or", "not", "in", "contains", "startsWith", "endsWith", "matches":
+			return nil, fmt.Errorf("unexpected keyword %q at %d", t.text, t.pos)
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+
+		return &exprIdent{name: t.text}, nil
+	case exprTokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+
+			return &exprList{items: items}, nil
+		}
+	case exprTokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(t exprToken) (exprNode, error) {
+	fn, ok := exprBuiltins[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at %d", t.text, t.pos)
+	}
+
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects 1 argument, got %d", t.text, len(args))
+	}
+
+	return &exprCall{name: t.text, fn: fn, args: args}, nil
+}
+
+func (p *exprParser) parseList(end string) ([]exprNode, error) {
+	var items []exprNode
+
+	if _, ok := p.accept(end); ok {
+		return items, nil
+	}
+
+	for {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, n)
+
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+
+		return items, p.expect(end)
+	}
+}
+
+type exprLiteral struct {
+	val  interface{}
+	text string
+}
+
+func (n *exprLiteral) eval(exprEnv) (interface{}, error) {
+	return n.val, nil
+}
+
+func (n *exprLiteral) String() string {
+	return n.text
+}
+
+type exprIdent struct {
+	name string
+}
+
+func (n *exprIdent) eval(env exprEnv) (interface{}, error) {
+	v, ok, err := env.resolve(n.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("undefined attribute %s", n.name)
+	}
+
+	return v, nil
+}
+
+func (n *exprIdent) String() string {
+	return n.name
+}
+
+type exprMember struct {
+	object exprNode
+	name   string
+}
+
+func (n *exprMember) eval(env exprEnv) (interface{}, error) {
+	obj, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := attributeField(obj, n.name)
+	if !ok {
+		return nil, fmt.Errorf("undefined attribute %s", n)
+	}
+
+	return v, nil
+}
+
+func (n *exprMember) String() string {
+	return n.object.String() + "." + n.name
+}
+
+type exprIndex struct {
+	object exprNode
+	index  exprNode
+}
+
+func (n *exprIndex) eval(env exprEnv) (interface{}, error) {
+	obj, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := n.index.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := attributeField(obj, key)
+	if !ok {
+		return nil, fmt.Errorf("undefined attribute %s", n)
+	}
+
+	return v, nil
+}
+
+func (n *exprIndex) String() string {
+	return n.object.String() + "[" + n.index.String() + "]"
+}
+
+type exprList struct {
+	items []exprNode
+}
+
+func (n *exprList) eval(env exprEnv) (interface{}, error) {
+	vals := make([]interface{}, 0, len(n.items))
+
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		vals = append(vals, v)
+	}
+
+	return vals, nil
+}
+
+func (n *exprList) String() string {
+	items := make([]string, 0, len(n.items))
+	for _, item := range n.items {
+		items = append(items, item.String())
+	}
+
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+var exprBuiltins = map[string]func(interface{}) (interface{}, error){
+	"len": func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return float64(len([]rune(s))), nil
+		}
+
+		rv := indirectValue(reflect.ValueOf(v))
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return float64(rv.Len()), nil
+		}
+
+		return nil, fmt.Errorf("len of %T", v)
+	},
+	"lower": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("lower of %T", v)
+		}
+
+		return strings.ToLower(s), nil
+	},
+	"upper": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("upper of %T", v)
+		}
+
+		return strings.ToUpper(s), nil
+	},
+}
+
+type exprCall struct {
+	name string
+	fn   func(interface{}) (interface{}, error)
+	args []exprNode
+}
+
+func (n *exprCall) eval(env exprEnv) (interface{}, error) {
+	v, err := n.args[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.fn(v)
+}
+
+func (n *exprCall) String() string {
+	return n.name + "(" + n.args[0].String() + ")"
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (n *exprUnary) eval(env exprEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "!" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! on %T", v)
+		}
+
+		return !b, nil
+	}
+
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("operator - on %T", v)
+	}
+
+	return -f, nil
+}
+
+func (n *exprUnary) String() string {
+	return n.op + n.operand.String()
+}
+
+type exprLogical struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (n *exprLogical) eval(env exprEnv) (interface{}, error) {
+	l, err := evalBool(n.left, env, n.op)
+	if err != nil {
+		return nil, err
+	}
+
+	if (n.op == "||") == l {
+		return l, nil
+	}
+
+	return evalBool(n.right, env, n.op)
+}
+
+func (n *exprLogical) String() string {
+	return "(" + n.left.String() + " " + n.op + " " + n.right.String() + ")"
+}
+
+func evalBool(n exprNode, env exprEnv, op string) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("operator %s on %T", op, v)
+	}
+
+	return b, nil
+}
+
+type exprBinary struct {
+	op    string
+	left  exprNode
+	right exprNode
+	re    *regexp.Regexp
+}
+
+func (n *exprBinary) String() string {
+	return "(" + n.left.String() + " " + n.op + " " + n.right.String() + ")"
+}
+
+func (n *exprBinary) eval(env exprEnv) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=":
+		eq, err := exprEqual(l, r)
+		if err != nil {
+			return nil, err
+		}
+
+		return eq == (n.op == "=="), nil
+	case "<", "<=", ">", ">=":
+		return exprCompare(n.op, l, r)
+	case "in", "not in":
+		in, err := exprContains(r, l)
+		if err != nil {
+			return nil, err
+		}
+
+		return in == (n.op == "in"), nil
+	case "contains":
+		return exprContains(l, r)
+	case "startsWith", "endsWith", "matches":
+		return n.evalString(l, r)
+	}
+
+	return exprArithmetic(n.op, l, r)
+}
+
+func (n *exprBinary) evalString(l, r interface{}) (interface{}, error) {
+	ls, lok := l.(string)
+	rs, rok := r.(string)
+
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s on %T and %T", n.op, l, r)
+	}
+
+	switch n.op {
+	case "startsWith":
+		return strings.HasPrefix(ls, rs), nil
+	case "endsWith":
+		return strings.HasSuffix(ls, rs), nil
+	}
+
+	re := n.re
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(rs); err != nil {
+			return nil, err
+		}
+	}
+
+	return re.MatchString(ls), nil
+}
+
+func exprArithmetic(op string, l, r interface{}) (interface{}, error) {
+	if ls, ok := l.(string); ok && op == "+" {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator + on string and %T", r)
+		}
+
+		return ls + rs, nil
+	}
+
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s on %T and %T", op, l, r)
+	}
+
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	}
+
+	if rf == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+
+	if op == "%" {
+		return math.Mod(lf, rf), nil
+	}
+
+	return lf / rf, nil
+}
+
+// exprEqual compares two values of the same kind. Comparing values of different kinds is a type error,
+// except for comparisons with null.
+func exprEqual(l, r interface{}) (bool, error) {
+	if l == nil || r == nil {
+		return l == nil && r == nil, nil
+	}
+
+	if lf, ok := toFloat(l); ok {
+		rf, ok := toFloat(r)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %T and %T", l, r)
+		}
+
+		return lf == rf, nil
+	}
+
+	switch lv := l.(type) {
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %T and %T", l, r)
+		}
+
+		return lv == rv, nil
+	case bool:
+		rv, ok := r.(bool)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %T and %T", l, r)
+		}
+
+		return lv == rv, nil
+	}
+
+	ll, lok := toList(l)
+	rl, rok := toList(r)
+
+	if lok && rok {
+		if len(ll) != len(rl) {
+			return false, nil
+		}
+
+		for i := range ll {
+			if eq, err := exprEqual(ll[i], rl[i]); err != nil || !eq {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+
+	return reflect.DeepEqual(l, r), nil
+}
+
+func exprCompare(op string, l, r interface{}) (bool, error) {
+	var c int
+
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+	ls, lsok := l.(string)
+	rs, rsok := r.(string)
+
+	switch {
+	case lok && rok:
+		if math.IsNaN(lf) || math.IsNaN(rf) {
+			return false, nil
+		}
+
+		switch {
+		case lf < rf:
+			c = -1
+		case lf > rf:
+			c = 1
+		}
+	case lsok && rsok:
+		c = strings.Compare(ls, rs)
+	default:
+		return false, fmt.Errorf("operator %s on %T and %T", op, l, r)
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// exprContains reports whether container holds v: list elements, substrings of a string or keys of a map.
+func exprContains(container, v interface{}) (bool, error) {
+	if s, ok := container.(string); ok {
+		sub, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot search string for %T", v)
+		}
+
+		return strings.Contains(s, sub), nil
+	}
+
+	if list, ok := toList(container); ok {
+		for _, item := range list {
+			if eq, err := exprEqual(item, v); err == nil && eq {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	if rv := indirectValue(reflect.ValueOf(container)); rv.Kind() == reflect.Map {
+		key, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot search map for %T", v)
+		}
+
+		_, found := attributeField(container, key)
+
+		return found, nil
+	}
+
+	return false, fmt.Errorf("cannot search %T", container)
+}
+
+// toFloat converts Go numeric kinds and json.Number to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
+
+// toList converts slices and arrays other than byte slices to []interface{}.
+func toList(v interface{}) ([]interface{}, bool) {
+	if l, ok := v.([]interface{}); ok {
+		return l, true
+	}
+
+	rv := indirectValue(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+
+	return list, true
+}
@@ -0,0 +1,119 @@
+package redtape
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExprCondition(t *testing.T) {
+	req := NewRequest("invoices/42", "refund", "support", "eu", map[string]interface{}{
+		"amount": 250,
+		"limit":  json.Number("1000"),
+		"subject": map[string]interface{}{
+			"department": "billing",
+			"groups":     []string{"support", "tier2"},
+		},
+		"resource": map[string]interface{}{
+			"owner_dept": "billing",
+		},
+		"tags":       []interface{}{"a", "b"},
+		"région":     "Île-de-France",
+		"catégorie":  "à la carte",
+		"ñ_sub_dept": "billing",
+	})
+
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: `meta.amount < 1000 && subject.department == resource.owner_dept`, want: true},
+		{expr: `amount * 4 >= limit`, want: true},
+		{expr: `amount + 1 == 251 and not (amount % 2 == 1)`, want: true},
+		{expr: `-amount < 0 || false`, want: true},
+		{expr: `request.action == "refund" && request.resource startsWith 'invoices/'`, want: true},
+		{expr: `request.resource endsWith "/42" && request.resource matches "^invoices/[0-9]+$"`, want: true},
+		{expr: `"tier2" in subject.groups && "admin" not in subject.groups`, want: true},
+		{expr: `subject.groups[1] == "tier2" && subject["department"] == "billing"`, want: true},
+		{expr: `tags contains "b" && len(tags) == 2 && "department" in subject`, want: true},
+		{expr: `upper(request.scope) in ["EU", "US"] && lower("AB") + "c" == "abc"`, want: true},
+		{expr: `request.subject == "support" && "voice" in "invoices"`, want: true},
+		{expr: `amount > 1000`, want: false},
+		{expr: `meta.missing == 1`, wantErr: true},
+		{expr: `amount == "250"`, wantErr: true},
+		{expr: `amount < "1000"`, wantErr: true},
+		{expr: `amount && true`, wantErr: true},
+		{expr: `amount / 0 > 1`, wantErr: true},
+		{expr: `amount + 1`, wantErr: true},
+		{expr: `false && meta.missing`, want: false},
+		{expr: `true || meta.missing`, want: true},
+		{expr: `région == "Île-de-France" && catégorie startsWith "à "`, want: true},
+		{expr: `meta.ñ_sub_dept == subject.department`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c := &ExprCondition{Expression: tt.expr}
+			if err := c.Init(nil); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			got, err := c.eval(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+
+			if c.Meets(nil, req) != (tt.want && !tt.wantErr) {
+				t.Errorf("Meets() should fail closed")
+			}
+		})
+	}
+}
+
+func TestCompileExprDepth(t *testing.T) {
+	for _, src := range []string{
+		strings.Repeat("(", maxExprDepth-1) + "1" + strings.Repeat(")", maxExprDepth-1),
+		strings.Repeat("!", maxExprDepth-1) + "true",
+		"1" + strings.Repeat(" + 1", maxExprDepth-1),
+	} {
+		if _, err := compileExpr(src); err != nil {
+			t.Errorf("compileExpr() of %d levels error = %v", maxExprDepth-1, err)
+		}
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`amount <`,
+		`(amount < 1`,
+		`amount < 1)`,
+		`meta.`,
+		`a in in b`,
+		`unknown(1)`,
+		`len(1, 2)`,
+		`name matches "("`,
+		`"unterminated`,
+		`amount # 1`,
+		strings.Repeat("(", maxExprDepth) + "1" + strings.Repeat(")", maxExprDepth),
+		strings.Repeat("!", maxExprDepth+1) + "true",
+		"1" + strings.Repeat(" + 1", maxExprDepth),
+		"a" + strings.Repeat(".a", maxExprDepth),
+		strings.Repeat("(", 1000000),
+	} {
+		if _, err := compileExpr(src); err == nil {
+			t.Errorf("compileExpr(%q) expected error", src)
+		}
+	}
+
+	_, err := NewConditions([]ConditionOptions{
+		{Name: "bad", Type: "expr", Options: map[string]interface{}{"expression": "1 +"}},
+	}, nil)
+	if err == nil {
+		t.Error("NewConditions() should fail to compile expression")
+	}
+}
@@ -3,7 +3,7 @@ module github.com/blushft/redtape
 go 1.16
 
 require (
-	github.com/AlecAivazis/survey/v2 v2.2.14 // indirect
+	github.com/AlecAivazis/survey/v2 v2.2.14
 	github.com/davecgh/go-spew v1.1.1
 	github.com/fatih/structs v1.1.0
 	github.com/mitchellh/mapstructure v1.2.2
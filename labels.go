@@ -0,0 +1,129 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LabelOperator describes how a LabelRequirement compares a label value.
+type LabelOperator string
+
+const (
+	// LabelEquals requires the label to be present with the given value.
+	LabelEquals LabelOperator = "="
+	// LabelNotEquals requires the label to be absent or to hold a different value.
+	LabelNotEquals LabelOperator = "!="
+	// LabelExists requires the label to be present with any value.
+	LabelExists LabelOperator = "exists"
+	// LabelNotExists requires the label to be absent.
+	LabelNotExists LabelOperator = "!exists"
+)
+
+// LabelRequirement is a single clause of a LabelSelector.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Value    string
+}
+
+// Matches evaluates true when labels satisfy the requirement.
+func (lr LabelRequirement) Matches(labels map[string]string) bool {
+	v, ok := labels[lr.Key]
+
+	switch lr.Operator {
+	case LabelEquals:
+		return ok && v == lr.Value
+	case LabelNotEquals:
+		return !ok || v != lr.Value
+	case LabelExists:
+		return ok
+	case LabelNotExists:
+		return !ok
+	}
+
+	return false
+}
+
+// LabelSelector is a set of requirements that must all be met by a set of labels.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses a comma separated selector such as "team=payments,env!=dev". Supported clauses
+// are key=value, key==value, key!=value, key (exists) and !key (does not exist). An empty selector
+// matches everything.
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+
+	for _, clause := range strings.Split(s, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+
+		lr, err := parseLabelRequirement(clause)
+		if err != nil {
+			return nil, err
+		}
+
+		sel = append(sel, lr)
+	}
+
+	return sel, nil
+}
+
+func parseLabelRequirement(clause string) (LabelRequirement, error) {
+	var lr LabelRequirement
+
+	switch {
+	case strings.Contains(clause, "!="):
+		i := strings.Index(clause, "!=")
+		lr = LabelRequirement{Key: clause[:i], Operator: LabelNotEquals, Value: clause[i+2:]}
+	case strings.Contains(clause, "=="):
+		i := strings.Index(clause, "==")
+		lr = LabelRequirement{Key: clause[:i], Operator: LabelEquals, Value: clause[i+2:]}
+	case strings.Contains(clause, "="):
+		i := strings.Index(clause, "=")
+		lr = LabelRequirement{Key: clause[:i], Operator: LabelEquals, Value: clause[i+1:]}
+	case strings.HasPrefix(clause, "!"):
+		lr = LabelRequirement{Key: clause[1:], Operator: LabelNotExists}
+	default:
+		lr = LabelRequirement{Key: clause, Operator: LabelExists}
+	}
+
+	lr.Key = strings.TrimSpace(lr.Key)
+	lr.Value = strings.TrimSpace(lr.Value)
+
+	if lr.Key == "" || strings.ContainsAny(lr.Key, " !=") {
+		return LabelRequirement{}, fmt.Errorf("invalid label selector clause %q", clause)
+	}
+
+	return lr, nil
+}
+
+// Matches evaluates true when labels satisfy every requirement of the selector.
+func (ls LabelSelector) Matches(labels map[string]string) bool {
+	for _, lr := range ls {
+		if !lr.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the selector in its parseable form.
+func (ls LabelSelector) String() string {
+	clauses := make([]string, 0, len(ls))
+
+	for _, lr := range ls {
+		switch lr.Operator {
+		case LabelExists:
+			clauses = append(clauses, lr.Key)
+		case LabelNotExists:
+			clauses = append(clauses, "!"+lr.Key)
+		default:
+			clauses = append(clauses, lr.Key+string(lr.Operator)+lr.Value)
+		}
+	}
+
+	return strings.Join(clauses, ",")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 )
 
 // PolicyManager contains methods to allow query, update, and removal of policies.
@@ -18,22 +19,93 @@ type PolicyManager interface {
 	FindByRole(string) ([]Policy, error)
 	FindByResource(string) ([]Policy, error)
 	FindByScope(string) ([]Policy, error)
+	FindByLabels(selector string, limit, offset int) ([]Policy, error)
+
+	CreateSet(PolicySet) error
+	UpdateSet(PolicySet) error
+	GetSet(string) (PolicySet, error)
+	DeleteSet(string) error
+	AllSets(limit, offset int) ([]PolicySet, error)
+
+	FindSetsByRequest(*Request) ([]PolicySet, error)
+}
+
+// ConditionRegistryProvider is implemented by managers that carry the ConditionRegistry policies stored in them
+// are built with.
+type ConditionRegistryProvider interface {
+	ConditionRegistry() ConditionRegistry
+}
+
+// ManagerOptions holds the configuration of the default PolicyManager. When Matcher is a MatcherPrecompiler,
+// the patterns of policies and sets are compiled as they are added and invalid ones are rejected.
+type ManagerOptions struct {
+	Registry ConditionRegistry
+	Matcher  Matcher
+}
+
+// ManagerOption is a typed function allowing updates to ManagerOptions through functional options.
+type ManagerOption func(*ManagerOptions)
+
+// NewManagerOptions returns ManagerOptions configured with the provided functional options.
+func NewManagerOptions(opts ...ManagerOption) ManagerOptions {
+	options := ManagerOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// ManagerConditionRegistry sets the ConditionRegistry carried by the manager.
+func ManagerConditionRegistry(reg ConditionRegistry) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Registry = reg
+	}
+}
+
+// ManagerMatcher sets the Matcher policies and sets are precompiled for as they are added.
+func ManagerMatcher(matcher Matcher) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Matcher = matcher
+	}
 }
 
 type defaultManager struct {
 	policies map[string]Policy
+	sets     map[string]PolicySet
+	options  ManagerOptions
 	mu       sync.RWMutex
 }
 
 // NewManager returns a default memory backed policy manager.
-func NewManager() PolicyManager {
+func NewManager(opts ...ManagerOption) PolicyManager {
 	return &defaultManager{
 		policies: make(map[string]Policy),
+		sets:     make(map[string]PolicySet),
+		options:  NewManagerOptions(opts...),
+	}
+}
+
+// ConditionRegistry fulfills ConditionRegistryProvider, returning the default registry when none was configured.
+func (m *defaultManager) ConditionRegistry() ConditionRegistry {
+	if m.options.Registry == nil {
+		return NewConditionRegistry()
 	}
+
+	return m.options.Registry
 }
 
-// Create adds a policy to the manager.
+// Create validates and adds a policy to the manager, setting its created and updated timestamps.
 func (m *defaultManager) Create(p Policy) error {
+	if err := ValidatePolicyMatcher(p, m.options.Matcher).Err(); err != nil {
+		return err
+	}
+
+	if err := PrecompilePolicy(m.options.Matcher, p); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -41,17 +113,26 @@ func (m *defaultManager) Create(p Policy) error {
 		return fmt.Errorf("policy %s already registered", p.ID())
 	}
 
-	m.policies[p.ID()] = p
+	m.policies[p.ID()] = StampPolicy(p, nil, time.Now())
 
 	return nil
 }
 
-// Update replaces a named policy with the provided policy.
+// Update validates and replaces a named policy with the provided policy, preserving the created timestamp of the replaced
+// policy.
 func (m *defaultManager) Update(p Policy) error {
+	if err := ValidatePolicyMatcher(p, m.options.Matcher).Err(); err != nil {
+		return err
+	}
+
+	if err := PrecompilePolicy(m.options.Matcher, p); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.policies[p.ID()] = p
+	m.policies[p.ID()] = StampPolicy(p, m.policies[p.ID()], time.Now())
 
 	return nil
 }
@@ -129,11 +210,134 @@ func (m *defaultManager) FindByResource(_ string) ([]Policy, error) {
 	return m.findAll()
 }
 
+// FindByLabels returns policies whose labels match selector, ordered by id and paginated by limit and offset.
+func (m *defaultManager) FindByLabels(selector string, limit, offset int) ([]Policy, error) {
+	sel, err := ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pkeys := make([]string, 0, len(m.policies))
+	for k, p := range m.policies {
+		if sel.Matches(p.Labels()) {
+			pkeys = append(pkeys, k)
+		}
+	}
+
+	start, end := limitIndices(limit, offset, len(pkeys))
+	sort.Strings(pkeys)
+
+	pols := make([]Policy, 0, len(pkeys[start:end]))
+	for _, p := range pkeys[start:end] {
+		pols = append(pols, m.policies[p])
+	}
+
+	return pols, nil
+}
+
 // FindByResource returns all policies matching a Resource.
 func (m *defaultManager) FindByScope(_ string) ([]Policy, error) {
 	return m.findAll()
 }
 
+// CreateSet adds a policy set to the manager.
+func (m *defaultManager) CreateSet(s PolicySet) error {
+	if err := PrecompilePolicySet(m.options.Matcher, s); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sets[s.ID()]; exists {
+		return fmt.Errorf("policy set %s already registered", s.ID())
+	}
+
+	m.sets[s.ID()] = s
+
+	return nil
+}
+
+// UpdateSet replaces a named policy set with the provided set.
+func (m *defaultManager) UpdateSet(s PolicySet) error {
+	if err := PrecompilePolicySet(m.options.Matcher, s); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sets[s.ID()] = s
+
+	return nil
+}
+
+// GetSet retrieves a policy set by id or error if one does not exist.
+func (m *defaultManager) GetSet(id string) (PolicySet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sets[id]
+	if !ok {
+		return nil, fmt.Errorf("policy set %s does not exist", id)
+	}
+
+	return s, nil
+}
+
+// DeleteSet removes a policy set by id.
+func (m *defaultManager) DeleteSet(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sets, id)
+	return nil
+}
+
+// AllSets returns a slice containing all policy sets.
+func (m *defaultManager) AllSets(limit, offset int) ([]PolicySet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	skeys := make([]string, 0, len(m.sets))
+	for k := range m.sets {
+		skeys = append(skeys, k)
+	}
+
+	start, end := limitIndices(limit, offset, len(m.sets))
+	sort.Strings(skeys)
+
+	sets := make([]PolicySet, 0, len(skeys[start:end]))
+	for _, s := range skeys[start:end] {
+		sets = append(sets, m.sets[s])
+	}
+
+	return sets, nil
+}
+
+// FindSetsByRequest returns all policy sets that may apply to a Request. Targets are evaluated by the Enforcer.
+func (m *defaultManager) FindSetsByRequest(_ *Request) ([]PolicySet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	skeys := make([]string, 0, len(m.sets))
+	for k := range m.sets {
+		skeys = append(skeys, k)
+	}
+
+	sort.Strings(skeys)
+
+	sets := make([]PolicySet, 0, len(skeys))
+	for _, s := range skeys {
+		sets = append(sets, m.sets[s])
+	}
+
+	return sets, nil
+}
+
 // RoleManager provides methods to store and retrieve role sets.
 type RoleManager interface {
 	Create(*Role) error
@@ -239,6 +443,27 @@ func (m *defaultRoleManager) GetMatching(id string) ([]*Role, error) {
 	panic("not implemented")
 }
 
+// StampPolicy returns a copy of p carrying maintained timestamps when it implements PolicyTimestamper, or p
+// itself otherwise. The created time is taken from prev when it is not nil, kept when p already has one, or set
+// to now.
+func StampPolicy(p, prev Policy, now time.Time) Policy {
+	ts, ok := p.(PolicyTimestamper)
+	if !ok {
+		return p
+	}
+
+	created := p.CreatedAt()
+	if prev != nil && !prev.CreatedAt().IsZero() {
+		created = prev.CreatedAt()
+	}
+
+	if created.IsZero() {
+		created = now
+	}
+
+	return ts.WithTimestamps(created, now)
+}
+
 func limitIndices(limit, offset, length int) (int, int) {
 	if offset > length {
 		return length, length
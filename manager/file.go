@@ -6,15 +6,23 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/blushft/redtape"
 )
 
+// FileOptions configures the files a File manager stores roles and policies in. Registry is the
+// ConditionRegistry stored policies are rebuilt with, the default registry when nil. Matcher, when set,
+// precompiles the patterns of policies and sets as they are written.
 type FileOptions struct {
-	Name string
-	Path string
+	Name     string
+	Path     string
+	Registry redtape.ConditionRegistry
+	Matcher  redtape.Matcher
 }
 
+// FileOption is a typed function allowing updates to FileOptions through functional options.
 type FileOption func(*FileOptions)
 
 func NewFileOptions(opts ...FileOption) FileOptions {
@@ -29,6 +37,35 @@ func NewFileOptions(opts ...FileOption) FileOptions {
 	return o
 }
 
+// FileName sets the base name of the files, "redtape" by default.
+func FileName(name string) FileOption {
+	return func(o *FileOptions) {
+		o.Name = name
+	}
+}
+
+// FilePath sets the directory the files are stored in.
+func FilePath(path string) FileOption {
+	return func(o *FileOptions) {
+		o.Path = path
+	}
+}
+
+// FileConditionRegistry sets the ConditionRegistry used to rebuild the conditions of stored policies, allowing
+// policies to use custom conditions.
+func FileConditionRegistry(reg redtape.ConditionRegistry) FileOption {
+	return func(o *FileOptions) {
+		o.Registry = reg
+	}
+}
+
+// FileMatcher sets the Matcher policies and sets are precompiled for as they are written.
+func FileMatcher(matcher redtape.Matcher) FileOption {
+	return func(o *FileOptions) {
+		o.Matcher = matcher
+	}
+}
+
 type File struct {
 	options FileOptions
 }
@@ -39,22 +76,64 @@ func NewFile(opts ...FileOption) *File {
 	}
 }
 
-func (f *File) policyPath() string {
+func (f *File) registry() redtape.ConditionRegistry {
+	if f.options.Registry == nil {
+		return redtape.NewConditionRegistry()
+	}
+
+	return f.options.Registry
+}
+
+// PolicyManager returns a redtape.PolicyManager storing policies and policy sets in files, creating them when
+// they do not exist.
+func (f *File) PolicyManager() (redtape.PolicyManager, error) {
+	for _, fp := range []string{f.PolicyPath(), f.SetPath()} {
+		if !fileExists(fp) {
+			if err := os.WriteFile(fp, []byte("{}"), os.ModePerm); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &filePolicyMgr{mgr: f}, nil
+}
+
+// PolicyPath returns the path of the file policies are stored in.
+func (f *File) PolicyPath() string {
 	fn := fmt.Sprintf("%s.policy", f.options.Name)
 	return filepath.Join(f.options.Path, fn)
 }
 
+// SetPath returns the path of the file policy sets are stored in.
+func (f *File) SetPath() string {
+	fn := fmt.Sprintf("%s.sets", f.options.Name)
+	return filepath.Join(f.options.Path, fn)
+}
+
+// loadPolicies decodes the stored policy options and builds them with the configured registry.
 func (f *File) loadPolicies() (map[string]redtape.Policy, error) {
-	b, err := os.ReadFile(f.policyPath())
+	b, err := os.ReadFile(f.PolicyPath())
 	if err != nil {
 		return nil, err
 	}
 
-	m := make(map[string]redtape.Policy)
-	if err := json.Unmarshal(b, &m); err != nil {
+	opts := make(map[string]redtape.PolicyOptions)
+	if err := json.Unmarshal(b, &opts); err != nil {
 		return nil, err
 	}
 
+	reg := f.registry()
+	m := make(map[string]redtape.Policy, len(opts))
+
+	for id, po := range opts {
+		p, err := redtape.NewPolicy(redtape.SetPolicyOptions(po), redtape.SetConditionRegistry(reg))
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", id, err)
+		}
+
+		m[id] = p
+	}
+
 	return m, nil
 }
 
@@ -64,7 +143,43 @@ func (f *File) savePolicies(m map[string]redtape.Policy) error {
 		return err
 	}
 
-	return os.WriteFile(f.policyPath(), b, os.ModePerm)
+	return os.WriteFile(f.PolicyPath(), b, os.ModePerm)
+}
+
+// loadSets decodes the stored policy set options and builds them with the configured registry.
+func (f *File) loadSets() (map[string]redtape.PolicySet, error) {
+	b, err := os.ReadFile(f.SetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := make(map[string]redtape.PolicySetOptions)
+	if err := json.Unmarshal(b, &opts); err != nil {
+		return nil, err
+	}
+
+	reg := f.registry()
+	m := make(map[string]redtape.PolicySet, len(opts))
+
+	for id, so := range opts {
+		s, err := redtape.NewPolicySet(redtape.SetPolicySetOptions(so), redtape.SetPolicySetRegistry(reg))
+		if err != nil {
+			return nil, fmt.Errorf("policy set %s: %w", id, err)
+		}
+
+		m[id] = s
+	}
+
+	return m, nil
+}
+
+func (f *File) saveSets(m map[string]redtape.PolicySet) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.SetPath(), b, os.ModePerm)
 }
 
 func (f *File) RoleManager() (redtape.RoleManager, error) {
@@ -201,44 +316,341 @@ func (f *fileRoleMgr) GetMatching(_ string) ([]*redtape.Role, error) {
 	panic("not implemented") // TODO: Implement
 }
 
+// fileStamp identifies a version of a file by its modification time and size.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(fp string) (fileStamp, error) {
+	i, err := os.Stat(fp)
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: i.ModTime(), size: i.Size()}, nil
+}
+
+func (s fileStamp) equal(o fileStamp) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+// filePolicyMgr keeps the policies and sets built from its files until it writes them or they change on disk,
+// so that conditions and patterns are not built again on every request.
 type filePolicyMgr struct {
 	mgr *File
+	mu  sync.Mutex
+
+	cacheMu     sync.Mutex
+	policies    map[string]redtape.Policy
+	policyList  []redtape.Policy
+	policyStamp fileStamp
+	sets        map[string]redtape.PolicySet
+	setList     []redtape.PolicySet
+	setStamp    fileStamp
+}
+
+// cachedPolicies returns the stored policies by id and ordered by id, building them again only when the
+// policy file changed since they were last built.
+func (f *filePolicyMgr) cachedPolicies() (map[string]redtape.Policy, []redtape.Policy, error) {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+
+	stamp, err := statFile(f.mgr.PolicyPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.policies != nil && stamp.equal(f.policyStamp) {
+		return f.policies, f.policyList, nil
+	}
+
+	m, err := f.mgr.loadPolicies()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkeys := make([]string, 0, len(m))
+	for k := range m {
+		pkeys = append(pkeys, k)
+	}
+
+	sort.Strings(pkeys)
+
+	pols := make([]redtape.Policy, 0, len(pkeys))
+	for _, k := range pkeys {
+		pols = append(pols, m[k])
+	}
+
+	f.policies, f.policyList, f.policyStamp = m, pols, stamp
+
+	return m, pols, nil
+}
+
+// cachedSets returns the stored policy sets by id and ordered by id, building them again only when the set
+// file changed since they were last built.
+func (f *filePolicyMgr) cachedSets() (map[string]redtape.PolicySet, []redtape.PolicySet, error) {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+
+	stamp, err := statFile(f.mgr.SetPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if f.sets != nil && stamp.equal(f.setStamp) {
+		return f.sets, f.setList, nil
+	}
+
+	m, err := f.mgr.loadSets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skeys := make([]string, 0, len(m))
+	for k := range m {
+		skeys = append(skeys, k)
+	}
+
+	sort.Strings(skeys)
+
+	sets := make([]redtape.PolicySet, 0, len(skeys))
+	for _, k := range skeys {
+		sets = append(sets, m[k])
+	}
+
+	f.sets, f.setList, f.setStamp = m, sets, stamp
+
+	return m, sets, nil
+}
+
+// invalidate drops the cached policies and sets after the manager wrote its files.
+func (f *filePolicyMgr) invalidate() {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+
+	f.policies, f.policyList = nil, nil
+	f.sets, f.setList = nil, nil
+}
+
+// sortedPolicies returns the stored policies ordered by id.
+func (f *filePolicyMgr) sortedPolicies() ([]redtape.Policy, error) {
+	_, pols, err := f.cachedPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]redtape.Policy(nil), pols...), nil
+}
+
+// sortedSets returns the stored policy sets ordered by id.
+func (f *filePolicyMgr) sortedSets() ([]redtape.PolicySet, error) {
+	_, sets, err := f.cachedSets()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]redtape.PolicySet(nil), sets...), nil
+}
+
+// ConditionRegistry fulfills redtape.ConditionRegistryProvider.
+func (f *filePolicyMgr) ConditionRegistry() redtape.ConditionRegistry {
+	return f.mgr.registry()
 }
 
 func (f *filePolicyMgr) Create(p redtape.Policy) error {
-	panic("not implemented") // TODO: Implement
+	return f.writePolicy(p, false)
 }
 
-func (f *filePolicyMgr) Update(_ redtape.Policy) error {
-	panic("not implemented") // TODO: Implement
+func (f *filePolicyMgr) Update(p redtape.Policy) error {
+	return f.writePolicy(p, true)
 }
 
-func (f *filePolicyMgr) Get(_ string) (redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+func (f *filePolicyMgr) writePolicy(p redtape.Policy, overwrite bool) error {
+	if err := redtape.ValidatePolicyMatcher(p, f.mgr.options.Matcher).Err(); err != nil {
+		return err
+	}
+
+	if err := redtape.PrecompilePolicy(f.mgr.options.Matcher, p); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, err := f.mgr.loadPolicies()
+	if err != nil {
+		return err
+	}
+
+	prev, ok := m[p.ID()]
+	if ok && !overwrite {
+		return fmt.Errorf("policy %s already registered", p.ID())
+	}
+
+	m[p.ID()] = redtape.StampPolicy(p, prev, time.Now())
+
+	defer f.invalidate()
+
+	return f.mgr.savePolicies(m)
 }
 
-func (f *filePolicyMgr) Delete(_ string) error {
-	panic("not implemented") // TODO: Implement
+func (f *filePolicyMgr) Get(id string) (redtape.Policy, error) {
+	m, _, err := f.cachedPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("policy %s does not exist", id)
+	}
+
+	return p, nil
+}
+
+func (f *filePolicyMgr) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, err := f.mgr.loadPolicies()
+	if err != nil {
+		return err
+	}
+
+	delete(m, id)
+
+	defer f.invalidate()
+
+	return f.mgr.savePolicies(m)
 }
 
 func (f *filePolicyMgr) All(limit int, offset int) ([]redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+	pols, err := f.sortedPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := limitIndices(limit, offset, len(pols))
+
+	return pols[start:end], nil
 }
 
 func (f *filePolicyMgr) FindByRequest(_ *redtape.Request) ([]redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+	return f.sortedPolicies()
 }
 
 func (f *filePolicyMgr) FindByRole(_ string) ([]redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+	return f.sortedPolicies()
 }
 
 func (f *filePolicyMgr) FindByResource(_ string) ([]redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+	return f.sortedPolicies()
 }
 
 func (f *filePolicyMgr) FindByScope(_ string) ([]redtape.Policy, error) {
-	panic("not implemented") // TODO: Implement
+	return f.sortedPolicies()
+}
+
+func (f *filePolicyMgr) FindByLabels(selector string, limit int, offset int) ([]redtape.Policy, error) {
+	sel, err := redtape.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := f.sortedPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	pols := make([]redtape.Policy, 0, len(all))
+	for _, p := range all {
+		if sel.Matches(p.Labels()) {
+			pols = append(pols, p)
+		}
+	}
+
+	start, end := limitIndices(limit, offset, len(pols))
+
+	return pols[start:end], nil
+}
+
+func (f *filePolicyMgr) CreateSet(s redtape.PolicySet) error {
+	return f.writeSet(s, false)
+}
+
+func (f *filePolicyMgr) UpdateSet(s redtape.PolicySet) error {
+	return f.writeSet(s, true)
+}
+
+func (f *filePolicyMgr) writeSet(s redtape.PolicySet, overwrite bool) error {
+	if err := redtape.PrecompilePolicySet(f.mgr.options.Matcher, s); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, err := f.mgr.loadSets()
+	if err != nil {
+		return err
+	}
+
+	_, ok := m[s.ID()]
+	if ok && !overwrite {
+		return fmt.Errorf("policy set %s already registered", s.ID())
+	}
+
+	m[s.ID()] = s
+
+	defer f.invalidate()
+
+	return f.mgr.saveSets(m)
+}
+
+func (f *filePolicyMgr) GetSet(id string) (redtape.PolicySet, error) {
+	m, _, err := f.cachedSets()
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("policy set %s does not exist", id)
+	}
+
+	return s, nil
+}
+
+func (f *filePolicyMgr) DeleteSet(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, err := f.mgr.loadSets()
+	if err != nil {
+		return err
+	}
+
+	delete(m, id)
+
+	defer f.invalidate()
+
+	return f.mgr.saveSets(m)
+}
+
+func (f *filePolicyMgr) AllSets(limit int, offset int) ([]redtape.PolicySet, error) {
+	sets, err := f.sortedSets()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := limitIndices(limit, offset, len(sets))
+
+	return sets[start:end], nil
+}
+
+func (f *filePolicyMgr) FindSetsByRequest(_ *redtape.Request) ([]redtape.PolicySet, error) {
+	return f.sortedSets()
 }
 
 func limitIndices(limit, offset, length int) (int, int) {
@@ -3,8 +3,10 @@ package manager_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/conditions"
 	"github.com/blushft/redtape/manager"
 	"github.com/stretchr/testify/assert"
 )
@@ -51,3 +53,351 @@ func TestFileRoleManager(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFilePolicyManager(t *testing.T) {
+	f := manager.NewFile(
+		manager.FilePath(t.TempDir()),
+		manager.FileConditionRegistry(redtape.SharedConditionRegistry()),
+	)
+
+	pm, err := f.PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := redtape.NewPolicy(
+		redtape.PolicyName("office"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("staff")),
+		redtape.SetResources("documents"),
+		redtape.SetActions("read"),
+		redtape.WithLabel("team", "ops"),
+		redtape.SetConditionRegistry(redtape.SharedConditionRegistry()),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name: "remote_addr",
+			Type: "ip_allow",
+			Options: map[string]interface{}{
+				"networks": []string{"10.0.0.0/8"},
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, pm.Create(p))
+	assert.True(t, p.CreatedAt().IsZero())
+
+	got, err := pm.Get("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, p.Resources(), got.Resources())
+	assert.IsType(t, &conditions.IPAllowCondition{}, got.Conditions()["remote_addr"])
+	assert.False(t, got.CreatedAt().IsZero())
+
+	if err := pm.Update(got); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := pm.Get("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, updated.CreatedAt().Equal(got.CreatedAt()))
+
+	byLabel, err := pm.FindByLabels("team=ops", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, byLabel, 1)
+
+	s, err := redtape.NewPolicySet(
+		redtape.PolicySetName("office_set"),
+		redtape.WithPolicyOptions(redtape.PolicyOptions{
+			Name:      "office_nested",
+			Effect:    "allow",
+			Roles:     []*redtape.Role{redtape.NewRole("staff")},
+			Resources: []string{"documents"},
+			Actions:   []string{"read"},
+			Conditions: []redtape.ConditionOptions{
+				{
+					Name:    "remote_addr",
+					Type:    "ip_deny",
+					Options: map[string]interface{}{"networks": []string{"192.168.0.0/16"}},
+				},
+			},
+		}),
+		redtape.SetPolicySetRegistry(redtape.SharedConditionRegistry()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.CreateSet(s); err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := pm.AllSets(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, sets, 1)
+	assert.IsType(t, &conditions.IPDenyCondition{}, sets[0].Policies()[0].Conditions()["remote_addr"])
+
+	if err := pm.Delete("office"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := pm.All(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, all)
+
+	rp, ok := pm.(redtape.ConditionRegistryProvider)
+	assert.True(t, ok)
+	assert.Contains(t, rp.ConditionRegistry(), "ip_allow")
+}
+
+func TestFilePolicyManagerPrecompile(t *testing.T) {
+	f := manager.NewFile(
+		manager.FilePath(t.TempDir()),
+		manager.FileMatcher(redtape.NewRegexMatcher(redtape.RegexDelimiters('{', '}'))),
+	)
+
+	pm, err := f.PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("documents"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("staff")),
+		redtape.SetResources("documents/{[0-9}"),
+		redtape.SetActions("read"),
+	)
+
+	assert.Error(t, pm.Create(p))
+
+	all, err := pm.All(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestFilePolicyManagerSets(t *testing.T) {
+	f := manager.NewFile(manager.FilePath(t.TempDir()))
+
+	pm, err := f.PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pm.GetSet("documents")
+	assert.Error(t, err)
+
+	s := redtape.MustNewPolicySet(
+		redtape.PolicySetName("documents"),
+		redtape.SetTarget(redtape.Target{Resources: []string{"documents/*"}}),
+		redtape.WithPolicyOptions(redtape.PolicyOptions{
+			Name:      "read_documents",
+			Effect:    "allow",
+			Roles:     []*redtape.Role{redtape.NewRole("staff")},
+			Resources: []string{"documents/*"},
+			Actions:   []string{"read"},
+		}),
+	)
+
+	if err := pm.CreateSet(s); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, pm.CreateSet(s))
+	assert.NoError(t, pm.UpdateSet(s))
+
+	got, err := pm.GetSet("documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, s.Target(), got.Target())
+	assert.Len(t, got.Policies(), 1)
+
+	found, err := pm.FindSetsByRequest(redtape.NewRequest("documents/1", "read", "staff", "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, found, 1)
+
+	if err := pm.DeleteSet("documents"); err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := pm.AllSets(10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, sets)
+}
+
+func TestFilePolicyManagerCache(t *testing.T) {
+	f := manager.NewFile(manager.FilePath(t.TempDir()))
+
+	pm, err := f.PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("documents"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("staff")),
+		redtape.SetResources("documents"),
+		redtape.SetActions("read"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	req := redtape.NewRequest("documents", "read", "staff", "")
+
+	first, err := pm.FindByRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := pm.FindByRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, again, 1)
+	assert.Same(t, first[0], again[0], "policies should not be built again while the file is unchanged")
+
+	if err := pm.Update(p); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := pm.FindByRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotSame(t, first[0], updated[0], "a write should rebuild the policies")
+
+	// another process replacing the file is picked up by its modification time
+	if err := os.WriteFile(f.PolicyPath(), []byte("{}"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(f.PolicyPath(), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := pm.FindByRequest(req)
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestFilePolicyManagerEmbeddedRoles(t *testing.T) {
+	dir := t.TempDir()
+
+	pm, err := manager.NewFile(manager.FilePath(dir)).PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("admin_docs"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("admin", redtape.NewRole("viewer"))),
+		redtape.SetActions("read"),
+		redtape.SetResources("docs"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh manager reads the policy back from disk
+	reloaded, err := manager.NewFile(manager.FilePath(dir)).PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reloaded.Get("admin_docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, got.RoleRefs())
+
+	if assert.Len(t, got.Roles(), 1) {
+		assert.Equal(t, "admin", got.Roles()[0].ID)
+		assert.Len(t, got.Roles()[0].Roles, 1)
+	}
+
+	e, err := redtape.NewDefaultEnforcer(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, role := range []string{"admin", "viewer"} {
+		if err := e.Enforce(redtape.NewRequest("docs", "read", role, "")); err != nil {
+			t.Errorf("Enforce() as %s error = %v", role, err)
+		}
+	}
+}
+
+func TestFilePolicyManagerScopes(t *testing.T) {
+	dir := t.TempDir()
+
+	pm, err := manager.NewFile(manager.FilePath(dir)).PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("tenant_docs"),
+		redtape.PolicyAllow(),
+		redtape.WithRole(redtape.NewRole("staff")),
+		redtape.SetActions("read"),
+		redtape.SetResources("docs"),
+		redtape.SetScopes("acme"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := manager.NewFile(manager.FilePath(dir)).PolicyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reloaded.Get("tenant_docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"acme"}, got.Scopes())
+
+	e, err := redtape.NewDefaultEnforcer(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, e.Enforce(redtape.NewRequest("docs", "read", "staff", "acme")))
+	assert.Error(t, e.Enforce(redtape.NewRequest("docs", "read", "staff", "globex")))
+}
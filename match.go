@@ -1,8 +1,12 @@
 package redtape
 
 import (
+	"container/list"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/blushft/redtape/strmatch"
 )
@@ -52,18 +56,132 @@ func (m *simpleMatcher) MatchRole(r *Role, val string) (bool, error) {
 	return false, nil
 }
 
+// MatcherPrecompiler is implemented by matchers that can prepare patterns ahead of matching. Precompile reports
+// patterns that are invalid and could never match.
+type MatcherPrecompiler interface {
+	Precompile(patterns ...string) error
+}
+
+// PrecompilePolicy prepares the role, action, resource and scope patterns of p when m is a MatcherPrecompiler.
+// Patterns holding templates are left until they are expanded.
+func PrecompilePolicy(m Matcher, p Policy) error {
+	pc, ok := m.(MatcherPrecompiler)
+	if !ok {
+		return nil
+	}
+
+	patterns := make([]string, 0, len(p.Roles())+len(p.Actions())+len(p.Resources())+len(p.Scopes()))
+	for _, r := range p.Roles() {
+		patterns = append(patterns, r.ID)
+	}
+
+	// templates are compiled once expanded during evaluation
+	for _, def := range [][]string{p.Actions(), p.Resources(), p.Scopes()} {
+		for _, h := range def {
+			if !isTemplate(h) {
+				patterns = append(patterns, h)
+			}
+		}
+	}
+
+	if err := pc.Precompile(patterns...); err != nil {
+		return fmt.Errorf("policy %s: %w", p.ID(), err)
+	}
+
+	return nil
+}
+
+// PrecompilePolicySet prepares the target patterns of s and the patterns of its policies and nested sets when m
+// is a MatcherPrecompiler.
+func PrecompilePolicySet(m Matcher, s PolicySet) error {
+	pc, ok := m.(MatcherPrecompiler)
+	if !ok {
+		return nil
+	}
+
+	t := s.Target()
+	patterns := make([]string, 0, len(t.Actions)+len(t.Resources)+len(t.Scopes))
+	patterns = append(patterns, t.Actions...)
+	patterns = append(patterns, t.Resources...)
+	patterns = append(patterns, t.Scopes...)
+
+	if err := pc.Precompile(patterns...); err != nil {
+		return fmt.Errorf("policy set %s: %w", s.ID(), err)
+	}
+
+	for _, p := range s.Policies() {
+		if err := PrecompilePolicy(m, p); err != nil {
+			return fmt.Errorf("policy set %s: %w", s.ID(), err)
+		}
+	}
+
+	for _, ns := range s.Sets() {
+		if err := PrecompilePolicySet(m, ns); err != nil {
+			return fmt.Errorf("policy set %s: %w", s.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+// DefaultRegexCacheSize is the number of compiled patterns a regex Matcher keeps unless configured otherwise.
+const DefaultRegexCacheSize = 1024
+
+// RegexMatcherOptions configures the Matcher returned by NewRegexMatcher.
+type RegexMatcherOptions struct {
+	StartDelim rune
+	EndDelim   rune
+	CacheSize  int
+}
+
+// RegexMatcherOption is a typed function allowing updates to RegexMatcherOptions through functional options.
+type RegexMatcherOption func(*RegexMatcherOptions)
+
+// NewRegexMatcherOptions returns RegexMatcherOptions configured with the provided functional options. Regular
+// expressions are delimited by < and > by default.
+func NewRegexMatcherOptions(opts ...RegexMatcherOption) RegexMatcherOptions {
+	options := RegexMatcherOptions{
+		StartDelim: '<',
+		EndDelim:   '>',
+		CacheSize:  DefaultRegexCacheSize,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// RegexDelimiters sets the runes regular expressions are enclosed in within patterns.
+func RegexDelimiters(start, end rune) RegexMatcherOption {
+	return func(o *RegexMatcherOptions) {
+		o.StartDelim = start
+		o.EndDelim = end
+	}
+}
+
+// RegexCacheSize sets the number of compiled patterns kept, the least recently used being evicted first. A size
+// of zero or less disables the cache.
+func RegexCacheSize(n int) RegexMatcherOption {
+	return func(o *RegexMatcherOptions) {
+		o.CacheSize = n
+	}
+}
+
 type regexMatcher struct {
-	startDelim string
-	stopDelim  string
-	pat        map[string]*regexp.Regexp
+	options RegexMatcherOptions
+	cache   *regexCache
 }
 
-// NewRegexMatcher returns a Matcher using delimited regex for matching.
-func NewRegexMatcher() Matcher {
+// NewRegexMatcher returns a Matcher using delimited regex for matching. Patterns without delimiters are matched
+// by wildcard. It is safe for concurrent use.
+func NewRegexMatcher(opts ...RegexMatcherOption) Matcher {
+	options := NewRegexMatcherOptions(opts...)
+
 	return &regexMatcher{
-		startDelim: "<",
-		stopDelim:  ">",
-		pat:        make(map[string]*regexp.Regexp),
+		options: options,
+		cache:   newRegexCache(options.CacheSize),
 	}
 }
 
@@ -83,37 +201,305 @@ func (m *regexMatcher) MatchRole(r *Role, val string) (bool, error) {
 }
 
 // MatchPolicy evaluates true when the provided val regex matches at least one element in def.
+// If def is nil, a match is assumed against any value.
 func (m *regexMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
 	return m.match(def, val)
 }
 
+// Precompile fulfills MatcherPrecompiler, compiling the delimited patterns into the cache.
+func (m *regexMatcher) Precompile(patterns ...string) error {
+	for _, h := range patterns {
+		if !m.delimited(h) {
+			continue
+		}
+
+		if _, err := m.compile(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *regexMatcher) delimited(h string) bool {
+	return strings.ContainsRune(h, m.options.StartDelim)
+}
+
+func (m *regexMatcher) compile(h string) (*regexp.Regexp, error) {
+	return m.cache.get(h, func() (*regexp.Regexp, error) {
+		return strmatch.CompileDelimitedRegex(h, m.options.StartDelim, m.options.EndDelim)
+	})
+}
+
 func (m *regexMatcher) match(def []string, val string) (bool, error) {
+	match, _, err := m.matchCaptures(def, val, false)
+	return match, err
+}
+
+// MatchPolicyCaptures fulfills CaptureMatcher, capturing the groups of the first regular expression matching
+// val. Wildcard patterns capture nothing.
+func (m *regexMatcher) MatchPolicyCaptures(p Policy, def []string, val string) (bool, Captures, error) {
+	if def == nil {
+		return true, nil, nil
+	}
+
+	return m.matchCaptures(def, val, true)
+}
+
+func (m *regexMatcher) matchCaptures(def []string, val string, capture bool) (bool, Captures, error) {
 	for _, h := range def {
-		if strings.Count(h, m.startDelim) == 0 {
+		if !m.delimited(h) {
 			if strmatch.MatchWildcard(h, val) {
-				return true, nil
+				return true, nil, nil
 			}
 
 			continue
 		}
 
-		var reg *regexp.Regexp
-		var err error
+		reg, err := m.compile(h)
+		if err != nil {
+			return false, nil, err
+		}
 
-		reg, ok := m.pat[h]
-		if !ok {
-			reg, err = strmatch.CompileDelimitedRegex(val, '<', '>')
-			if err != nil {
-				return false, err
+		if !capture {
+			if reg.MatchString(val) {
+				return true, nil, nil
 			}
 
-			m.pat[h] = reg
+			continue
 		}
 
-		if reg.MatchString(val) {
-			return true, nil
+		if sub := reg.FindStringSubmatch(val); sub != nil {
+			return true, regexCaptures(reg, sub), nil
 		}
 	}
 
-	return false, nil
+	return false, nil, nil
+}
+
+// regexCaptures keys the groups of a match by number and, for named groups, by name.
+func regexCaptures(reg *regexp.Regexp, sub []string) Captures {
+	if len(sub) < 2 {
+		return nil
+	}
+
+	caps := make(Captures, len(sub)-1)
+
+	for i, name := range reg.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+
+		caps[strconv.Itoa(i)] = sub[i]
+
+		if name != "" {
+			caps[name] = sub[i]
+		}
+	}
+
+	return caps
+}
+
+// regexCache holds compiled patterns, and the errors of invalid ones, evicting the least recently used entry
+// once full.
+type regexCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+type regexEntry struct {
+	pattern string
+	re      *regexp.Regexp
+	err     error
+}
+
+func newRegexCache(size int) *regexCache {
+	return &regexCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached result for pattern, calling compile outside the lock on a miss.
+func (c *regexCache) get(pattern string, compile func() (*regexp.Regexp, error)) (*regexp.Regexp, error) {
+	if c.size <= 0 {
+		return compile()
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(el)
+		e := el.Value.(*regexEntry)
+		c.mu.Unlock()
+
+		return e.re, e.err
+	}
+	c.mu.Unlock()
+
+	re, err := compile()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[pattern]; !ok {
+		c.entries[pattern] = c.order.PushFront(&regexEntry{pattern: pattern, re: re, err: err})
+
+		if c.order.Len() > c.size {
+			last := c.order.Back()
+			c.order.Remove(last)
+			delete(c.entries, last.Value.(*regexEntry).pattern)
+		}
+	}
+
+	return re, err
+}
+
+// len returns the number of cached patterns.
+func (c *regexCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// GlobMatcherOptions configures the Matcher returned by NewGlobMatcher.
+type GlobMatcherOptions struct {
+	Separator rune
+	CacheSize int
+}
+
+// GlobMatcherOption is a typed function allowing updates to GlobMatcherOptions through functional options.
+type GlobMatcherOption func(*GlobMatcherOptions)
+
+// NewGlobMatcherOptions returns GlobMatcherOptions configured with the provided functional options. Segments are
+// divided by / by default.
+func NewGlobMatcherOptions(opts ...GlobMatcherOption) GlobMatcherOptions {
+	options := GlobMatcherOptions{
+		Separator: '/',
+		CacheSize: DefaultRegexCacheSize,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// GlobSeparator sets the rune dividing patterns and values into segments, such as /, : or .
+func GlobSeparator(sep rune) GlobMatcherOption {
+	return func(o *GlobMatcherOptions) {
+		o.Separator = sep
+	}
+}
+
+// GlobCacheSize sets the number of compiled patterns kept, the least recently used being evicted first. A size
+// of zero or less disables the cache.
+func GlobCacheSize(n int) GlobMatcherOption {
+	return func(o *GlobMatcherOptions) {
+		o.CacheSize = n
+	}
+}
+
+type globMatcher struct {
+	options GlobMatcherOptions
+	cache   *regexCache
+}
+
+// NewGlobMatcher returns a Matcher using segment aware globs, in which * stays within a segment and ** spans
+// segments. See strmatch.CompileGlob for the pattern syntax. It is safe for concurrent use.
+func NewGlobMatcher(opts ...GlobMatcherOption) Matcher {
+	options := NewGlobMatcherOptions(opts...)
+
+	return &globMatcher{
+		options: options,
+		cache:   newRegexCache(options.CacheSize),
+	}
+}
+
+// MatchPolicy evaluates true when the provided val glob matches at least one element in def.
+// If def is nil, a match is assumed against any value.
+func (m *globMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
+	return m.match(def, val)
+}
+
+// MatchRole evaluates true when the provided val glob matches at least one role in Role#EffectiveRoles.
+func (m *globMatcher) MatchRole(r *Role, val string) (bool, error) {
+	ef, err := r.EffectiveRoles()
+	if err != nil {
+		return false, err
+	}
+
+	def := make([]string, 0, len(ef))
+	for _, rr := range ef {
+		def = append(def, rr.ID)
+	}
+
+	return m.match(def, val)
+}
+
+// Precompile fulfills MatcherPrecompiler, compiling the patterns into the cache.
+func (m *globMatcher) Precompile(patterns ...string) error {
+	for _, h := range patterns {
+		if _, err := m.compile(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *globMatcher) compile(h string) (*regexp.Regexp, error) {
+	return m.cache.get(h, func() (*regexp.Regexp, error) {
+		return strmatch.CompileGlob(h, m.options.Separator)
+	})
+}
+
+func (m *globMatcher) match(def []string, val string) (bool, error) {
+	match, _, err := m.matchCaptures(def, val, false)
+	return match, err
+}
+
+// MatchPolicyCaptures fulfills CaptureMatcher, capturing what each wildcard, class and alternation of the
+// first pattern matching val matched, numbered from "1" in the order they appear.
+func (m *globMatcher) MatchPolicyCaptures(p Policy, def []string, val string) (bool, Captures, error) {
+	if def == nil {
+		return true, nil, nil
+	}
+
+	return m.matchCaptures(def, val, true)
+}
+
+func (m *globMatcher) matchCaptures(def []string, val string, capture bool) (bool, Captures, error) {
+	for _, h := range def {
+		reg, err := m.compile(h)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if !capture {
+			if reg.MatchString(val) {
+				return true, nil, nil
+			}
+
+			continue
+		}
+
+		if sub := reg.FindStringSubmatch(val); sub != nil {
+			return true, regexCaptures(reg, sub), nil
+		}
+	}
+
+	return false, nil, nil
 }
@@ -0,0 +1,252 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegexMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []RegexMatcherOption
+		def     []string
+		val     string
+		want    bool
+		wantErr bool
+	}{
+		{"regex", nil, []string{"documents/<[0-9]+>"}, "documents/42", true, false},
+		{"regex mismatch", nil, []string{"documents/<[0-9]+>"}, "documents/abc", false, false},
+		{"value is not a pattern", nil, []string{"documents/<[0-9]+>"}, "documents/<.*>", false, false},
+		{"wildcard", nil, []string{"documents/*"}, "documents/abc", true, false},
+		{"nil def", nil, nil, "anything", true, false},
+		{"second element", nil, []string{"users/*", "documents/<[a-z]+>"}, "documents/abc", true, false},
+		{"multibyte", nil, []string{"文書/<[0-9]+>"}, "文書/7", true, false},
+		{"custom delimiters", []RegexMatcherOption{RegexDelimiters('{', '}')}, []string{"documents/{[0-9]+}"}, "documents/7", true, false},
+		{"custom delimiters leave others literal", []RegexMatcherOption{RegexDelimiters('{', '}')}, []string{"documents/<[0-9]+>"}, "documents/<[0-9]+>", true, false},
+		{"invalid", nil, []string{"documents/<[0-9>"}, "documents/7", false, true},
+		{"uncached", []RegexMatcherOption{RegexCacheSize(0)}, []string{"documents/<[0-9]+>"}, "documents/42", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRegexMatcher(tt.opts...).MatchPolicy(nil, tt.def, tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("MatchPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexMatcherCacheBound(t *testing.T) {
+	m := NewRegexMatcher(RegexCacheSize(4)).(*regexMatcher)
+
+	for i := 0; i < 10; i++ {
+		h := fmt.Sprintf("documents/<%d[0-9]*>", i)
+		if ok, err := m.MatchPolicy(nil, []string{h}, fmt.Sprintf("documents/%d0", i)); err != nil || !ok {
+			t.Fatalf("MatchPolicy(%q) = %v, %v", h, ok, err)
+		}
+	}
+
+	if n := m.cache.len(); n != 4 {
+		t.Errorf("cache holds %d patterns, want 4", n)
+	}
+
+	// the most recently used patterns are kept
+	if _, ok := m.cache.entries["documents/<9[0-9]*>"]; !ok {
+		t.Error("most recent pattern was evicted")
+	}
+
+	if _, ok := m.cache.entries["documents/<0[0-9]*>"]; ok {
+		t.Error("least recent pattern was kept")
+	}
+}
+
+func TestRegexMatcherPrecompile(t *testing.T) {
+	m := NewRegexMatcher(RegexDelimiters('{', '}'))
+	pm := NewManager(ManagerMatcher(m))
+
+	p := MustNewPolicy(
+		PolicyName("documents"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("documents/{[0-9]+}"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.(*regexMatcher).cache.entries["documents/{[0-9]+}"]; !ok {
+		t.Error("Create() did not precompile the resource pattern")
+	}
+
+	// the manager validates patterns for its matcher, so < and > are plain text here
+	lt := MustNewPolicy(
+		PolicyName("lt"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("documents/<draft/{[0-9]+}"),
+		SetPolicyMatcher(m),
+	)
+
+	if err := pm.Create(lt); err != nil {
+		t.Errorf("Create() with custom delimiters error = %v", err)
+	}
+
+	if _, err := NewPolicy(
+		PolicyName("lt"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("documents/<draft/{[0-9]+}"),
+	); err == nil {
+		t.Error("NewPolicy() without a matcher accepted an unbalanced <regex>")
+	}
+
+	bad := MustNewPolicy(
+		PolicyName("bad"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("documents/{[0-9}"),
+	)
+
+	err := pm.Create(bad)
+	if err == nil || !strings.Contains(err.Error(), "resources[0]") {
+		t.Errorf("Create() with an invalid pattern error = %v", err)
+	}
+
+	set := MustNewPolicySet(
+		PolicySetName("bad_set"),
+		SetTarget(Target{Resources: []string{"{(}"}}),
+	)
+
+	if err := pm.CreateSet(set); err == nil {
+		t.Error("CreateSet() with an invalid target error = nil")
+	}
+}
+
+func TestRegexMatcherConcurrentEnforce(t *testing.T) {
+	m := NewRegexMatcher(RegexCacheSize(8))
+	pm := NewManager()
+
+	for i := 0; i < 16; i++ {
+		p := MustNewPolicy(
+			PolicyName(fmt.Sprintf("p%d", i)),
+			PolicyAllow(),
+			WithRole(NewRole("staff")),
+			SetActions("read"),
+			SetResources(fmt.Sprintf("documents/%d/<[0-9]+>", i)),
+		)
+
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewEnforcer(pm, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				req := NewRequest(fmt.Sprintf("documents/%d/%d", (g+i)%16, i), "read", "staff", "", nil)
+				if err := e.Enforce(req); err != nil {
+					t.Errorf("Enforce(%s) = %v", req.Resource, err)
+
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+func TestGlobMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []GlobMatcherOption
+		def     []string
+		val     string
+		want    bool
+		wantErr bool
+	}{
+		{"segment", nil, []string{"articles/*"}, "articles/1", true, false},
+		{"star stays in segment", nil, []string{"articles/*"}, "articles/1/comments/9", false, false},
+		{"double star", nil, []string{"articles/**"}, "articles/1/comments/9", true, false},
+		{"alternation", nil, []string{"{articles,posts}/[0-9]*"}, "posts/42", true, false},
+		{"nil def", nil, nil, "anything", true, false},
+		{"colon", []GlobMatcherOption{GlobSeparator(':')}, []string{"docs:*"}, "docs:1:read", false, false},
+		{"colon slash", []GlobMatcherOption{GlobSeparator(':')}, []string{"docs:*"}, "docs:1/read", true, false},
+		{"dot", []GlobMatcherOption{GlobSeparator('.')}, []string{"*.example.com"}, "a.b.example.com", false, false},
+		{"invalid", nil, []string{"{articles"}, "articles", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGlobMatcher(tt.opts...).MatchPolicy(nil, tt.def, tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("MatchPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcerMatcher(t *testing.T) {
+	pm := NewManager()
+
+	p := MustNewPolicy(
+		PolicyName("articles"),
+		PolicyAllow(),
+		WithRole(NewRole("editor")),
+		SetActions("edit"),
+		SetResources("articles/*"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	wildcard, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	glob, err := NewDefaultEnforcer(pm, EnforcerMatcher(NewGlobMatcher()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment := NewRequest("articles/1/comments/9", "edit", "editor", "", nil)
+
+	if err := wildcard.Enforce(comment); err != nil {
+		t.Errorf("wildcard Enforce() = %v, want allowed", err)
+	}
+
+	if err := glob.Enforce(comment); err == nil {
+		t.Error("glob Enforce() allowed a request outside the segment")
+	}
+
+	if err := glob.Enforce(NewRequest("articles/1", "edit", "editor", "", nil)); err != nil {
+		t.Errorf("glob Enforce() = %v, want allowed", err)
+	}
+}
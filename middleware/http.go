@@ -1,15 +1,113 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/conditions"
 )
 
+// AuthContextFunc returns the authentication context of an HTTP request, reporting false when the request is
+// not authenticated. An error rejects the request as unauthorized.
+type AuthContextFunc func(*http.Request) (conditions.AuthContext, bool, error)
+
+// HTTPOptions configures the HTTP middleware. The address of the client is added to the request metadata
+// under ClientIPKey, trusting X-Forwarded-For headers only as far as they were added by TrustedProxies. The
+// authentication context returned by AuthContext is added under AuthKey for the auth conditions, and requests
+// whose context cannot be read are answered with 401 Unauthorized.
+type HTTPOptions struct {
+	TrustedProxies *conditions.IPRanges
+	ClientIPKey    string
+	AuthContext    AuthContextFunc
+	AuthKey        string
+}
+
+// HTTPOption is a typed function allowing updates to HTTPOptions through functional options.
+type HTTPOption func(*HTTPOptions)
+
+// NewHTTPOptions returns HTTPOptions configured with the provided functional options.
+func NewHTTPOptions(opts ...HTTPOption) HTTPOptions {
+	o := HTTPOptions{
+		ClientIPKey: "client_ip",
+		AuthContext: ContextAuth,
+		AuthKey:     "auth",
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// HTTPTrustedProxies sets the proxies whose X-Forwarded-For entries are trusted.
+func HTTPTrustedProxies(r *conditions.IPRanges) HTTPOption {
+	return func(o *HTTPOptions) {
+		o.TrustedProxies = r
+	}
+}
+
+// HTTPClientIPKey sets the metadata key the client address is stored under.
+func HTTPClientIPKey(key string) HTTPOption {
+	return func(o *HTTPOptions) {
+		o.ClientIPKey = key
+	}
+}
+
+// HTTPAuthContext sets the function reading the authentication context of requests, ContextAuth by default.
+func HTTPAuthContext(fn AuthContextFunc) HTTPOption {
+	return func(o *HTTPOptions) {
+		o.AuthContext = fn
+	}
+}
+
+// HTTPAuthKey sets the metadata key the authentication context is stored under.
+func HTTPAuthKey(key string) HTTPOption {
+	return func(o *HTTPOptions) {
+		o.AuthKey = key
+	}
+}
+
+// ContextAuth is an AuthContextFunc returning the context stored in the request context with
+// conditions.WithAuthContext by an earlier authentication middleware.
+func ContextAuth(r *http.Request) (conditions.AuthContext, bool, error) {
+	ac, ok := conditions.AuthContextFromContext(r.Context())
+
+	return ac, ok, nil
+}
+
+// ClaimsAuth returns an AuthContextFunc reading the authentication context from token claims returned by
+// claims, such as those of a verified ID token. Claims that cannot be read, such as a malformed auth_time, are an
+// error.
+func ClaimsAuth(claims func(*http.Request) (map[string]interface{}, bool)) AuthContextFunc {
+	return func(r *http.Request) (conditions.AuthContext, bool, error) {
+		c, ok := claims(r)
+		if !ok {
+			return conditions.AuthContext{}, false, nil
+		}
+
+		ac, err := conditions.AuthContextFromClaims(c)
+		if err != nil {
+			return conditions.AuthContext{}, false, fmt.Errorf("reading auth claims: %w", err)
+		}
+
+		return ac, true, nil
+	}
+}
+
 // NewHTTPMiddleware returns an http handler that evaluates policy before returning child handler.
-func NewHTTPMiddleware(e redtape.Enforcer, h http.Handler) http.Handler {
+func NewHTTPMiddleware(e redtape.Enforcer, h http.Handler, opts ...HTTPOption) http.Handler {
+	o := NewHTTPOptions(opts...)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req := redtape.NewRequestWithContext(r.Context(), r.URL.Path, r.Method, "", "", requestMetadata(r))
+		meta, err := requestMetadata(r, o)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		req := redtape.NewRequestWithContext(r.Context(), r.URL.Path, r.Method, "", "", meta)
 
 		if err := e.Enforce(req); err != nil {
 			http.Error(w, err.Error(), http.StatusForbidden)
@@ -20,12 +118,31 @@ func NewHTTPMiddleware(e redtape.Enforcer, h http.Handler) http.Handler {
 	})
 }
 
-func requestMetadata(r *http.Request) map[string]interface{} {
-	return map[string]interface{}{
-		"referer":    r.Referer,
-		"cookies":    r.Cookies(),
-		"user_agent": r.UserAgent(),
-		"url":        r.URL.String,
-		"headers":    r.Header,
+func requestMetadata(r *http.Request, o HTTPOptions) (map[string]interface{}, error) {
+	meta := map[string]interface{}{
+		"referer":     r.Referer,
+		"cookies":     r.Cookies(),
+		"user_agent":  r.UserAgent(),
+		"url":         r.URL.String,
+		"headers":     r.Header,
+		"remote_addr": r.RemoteAddr,
 	}
+
+	// an unreadable client address is left out, failing ip_allow and making ip_deny indeterminate
+	if ip, err := conditions.RequestClientIP(r, o.TrustedProxies); err == nil {
+		meta[o.ClientIPKey] = ip.String()
+	}
+
+	if o.AuthContext != nil {
+		ac, ok, err := o.AuthContext(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			meta[o.AuthKey] = ac
+		}
+	}
+
+	return meta, nil
 }
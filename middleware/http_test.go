@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/conditions"
+)
+
+// enforcerFunc adapts a function to the redtape.Enforcer interface.
+type enforcerFunc func(*redtape.Request) error
+
+func (f enforcerFunc) Enforce(r *redtape.Request) error {
+	return f(r)
+}
+
+func TestHTTPMiddlewareAuth(t *testing.T) {
+	authTime := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		wantCode int
+		wantAuth bool
+	}{
+		{"claims", map[string]interface{}{"amr": []interface{}{"pwd"}, "auth_time": float64(authTime.Unix())}, http.StatusOK, true},
+		{"no claims", nil, http.StatusOK, false},
+		{"malformed claims", map[string]interface{}{"auth_time": "yesterday"}, http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAuth interface{}
+
+			e := enforcerFunc(func(r *redtape.Request) error {
+				gotAuth = r.Metadata()["auth"]
+				return nil
+			})
+
+			claims := func(*http.Request) (map[string]interface{}, bool) {
+				return tt.claims, tt.claims != nil
+			}
+
+			h := NewHTTPMiddleware(e, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}), HTTPAuthContext(ClaimsAuth(claims)))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("ServeHTTP() code = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			ac, ok := gotAuth.(conditions.AuthContext)
+			if ok != tt.wantAuth {
+				t.Fatalf("metadata auth = %#v, want an AuthContext %v", gotAuth, tt.wantAuth)
+			}
+
+			if ok && !ac.Time.Equal(authTime) {
+				t.Errorf("AuthContext.Time = %v, want %v", ac.Time, authTime)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareContextAuth(t *testing.T) {
+	var gotAuth interface{}
+
+	e := enforcerFunc(func(r *redtape.Request) error {
+		gotAuth = r.Metadata()["auth"]
+		return nil
+	})
+
+	h := NewHTTPMiddleware(e, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	ac := conditions.AuthContext{Methods: []string{"mfa"}, Level: 2}
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	req = req.WithContext(conditions.WithAuthContext(req.Context(), ac))
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got, ok := gotAuth.(conditions.AuthContext); !ok || got.Level != 2 {
+		t.Errorf("metadata auth = %#v, want %#v", gotAuth, ac)
+	}
+}
@@ -3,6 +3,7 @@ package redtape
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/fatih/structs"
 )
@@ -34,27 +35,44 @@ type Policy interface {
 	ID() string
 	Description() string
 	Roles() []*Role
+	RoleRefs() []string
 	Resources() []string
 	Actions() []string
 	Scopes() []string
 	Conditions() Conditions
 	Effect() PolicyEffect
 	Context() context.Context
+	Labels() map[string]string
+	Owner() string
+	CreatedAt() time.Time
+	UpdatedAt() time.Time
+}
+
+// PolicyTimestamper is implemented by policies whose timestamps can be maintained by a PolicyManager.
+// WithTimestamps returns a copy of the policy carrying the given timestamps, leaving the policy unchanged.
+type PolicyTimestamper interface {
+	WithTimestamps(created, updated time.Time) Policy
 }
 
 type policy struct {
 	id         string
 	desc       string
 	roles      []*Role
+	roleRefs   []string
 	resources  []string
 	actions    []string
 	scopes     []string
 	conditions Conditions
 	effect     PolicyEffect
 	ctx        context.Context
+	labels     map[string]string
+	owner      string
+	createdAt  time.Time
+	updatedAt  time.Time
 }
 
-// NewPolicy returns a default policy implementation from a set of provided options.
+// NewPolicy returns a default policy implementation from a set of provided options. The options are validated
+// first and all errors found are returned together as a *ValidationError.
 func NewPolicy(opts ...PolicyOption) (Policy, error) {
 	o := NewPolicyOptions(opts...)
 
@@ -62,14 +80,30 @@ func NewPolicy(opts ...PolicyOption) (Policy, error) {
 		id:        o.Name,
 		desc:      o.Description,
 		roles:     o.Roles,
+		roleRefs:  o.RoleRefs,
 		resources: o.Resources,
 		actions:   o.Actions,
+		scopes:    o.Scopes,
 		effect:    NewPolicyEffect(o.Effect),
 		ctx:       o.Context,
+		labels:    o.Labels,
+		owner:     o.Owner,
+		createdAt: o.CreatedAt,
+		updatedAt: o.UpdatedAt,
 	}
 
-	conds, err := NewConditions(o.Conditions, nil)
-	if err != nil {
+	if o.RoleManager != nil {
+		resolved, err := ResolveRoleRefs(o.RoleManager, o.RoleRefs)
+		if err != nil {
+			return nil, err
+		}
+
+		p.roles = append(append([]*Role{}, o.Roles...), resolved...)
+		p.roleRefs = nil
+	}
+
+	v, conds := validatePolicyOptions(o, o.Registry)
+	if err := v.Err(); err != nil {
 		return nil, err
 	}
 
@@ -95,20 +129,28 @@ func (p *policy) MarshalJSON() ([]byte, error) {
 		Name:        p.id,
 		Description: p.desc,
 		Roles:       p.roles,
+		RoleRefs:    p.roleRefs,
 		Resources:   p.resources,
 		Actions:     p.actions,
+		Scopes:      p.scopes,
 		Effect:      string(p.effect),
+		Labels:      p.labels,
+		Owner:       p.owner,
+		CreatedAt:   p.createdAt,
+		UpdatedAt:   p.updatedAt,
 	}
 
 	structs.DefaultTagName = "json"
 
 	copts := make([]ConditionOptions, 0, len(p.conditions))
 	for k, c := range p.conditions {
+		c, sel := unwrapCondition(c)
 		cov := structs.Map(c)
 		co := ConditionOptions{
-			Name:    k,
-			Type:    c.Name(),
-			Options: cov,
+			Name:     k,
+			Type:     c.Name(),
+			Selector: sel,
+			Options:  cov,
 		}
 		copts = append(copts, co)
 	}
@@ -133,6 +175,11 @@ func (p *policy) Roles() []*Role {
 	return p.roles
 }
 
+// RoleRefs returns the ids of roles the policy applies to that are resolved through a RoleManager.
+func (p *policy) RoleRefs() []string {
+	return p.roleRefs
+}
+
 // Resources returns the resources the policy applies to.
 func (p *policy) Resources() []string {
 	return p.resources
@@ -162,17 +209,55 @@ func (p *policy) Effect() PolicyEffect {
 	return p.effect
 }
 
+// Labels returns the key/value labels attached to the policy.
+func (p *policy) Labels() map[string]string {
+	return p.labels
+}
+
+// Owner returns the owner of the policy.
+func (p *policy) Owner() string {
+	return p.owner
+}
+
+// CreatedAt returns the time the policy was first stored.
+func (p *policy) CreatedAt() time.Time {
+	return p.createdAt
+}
+
+// UpdatedAt returns the time the policy was last stored.
+func (p *policy) UpdatedAt() time.Time {
+	return p.updatedAt
+}
+
+// WithTimestamps fulfills PolicyTimestamper.
+func (p *policy) WithTimestamps(created, updated time.Time) Policy {
+	pc := *p
+	pc.createdAt = created
+	pc.updatedAt = updated
+
+	return &pc
+}
+
 // PolicyOptions struct allows different Policy implementations to be configured with marshalable data.
+// Roles and RoleRefs share the "roles" JSON key, see MarshalJSON and UnmarshalJSON.
 type PolicyOptions struct {
 	Name        string             `json:"name"`
 	Description string             `json:"description"`
 	Roles       []*Role            `json:"roles"`
+	RoleRefs    []string           `json:"-"`
+	RoleManager RoleManager        `json:"-"`
 	Resources   []string           `json:"resources"`
 	Actions     []string           `json:"actions"`
 	Scopes      []string           `json:"scopes"`
 	Conditions  []ConditionOptions `json:"conditions"`
 	Effect      string             `json:"effect"`
+	Labels      map[string]string  `json:"labels"`
+	Owner       string             `json:"owner"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
 	Context     context.Context    `json:"-"`
+	Registry    ConditionRegistry  `json:"-"`
+	Matcher     Matcher            `json:"-"`
 }
 
 // PolicyOption is a typed function allowing updates to PolicyOptions through functional options.
@@ -250,6 +335,31 @@ func SetScopes(s ...string) PolicyOption {
 	}
 }
 
+// PolicyOwner sets the policy Owner option.
+func PolicyOwner(owner string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Owner = owner
+	}
+}
+
+// SetLabels replaces the option Labels with the provided values.
+func SetLabels(labels map[string]string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Labels = labels
+	}
+}
+
+// WithLabel adds a label to the Labels option.
+func WithLabel(key, value string) PolicyOption {
+	return func(o *PolicyOptions) {
+		if o.Labels == nil {
+			o.Labels = make(map[string]string)
+		}
+
+		o.Labels[key] = value
+	}
+}
+
 // SetContext sets the Context option.
 func SetContext(ctx context.Context) PolicyOption {
 	return func(o *PolicyOptions) {
@@ -270,3 +380,34 @@ func WithRole(r *Role) PolicyOption {
 		o.Roles = append(o.Roles, r)
 	}
 }
+
+// WithRoleRefs adds role ids to the RoleRefs option.
+func WithRoleRefs(ids ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.RoleRefs = append(o.RoleRefs, ids...)
+	}
+}
+
+// SetRoleManager sets the RoleManager used to resolve RoleRefs when the policy is built. Without it,
+// references are resolved by the Enforcer at evaluation time.
+func SetRoleManager(rm RoleManager) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.RoleManager = rm
+	}
+}
+
+// SetConditionRegistry sets the ConditionRegistry the conditions of the policy are built from. Without one the
+// default registry returned by NewConditionRegistry is used.
+func SetConditionRegistry(reg ConditionRegistry) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Registry = reg
+	}
+}
+
+// SetPolicyMatcher sets the Matcher the patterns of the policy are validated for. Without one, patterns are
+// checked for the <regex> syntax of the default regex Matcher.
+func SetPolicyMatcher(m Matcher) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Matcher = m
+	}
+}
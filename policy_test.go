@@ -2,8 +2,11 @@ package redtape
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func newConditions() Conditions {
@@ -33,9 +36,13 @@ func Test_policy_MarshalJSON(t *testing.T) {
 		roles      []*Role
 		resources  []string
 		actions    []string
+		scopes     []string
 		conditions Conditions
 		effect     PolicyEffect
 		ctx        context.Context
+		labels     map[string]string
+		owner      string
+		createdAt  time.Time
 	}
 	tests := []struct {
 		name    string
@@ -59,8 +66,24 @@ func Test_policy_MarshalJSON(t *testing.T) {
 				},
 				conditions: newConditions(),
 				effect:     PolicyEffectAllow,
+				labels:     map[string]string{"team": "payments"},
+				owner:      "alice",
+				createdAt:  time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
 			},
-			want:    []byte(`{"name":"test_policy","description":"testing policy","roles":[{"id":"test_role","name":"","description":"","roles":null}],"resources":["test_res"],"actions":["test_action"],"scopes":null,"conditions":[{"name":"let-me-in","type":"bool","options":{"value":true}}],"effect":"allow"}`),
+			want:    []byte(`{"name":"test_policy","description":"testing policy","roles":[{"id":"test_role","name":"","description":"","roles":null}],"resources":["test_res"],"actions":["test_action"],"scopes":null,"conditions":[{"name":"let-me-in","type":"bool","options":{"value":true}}],"effect":"allow","labels":{"team":"payments"},"owner":"alice","created_at":"2021-06-01T12:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`),
+			wantErr: false,
+		},
+		{
+			name: "test_marshal_scopes",
+			fields: fields{
+				id:        "scoped_policy",
+				roles:     []*Role{NewRole("test_role")},
+				resources: []string{"test_res"},
+				actions:   []string{"test_action"},
+				scopes:    []string{"tenant:${meta.tenant}"},
+				effect:    PolicyEffectDeny,
+			},
+			want:    []byte(`{"name":"scoped_policy","description":"","roles":[{"id":"test_role","name":"","description":"","roles":null}],"resources":["test_res"],"actions":["test_action"],"scopes":["tenant:${meta.tenant}"],"conditions":[],"effect":"deny","labels":null,"owner":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`),
 			wantErr: false,
 		},
 	}
@@ -72,9 +95,13 @@ func Test_policy_MarshalJSON(t *testing.T) {
 				roles:      tt.fields.roles,
 				resources:  tt.fields.resources,
 				actions:    tt.fields.actions,
+				scopes:     tt.fields.scopes,
 				conditions: tt.fields.conditions,
 				effect:     tt.fields.effect,
 				ctx:        tt.fields.ctx,
+				labels:     tt.fields.labels,
+				owner:      tt.fields.owner,
+				createdAt:  tt.fields.createdAt,
 			}
 			got, err := p.MarshalJSON()
 			if (err != nil) != tt.wantErr {
@@ -87,3 +114,132 @@ func Test_policy_MarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []PolicyOption
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "valid",
+			opts: []PolicyOption{
+				PolicyName("valid"),
+				SetActions("read"),
+				SetResources("docs/<[0-9]+>"),
+				WithRole(NewRole("reader")),
+				PolicyAllow(),
+			},
+		},
+		{
+			name:     "empty",
+			errors:   []string{"name", "actions", "roles"},
+			warnings: []string{"effect", "resources"},
+		},
+		{
+			name: "bad fields",
+			opts: []PolicyOption{
+				PolicyName("bad"),
+				SetActions("read", "<[a-z>"),
+				SetResources("docs/<(>"),
+				WithRole(&Role{}),
+				WithRoleRefs(""),
+				SetPolicyEffect("maybe"),
+				WithCondition(ConditionOptions{Name: "mfa", Type: "bool"}),
+				WithCondition(ConditionOptions{Name: "mfa", Type: "bool"}),
+				WithCondition(ConditionOptions{Name: "geo", Type: "unknown"}),
+			},
+			errors: []string{
+				"effect",
+				"roles[0].id",
+				"roles[1]",
+				"actions[1]",
+				"resources[0]",
+				"conditions[1].name",
+				"conditions[2]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewPolicyOptions(tt.opts...)
+			v := ValidatePolicyOptions(o, nil)
+
+			if got := problemFields(v.Errors()); !reflect.DeepEqual(got, tt.errors) {
+				t.Errorf("ValidatePolicyOptions() errors = %v, want %v", got, tt.errors)
+			}
+
+			if got := problemFields(v.Warnings()); !reflect.DeepEqual(got, tt.warnings) {
+				t.Errorf("ValidatePolicyOptions() warnings = %v, want %v", got, tt.warnings)
+			}
+
+			_, err := NewPolicy(tt.opts...)
+			if (err != nil) != (len(tt.errors) > 0) {
+				t.Errorf("NewPolicy() error = %v, want errors %v", err, tt.errors)
+			}
+
+			var verr *ValidationError
+			if err != nil && !errors.As(err, &verr) {
+				t.Errorf("NewPolicy() error = %T, want *ValidationError", err)
+			}
+		})
+	}
+}
+
+func problemFields(problems []ValidationProblem) []string {
+	var fields []string
+	for _, p := range problems {
+		fields = append(fields, p.Field)
+	}
+
+	return fields
+}
+
+// NewPolicy and MarshalJSON used to drop the scopes of a policy, so scoped policies matched every scope.
+func TestPolicyScopes(t *testing.T) {
+	p := MustNewPolicy(
+		PolicyName("tenant_docs"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("documents"),
+		SetScopes("acme"),
+	)
+
+	if got := p.Scopes(); !reflect.DeepEqual(got, []string{"acme"}) {
+		t.Fatalf("Scopes() = %v, want [acme]", got)
+	}
+
+	b, err := p.(*policy).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var o PolicyOptions
+	if err := json.Unmarshal(b, &o); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(o.Scopes, []string{"acme"}) {
+		t.Errorf("MarshalJSON() scopes = %v, want [acme]: %s", o.Scopes, b)
+	}
+
+	pm := NewManager()
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("documents", "read", "staff", "acme")); err != nil {
+		t.Errorf("Enforce() in scope error = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("documents", "read", "staff", "globex")); err == nil {
+		t.Error("Enforce() out of scope error = nil")
+	}
+}
@@ -0,0 +1,214 @@
+package redtape
+
+import (
+	"encoding/json"
+)
+
+// Target restricts the requests a PolicySet applies to. Each field holds patterns evaluated by the enforcer
+// Matcher; an empty field matches any value.
+type Target struct {
+	Actions   []string `json:"actions"`
+	Resources []string `json:"resources"`
+	Scopes    []string `json:"scopes"`
+}
+
+// PolicySet groups policies and nested policy sets behind a shared Target and CombiningAlgorithm.
+type PolicySet interface {
+	ID() string
+	Description() string
+	Target() Target
+	Algorithm() CombiningAlgorithm
+	Policies() []Policy
+	Sets() []PolicySet
+}
+
+type policySet struct {
+	id        string
+	desc      string
+	target    Target
+	algorithm CombiningAlgorithm
+	policies  []Policy
+	sets      []PolicySet
+}
+
+// NewPolicySet returns a default PolicySet implementation from a set of provided options. Nested policies
+// and sets are built from their options in order.
+func NewPolicySet(opts ...PolicySetOption) (PolicySet, error) {
+	o := NewPolicySetOptions(opts...)
+
+	s := &policySet{
+		id:        o.Name,
+		desc:      o.Description,
+		target:    o.Target,
+		algorithm: NewCombiningAlgorithm(o.Algorithm),
+	}
+
+	for _, po := range o.Policies {
+		if po.Registry == nil {
+			po.Registry = o.Registry
+		}
+
+		p, err := NewPolicy(SetPolicyOptions(po))
+		if err != nil {
+			return nil, err
+		}
+
+		s.policies = append(s.policies, p)
+	}
+
+	for _, so := range o.Sets {
+		if so.Registry == nil {
+			so.Registry = o.Registry
+		}
+
+		ns, err := NewPolicySet(SetPolicySetOptions(so))
+		if err != nil {
+			return nil, err
+		}
+
+		s.sets = append(s.sets, ns)
+	}
+
+	return s, nil
+}
+
+// MustNewPolicySet returns a default policy set implementation or panics on error.
+func MustNewPolicySet(opts ...PolicySetOption) PolicySet {
+	s, err := NewPolicySet(opts...)
+	if err != nil {
+		panic("failed to create new policy set: " + err.Error())
+	}
+
+	return s
+}
+
+// MarshalJSON returns a JSON byte slice representation of the default policy set implementation.
+func (s *policySet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Target      Target      `json:"target"`
+		Algorithm   string      `json:"algorithm"`
+		Policies    []Policy    `json:"policies"`
+		Sets        []PolicySet `json:"sets"`
+	}{
+		Name:        s.id,
+		Description: s.desc,
+		Target:      s.target,
+		Algorithm:   string(s.algorithm),
+		Policies:    s.policies,
+		Sets:        s.sets,
+	})
+}
+
+// ID returns the policy set ID.
+func (s *policySet) ID() string {
+	return s.id
+}
+
+// Description returns the policy set Description.
+func (s *policySet) Description() string {
+	return s.desc
+}
+
+// Target returns the Target a request must match for the set to apply.
+func (s *policySet) Target() Target {
+	return s.target
+}
+
+// Algorithm returns the CombiningAlgorithm applied to the members of the set.
+func (s *policySet) Algorithm() CombiningAlgorithm {
+	return s.algorithm
+}
+
+// Policies returns the policies contained in the set.
+func (s *policySet) Policies() []Policy {
+	return s.policies
+}
+
+// Sets returns the policy sets nested in the set.
+func (s *policySet) Sets() []PolicySet {
+	return s.sets
+}
+
+// PolicySetOptions struct allows PolicySet implementations to be configured with marshalable data.
+type PolicySetOptions struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Target      Target             `json:"target"`
+	Algorithm   string             `json:"algorithm"`
+	Policies    []PolicyOptions    `json:"policies"`
+	Sets        []PolicySetOptions `json:"sets"`
+	Registry    ConditionRegistry  `json:"-"`
+}
+
+// PolicySetOption is a typed function allowing updates to PolicySetOptions through functional options.
+type PolicySetOption func(*PolicySetOptions)
+
+// NewPolicySetOptions returns PolicySetOptions configured with the provided functional options.
+func NewPolicySetOptions(opts ...PolicySetOption) PolicySetOptions {
+	options := PolicySetOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// SetPolicySetOptions is a PolicySetOption setting all PolicySetOptions to the provided values.
+func SetPolicySetOptions(opts PolicySetOptions) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		*o = opts
+	}
+}
+
+// PolicySetName sets the policy set Name option.
+func PolicySetName(n string) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Name = n
+	}
+}
+
+// PolicySetDescription sets the policy set Description option.
+func PolicySetDescription(d string) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Description = d
+	}
+}
+
+// SetTarget replaces the Target option.
+func SetTarget(t Target) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Target = t
+	}
+}
+
+// SetAlgorithm sets the CombiningAlgorithm option.
+func SetAlgorithm(alg CombiningAlgorithm) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Algorithm = string(alg)
+	}
+}
+
+// WithPolicyOptions adds a policy to the Policies option.
+func WithPolicyOptions(po PolicyOptions) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Policies = append(o.Policies, po)
+	}
+}
+
+// WithPolicySetOptions adds a nested policy set to the Sets option.
+func WithPolicySetOptions(so PolicySetOptions) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Sets = append(o.Sets, so)
+	}
+}
+
+// SetPolicySetRegistry sets the ConditionRegistry used to build the conditions of the policies in the set and
+// in its nested sets, unless they carry their own.
+func SetPolicySetRegistry(reg ConditionRegistry) PolicySetOption {
+	return func(o *PolicySetOptions) {
+		o.Registry = reg
+	}
+}
@@ -0,0 +1,177 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttributeProvider resolves named attributes that are not part of the request metadata, such as the
+// department of a subject held in a directory. Providers are only asked for attributes a condition needs.
+// Attribute reports false when the provider does not know the attribute.
+type AttributeProvider interface {
+	Attribute(ctx context.Context, r *Request, name string) (interface{}, bool, error)
+}
+
+// AttributeProviderFunc adapts a function to the AttributeProvider interface.
+type AttributeProviderFunc func(ctx context.Context, r *Request, name string) (interface{}, bool, error)
+
+// Attribute calls f.
+func (f AttributeProviderFunc) Attribute(ctx context.Context, r *Request, name string) (interface{}, bool, error) {
+	return f(ctx, r, name)
+}
+
+// attributeResolverKey identifies the attributeResolver embedded in the context of a request.
+type attributeResolverKey struct{}
+
+type attributeEntry struct {
+	val interface{}
+	ok  bool
+	err error
+}
+
+// attributeCall is an attribute being resolved, or resolved once done is closed.
+type attributeCall struct {
+	attributeEntry
+	done chan struct{}
+}
+
+// attributeChainKey identifies the names of the attributes whose providers are running in a request context.
+type attributeChainKey struct{}
+
+// attributeResolver asks providers for attributes on behalf of a single request and caches the answers, so
+// each attribute is fetched at most once however many conditions use it. Providers run without holding the
+// lock of the resolver and may resolve other attributes of the request themselves.
+type attributeResolver struct {
+	providers []AttributeProvider
+	timeout   time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*attributeCall
+}
+
+func newAttributeResolver(providers []AttributeProvider, timeout time.Duration) *attributeResolver {
+	return &attributeResolver{
+		providers: providers,
+		timeout:   timeout,
+		calls:     make(map[string]*attributeCall),
+	}
+}
+
+// resolve returns the attribute name from the first provider that knows it. Concurrent lookups of the same
+// attribute wait for the first one, while a provider asking for an attribute it is itself resolving fails.
+func (ar *attributeResolver) resolve(r *Request, name string) (interface{}, bool, error) {
+	chain, _ := nonNilContext(r.Context).Value(attributeChainKey{}).([]string)
+	for _, n := range chain {
+		if n == name {
+			return nil, false, fmt.Errorf("attribute %s depends on itself through %s", name, strings.Join(chain, ", "))
+		}
+	}
+
+	ar.mu.Lock()
+
+	if c, ok := ar.calls[name]; ok {
+		ar.mu.Unlock()
+		<-c.done
+
+		return c.val, c.ok, c.err
+	}
+
+	c := &attributeCall{done: make(chan struct{})}
+	ar.calls[name] = c
+
+	ar.mu.Unlock()
+
+	defer close(c.done)
+
+	chain = append(chain[:len(chain):len(chain)], name)
+
+	rc := *r
+	rc.Context = context.WithValue(nonNilContext(r.Context), attributeChainKey{}, chain)
+
+	for _, p := range ar.providers {
+		c.val, c.ok, c.err = ar.fetch(p, &rc, name)
+		if c.ok || c.err != nil {
+			break
+		}
+	}
+
+	return c.val, c.ok, c.err
+}
+
+// fetch asks p for an attribute. With a timeout, the call is abandoned once the deadline passes even if the
+// provider does not watch its context.
+func (ar *attributeResolver) fetch(p AttributeProvider, r *Request, name string) (interface{}, bool, error) {
+	ctx := nonNilContext(r.Context)
+
+	if ar.timeout <= 0 {
+		return p.Attribute(ctx, r, name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ar.timeout)
+	defer cancel()
+
+	done := make(chan attributeEntry, 1)
+
+	go func() {
+		var e attributeEntry
+		e.val, e.ok, e.err = p.Attribute(ctx, r, name)
+		done <- e
+	}()
+
+	select {
+	case e := <-done:
+		return e.val, e.ok, e.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+func nonNilContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
+
+// RequestAttribute returns the named attribute of r. During policy evaluation the "captures" attribute holds
+// the captures of the policy's matches, see CaptureMatcher. Otherwise request metadata is consulted first, then
+// the attribute providers of the enforcer evaluating the request, whose answers are cached for the rest of the
+// evaluation.
+func RequestAttribute(r *Request, name string) (interface{}, bool, error) {
+	if r == nil {
+		return nil, false, nil
+	}
+
+	if v, ok := localAttribute(r, name); ok {
+		return v, true, nil
+	}
+
+	if r.Context == nil {
+		return nil, false, nil
+	}
+
+	ar, ok := r.Context.Value(attributeResolverKey{}).(*attributeResolver)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return ar.resolve(r, name)
+}
+
+// localAttribute returns the named attribute r holds itself, the captures of its matches or a metadata value,
+// without asking attribute providers.
+func localAttribute(r *Request, name string) (interface{}, bool) {
+	if name == "captures" {
+		if caps := RequestCaptures(r); caps != nil {
+			return caps, true
+		}
+	}
+
+	v, ok := r.Metadata()[name]
+
+	return v, ok
+}
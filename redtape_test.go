@@ -2,7 +2,11 @@ package redtape
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -159,3 +163,457 @@ func (s *RedtapeSuite) TestCEnforce() {
 	err = e.Enforce(req)
 	s.Require().Error(err, "should be denied")
 }
+
+func (s *RedtapeSuite) TestDPolicySets() {
+	pm := NewManager()
+
+	viewer := NewRole("viewer")
+	admin := NewRole("admin")
+
+	baseline := MustNewPolicySet(
+		PolicySetName("platform_baseline"),
+		SetTarget(Target{Resources: []string{"platform/*"}}),
+		SetAlgorithm(FirstApplicable),
+		WithPolicyOptions(PolicyOptions{
+			Name:      "deny_viewer_write",
+			Roles:     []*Role{viewer},
+			Resources: []string{"platform/*"},
+			Actions:   []string{"write"},
+			Effect:    "deny",
+		}),
+		WithPolicyOptions(PolicyOptions{
+			Name:      "allow_admin",
+			Roles:     []*Role{admin},
+			Resources: []string{"platform/*"},
+			Actions:   []string{"read", "write"},
+			Effect:    "allow",
+		}),
+		WithPolicySetOptions(PolicySetOptions{
+			Name:      "team_payments",
+			Target:    Target{Resources: []string{"platform/payments*"}},
+			Algorithm: string(AllowOverrides),
+			Policies: []PolicyOptions{
+				{
+					Name:      "allow_viewer_read",
+					Roles:     []*Role{viewer},
+					Resources: []string{"platform/payments*"},
+					Actions:   []string{"read"},
+					Effect:    "allow",
+				},
+			},
+		}),
+	)
+
+	s.Require().NoError(pm.CreateSet(baseline))
+	s.Require().Error(pm.CreateSet(baseline), "should not register duplicate set")
+
+	got, err := pm.GetSet("platform_baseline")
+	s.Require().NoError(err)
+	s.Len(got.Sets(), 1)
+
+	e, err := NewEnforcer(pm, NewMatcher(), nil)
+	s.Require().NoError(err)
+
+	table := []struct {
+		name    string
+		req     *Request
+		allowed bool
+	}{
+		{"nested set allows viewer", NewRequest("platform/payments", "read", "viewer", ""), true},
+		{"first applicable denies viewer write", NewRequest("platform/payments", "write", "viewer", ""), false},
+		{"admin allowed to write", NewRequest("platform/payments", "write", "admin", ""), true},
+		{"viewer outside nested target", NewRequest("platform/billing", "read", "viewer", ""), false},
+		{"target does not match", NewRequest("other/payments", "read", "admin", ""), false},
+	}
+
+	for _, tt := range table {
+		err := e.Enforce(tt.req)
+		if tt.allowed {
+			s.NoError(err, tt.name)
+		} else {
+			s.Error(err, tt.name)
+		}
+	}
+}
+
+func (s *RedtapeSuite) TestEPolicyLabels() {
+	pm := NewManager()
+
+	base := func(opts ...PolicyOption) PolicyOptions {
+		return NewPolicyOptions(append([]PolicyOption{
+			SetActions("read"),
+			SetResources("ledger"),
+			WithRole(NewRole("reader")),
+			PolicyAllow(),
+		}, opts...)...)
+	}
+
+	popts := []PolicyOptions{
+		base(PolicyName("a"), PolicyOwner("payments-team"), WithLabel("team", "payments"), WithLabel("env", "prod")),
+		base(PolicyName("b"), WithLabel("team", "payments"), WithLabel("env", "dev")),
+		base(PolicyName("c"), WithLabel("team", "payments")),
+		base(PolicyName("d"), WithLabel("team", "platform")),
+	}
+
+	for _, po := range popts {
+		p := MustNewPolicy(SetPolicyOptions(po))
+		s.Require().NoError(pm.Create(p))
+		s.True(p.CreatedAt().IsZero(), "create should stamp a copy of the policy")
+	}
+
+	a, err := pm.Get("a")
+	s.Require().NoError(err)
+	s.Equal("payments-team", a.Owner())
+	s.False(a.CreatedAt().IsZero())
+	s.Equal(a.CreatedAt(), a.UpdatedAt())
+
+	created := a.CreatedAt()
+	s.Require().NoError(pm.Update(MustNewPolicy(SetPolicyOptions(popts[0]))))
+
+	a, err = pm.Get("a")
+	s.Require().NoError(err)
+	s.Equal(created, a.CreatedAt(), "update should preserve created timestamp")
+	s.False(a.UpdatedAt().Before(created))
+
+	table := []struct {
+		selector string
+		limit    int
+		offset   int
+		want     []string
+	}{
+		{"team=payments", 10, 0, []string{"a", "b", "c"}},
+		{"team=payments,env!=dev", 10, 0, []string{"a", "c"}},
+		{"team==payments,env", 10, 0, []string{"a", "b"}},
+		{"!env", 10, 0, []string{"c", "d"}},
+		{"team=payments", 2, 1, []string{"b", "c"}},
+		{"", 10, 3, []string{"d"}},
+	}
+
+	for _, tt := range table {
+		pols, err := pm.FindByLabels(tt.selector, tt.limit, tt.offset)
+		s.Require().NoError(err, tt.selector)
+
+		ids := make([]string, 0, len(pols))
+		for _, p := range pols {
+			ids = append(ids, p.ID())
+		}
+
+		s.Equal(tt.want, ids, tt.selector)
+	}
+
+	_, err = pm.FindByLabels("team=payments,=dev", 10, 0)
+	s.Error(err, "should reject malformed selector")
+}
+
+func (s *RedtapeSuite) TestFRoleRefs() {
+	rm := NewRoleManager()
+	s.Require().NoError(rm.Create(NewRole("editor", NewRole("viewer"))))
+
+	doc := []byte(`{
+		"name": "edit_docs",
+		"roles": ["editor", {"id": "auditor", "roles": [{"id": "reader"}]}],
+		"resources": ["docs/*"],
+		"actions": ["edit"],
+		"effect": "allow"
+	}`)
+
+	var opts PolicyOptions
+	s.Require().NoError(json.Unmarshal(doc, &opts))
+	s.Equal([]string{"editor"}, opts.RoleRefs)
+	s.Require().Len(opts.Roles, 1)
+	s.Equal("auditor", opts.Roles[0].ID)
+
+	b, err := json.Marshal(opts)
+	s.Require().NoError(err)
+	s.Contains(string(b), `"roles":[{"id":"auditor","name":"","description":"","roles":[{"id":"reader","name":"","description":"","roles":null}]},"editor"]`)
+
+	migrated, err := MigrateRoles(opts, rm)
+	s.Require().NoError(err)
+	s.Empty(migrated.Roles)
+	s.Equal([]string{"editor", "auditor"}, migrated.RoleRefs)
+
+	for _, id := range []string{"auditor", "reader"} {
+		_, err := rm.Get(id)
+		s.NoError(err, "migration should register %s", id)
+	}
+
+	p, err := NewPolicy(SetPolicyOptions(migrated), SetRoleManager(rm))
+	s.Require().NoError(err)
+	s.Len(p.Roles(), 2)
+	s.Empty(p.RoleRefs())
+
+	_, err = NewPolicy(SetPolicyOptions(opts), WithRoleRefs("missing"), SetRoleManager(rm))
+	s.Error(err, "should fail on dangling reference at load")
+
+	pm := NewManager()
+	s.Require().NoError(pm.Create(MustNewPolicy(SetPolicyOptions(migrated))))
+
+	e, err := NewEnforcer(pm, NewMatcher(), nil, EnforcerRoleManager(rm))
+	s.Require().NoError(err)
+	s.NoError(e.Enforce(NewRequest("docs/1", "edit", "viewer", "")), "viewer is resolved through editor")
+
+	s.Require().NoError(pm.Update(MustNewPolicy(SetPolicyOptions(migrated), WithRoleRefs("ghost"))))
+	s.Error(e.Enforce(NewRequest("docs/1", "edit", "viewer", "")), "should fail on dangling reference at evaluation")
+
+	unresolved, err := NewEnforcer(pm, NewMatcher(), nil)
+	s.Require().NoError(err)
+	s.Error(unresolved.Enforce(NewRequest("docs/1", "edit", "ghost", "")), "should not match references without a RoleManager")
+}
+
+func (s *RedtapeSuite) TestGTimeConditions() {
+	pm := NewManager()
+
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("business_hours"),
+		SetActions("refund"),
+		WithRole(NewRole("support")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "hours", Type: "time_of_day", Options: map[string]interface{}{
+			"start":    "09:00",
+			"end":      "17:00",
+			"timezone": "Europe/Berlin",
+		}}),
+		WithCondition(ConditionOptions{Name: "weekdays", Type: "day_of_week", Options: map[string]interface{}{
+			"days":     []string{"mon", "tue", "wed", "thu", "fri"},
+			"timezone": "Europe/Berlin",
+		}}),
+	)))
+
+	now := time.Date(2021, time.June, 7, 8, 30, 0, 0, time.UTC) // monday 10:30 in Berlin
+
+	e, err := NewEnforcer(pm, NewMatcher(), nil, EnforcerClock(func() time.Time { return now }))
+	s.Require().NoError(err)
+
+	req := NewRequest("orders/1", "refund", "support", "")
+	s.NoError(e.Enforce(req))
+	s.Nil(req.Context.Value(RequestClockKey{}), "enforce should not modify the request")
+
+	now = now.Add(8 * time.Hour)
+	s.Error(e.Enforce(req), "should deny after hours")
+
+	now = time.Date(2021, time.June, 5, 8, 30, 0, 0, time.UTC)
+	s.Error(e.Enforce(req), "should deny on saturday")
+
+	s.NoError(e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{
+		"hours":    "2021-06-04T11:00:00+02:00",
+		"weekdays": "2021-06-04T11:00:00+02:00",
+	})), "metadata time should take precedence over the clock")
+}
+
+type recordingAuditor struct {
+	effects []PolicyEffect
+	evals   []ConditionEvaluation
+}
+
+func (a *recordingAuditor) LogRequest(*Request) {}
+
+func (a *recordingAuditor) LogPolicyEffect(_ *Request, effect PolicyEffect) {
+	a.effects = append(a.effects, effect)
+}
+
+func (a *recordingAuditor) LogConditions(_ *Request, evals []ConditionEvaluation) {
+	a.evals = append(a.evals, evals...)
+}
+
+func (s *RedtapeSuite) TestHIndeterminateConditions() {
+	refund := func(name string, effect PolicyOption, conds ...ConditionOptions) Policy {
+		opts := []PolicyOption{
+			PolicyName(name),
+			SetActions("refund"),
+			WithRole(NewRole("support")),
+			effect,
+		}
+
+		for _, c := range conds {
+			opts = append(opts, WithCondition(c))
+		}
+
+		return MustNewPolicy(opts...)
+	}
+
+	limit := ConditionOptions{Name: "amount", Type: "numeric_lt", Options: map[string]interface{}{"value": 500}}
+
+	pm := NewManager()
+	s.Require().NoError(pm.Create(refund("small_refunds", PolicyAllow(), limit)))
+
+	aud := &recordingAuditor{}
+	e, err := NewEnforcer(pm, NewMatcher(), aud)
+	s.Require().NoError(err)
+
+	s.NoError(e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": 120})))
+	s.Require().Len(aud.evals, 1)
+	s.Equal("small_refunds", aud.evals[0].Policy)
+	s.Equal("amount", aud.evals[0].Condition)
+	s.True(aud.evals[0].Met)
+	s.Equal("120 < 500", aud.evals[0].Reason)
+
+	err = e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": "lots"}))
+	s.Require().Error(err)
+
+	var rerr *Error
+	s.Require().True(errors.As(err, &rerr), "%v", err)
+	s.Equal("small_refunds", rerr.RequestID())
+	s.Contains(rerr.Reason(), "could not be evaluated")
+	s.Contains(err.Error(), "not a number")
+	s.Equal([]PolicyEffect{PolicyEffectAllow, PolicyEffectDeny}, aud.effects)
+	s.Error(aud.evals[1].Err)
+
+	// an indeterminate allow does not override an explicit deny
+	s.Require().NoError(pm.Create(refund("no_refunds", PolicyDeny())))
+	err = e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": "lots"}))
+	s.Require().True(errors.As(err, &rerr), "%v", err)
+	s.Equal("no_refunds", rerr.RequestID())
+
+	// a condition that is not met decides its policy even when another condition fails to evaluate
+	s.Require().NoError(pm.Delete("no_refunds"))
+	s.Require().NoError(pm.Update(refund("small_refunds", PolicyAllow(), limit, ConditionOptions{
+		Name: "mfa", Type: "bool", Options: map[string]interface{}{"value": true},
+	})))
+	err = e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": "lots", "mfa": false}))
+	s.Require().True(errors.As(err, &rerr), "%v", err)
+	s.Contains(rerr.Reason(), "no matching policy")
+
+	// an indeterminate allow does not override an allow granted by another policy
+	s.Require().NoError(pm.Update(refund("small_refunds", PolicyAllow(), limit)))
+	s.Require().NoError(pm.Create(refund("lead_refunds", PolicyAllow())))
+	s.NoError(e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": "lots"})))
+
+	// an indeterminate deny might have denied, so it overrides the allows
+	s.Require().NoError(pm.Create(refund("blocked_refunds", PolicyDeny(), ConditionOptions{
+		Name: "amount", Type: "numeric_gt", Options: map[string]interface{}{"value": 10000},
+	})))
+	err = e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": "lots"}))
+	s.Require().True(errors.As(err, &rerr), "%v", err)
+	s.Equal("blocked_refunds", rerr.RequestID())
+	s.Contains(rerr.Reason(), "could not be evaluated")
+	s.NoError(e.Enforce(NewRequest("orders/1", "refund", "support", "", map[string]interface{}{"amount": 120})))
+}
+
+func (s *RedtapeSuite) TestIAttributeProviders() {
+	var mu sync.Mutex
+
+	calls := map[string]int{}
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return calls[name]
+	}
+
+	directory := AttributeProviderFunc(func(ctx context.Context, r *Request, name string) (interface{}, bool, error) {
+		mu.Lock()
+		calls[name]++
+		mu.Unlock()
+
+		switch name {
+		case "department":
+			return map[string]interface{}{"name": "eng", "cost_center": 42}, true, nil
+		case "manager":
+			// providers may resolve other attributes of the request
+			dept, _, err := RequestAttribute(r, "department")
+			if err != nil {
+				return nil, false, err
+			}
+
+			return dept.(map[string]interface{})["name"].(string) + "-lead", true, nil
+		case "loop":
+			return RequestAttribute(r, "loop")
+		case "slow":
+			<-ctx.Done()
+			return nil, false, ctx.Err()
+		case "hung":
+			time.Sleep(300 * time.Millisecond)
+			return "late", true, nil
+		}
+
+		return nil, false, nil
+	})
+
+	pm := NewManager()
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("eng_deploys"),
+		SetActions("deploy"),
+		WithRole(NewRole("dev")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "dept", Type: "expr", Options: map[string]interface{}{
+			"expression": `department.name == "eng"`,
+		}}),
+		WithCondition(ConditionOptions{Name: "cost", Type: "numeric_eq", Selector: "department.cost_center", Options: map[string]interface{}{
+			"value": 42,
+		}}),
+	)))
+
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("slow_reads"),
+		SetActions("read"),
+		WithRole(NewRole("dev")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "slow", Type: "string_equals", Options: map[string]interface{}{"value": "x"}}),
+	)))
+
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("lead_approvals"),
+		SetActions("approve"),
+		WithRole(NewRole("dev")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "manager", Type: "string_equals", Options: map[string]interface{}{"value": "eng-lead"}}),
+		WithCondition(ConditionOptions{Name: "flagged", Type: "bool", Options: map[string]interface{}{"value": false}}),
+	)))
+
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("loops"),
+		SetActions("loop"),
+		WithRole(NewRole("dev")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "loop", Type: "string_equals", Options: map[string]interface{}{"value": "x"}}),
+	)))
+
+	s.Require().NoError(pm.Create(MustNewPolicy(
+		PolicyName("hung_writes"),
+		SetActions("write"),
+		WithRole(NewRole("dev")),
+		PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "hung", Type: "string_equals", Options: map[string]interface{}{"value": "late"}}),
+	)))
+
+	e, err := NewEnforcer(pm, NewMatcher(), nil,
+		EnforcerAttributeProvider(directory),
+		EnforcerAttributeTimeout(20*time.Millisecond),
+	)
+	s.Require().NoError(err)
+
+	s.Error(e.Enforce(NewRequest("svc", "delete", "dev", "")))
+	s.Zero(count("department"), "providers should not be asked when no policy needs an attribute")
+
+	s.NoError(e.Enforce(NewRequest("svc", "deploy", "dev", "")))
+	s.Equal(1, count("department"), "attributes should be cached per request")
+	s.Zero(count("dept"), "conditions ignoring their value should not cause lookups")
+
+	s.NoError(e.Enforce(NewRequest("svc", "deploy", "dev", "")))
+	s.Equal(2, count("department"), "the cache should not outlive the request")
+
+	s.Error(e.Enforce(NewRequest("svc", "deploy", "dev", "", map[string]interface{}{
+		"department": map[string]interface{}{"name": "sales", "cost_center": 42},
+	})), "metadata should take precedence over providers")
+	s.Equal(2, count("department"))
+
+	err = e.Enforce(NewRequest("svc", "read", "dev", ""))
+	s.Require().Error(err)
+	s.Contains(err.Error(), context.DeadlineExceeded.Error())
+
+	s.Error(e.Enforce(NewRequest("svc", "approve", "dev", "")), "bool conditions should not take provided values")
+	s.Zero(count("flagged"))
+	s.Zero(count("manager"), "conditions run in name order and flagged, which is not met, comes first")
+
+	s.NoError(e.Enforce(NewRequest("svc", "approve", "dev", "", map[string]interface{}{"flagged": false})))
+	s.Equal(1, count("manager"))
+
+	err = e.Enforce(NewRequest("svc", "loop", "dev", ""))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "depends on itself")
+
+	start := time.Now()
+	err = e.Enforce(NewRequest("svc", "write", "dev", ""))
+	s.Require().Error(err)
+	s.Less(int64(time.Since(start)), int64(200*time.Millisecond), "a provider ignoring its context should be abandoned")
+}
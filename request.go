@@ -1,6 +1,9 @@
 package redtape
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Request represents a request to be matched against a policy set.
 type Request struct {
@@ -77,3 +80,30 @@ func RequestMetadataFromContext(ctx context.Context) RequestMetadata {
 
 	return md.(RequestMetadata)
 }
+
+// Clock returns the current time. It allows the time seen by conditions to be controlled, for example in tests.
+type Clock func() time.Time
+
+// RequestClockKey is a type to identify a Clock embedded in context.
+type RequestClockKey struct{}
+
+// NewRequestClockContext returns a copy of ctx carrying clock.
+func NewRequestClockContext(ctx context.Context, clock Clock) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, RequestClockKey{}, clock)
+}
+
+// RequestTime returns the time of the request as reported by the Clock embedded in its context, or the current
+// time when there is none.
+func RequestTime(r *Request) time.Time {
+	if r != nil && r.Context != nil {
+		if clock, ok := r.Context.Value(RequestClockKey{}).(Clock); ok && clock != nil {
+			return clock()
+		}
+	}
+
+	return time.Now()
+}
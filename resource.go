@@ -0,0 +1,382 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// DefaultResourceSchema describes resource names such as rn:docs:eu-west-1:acme:document/42. The region may be
+// empty for global services.
+var DefaultResourceSchema = MustNewResourceSchema("rn:{service}:{region?}:{tenant}:{type}/{id}")
+
+// ResourceSchema describes the fields of structured resource names. A schema is written as a template of
+// literal text and {field} placeholders, a trailing ? marking a field that may be empty. The template starts
+// with literal text telling its names apart from other values. Each field ends where the literal text
+// following it starts, so the last field holds the rest of the name.
+type ResourceSchema struct {
+	template string
+	prefix   string
+	fields   []string
+	optional []bool
+	// suffixes[i] is the literal text following fields[i]
+	suffixes []string
+}
+
+// NewResourceSchema returns the ResourceSchema described by template.
+func NewResourceSchema(template string) (*ResourceSchema, error) {
+	s := &ResourceSchema{template: template}
+	seen := make(map[string]bool)
+	rest := template
+
+	i := strings.IndexByte(rest, '{')
+	switch {
+	case i < 0:
+		return nil, fmt.Errorf("resource schema %q has no fields", template)
+	case i == 0:
+		return nil, fmt.Errorf("resource schema %q must start with literal text", template)
+	}
+
+	s.prefix, rest = rest[:i], rest[i:]
+
+	for len(rest) > 0 {
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return nil, fmt.Errorf("resource schema %q has an unclosed field", template)
+		}
+
+		name := rest[1:end]
+		opt := strings.HasSuffix(name, "?")
+		name = strings.TrimSuffix(name, "?")
+
+		if name == "" || strings.ContainsAny(name, "{") {
+			return nil, fmt.Errorf("resource schema %q has an invalid field name %q", template, rest[1:end])
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("resource schema %q repeats field %s", template, name)
+		}
+
+		seen[name] = true
+		rest = rest[end+1:]
+
+		lit := rest
+		if next := strings.IndexByte(rest, '{'); next >= 0 {
+			lit = rest[:next]
+
+			if lit == "" {
+				return nil, fmt.Errorf("resource schema %q has no separator after field %s", template, name)
+			}
+		}
+
+		if strings.ContainsAny(lit, "}*?") {
+			return nil, fmt.Errorf("resource schema %q has an invalid separator %q", template, lit)
+		}
+
+		rest = rest[len(lit):]
+
+		s.fields = append(s.fields, name)
+		s.optional = append(s.optional, opt)
+		s.suffixes = append(s.suffixes, lit)
+	}
+
+	return s, nil
+}
+
+// MustNewResourceSchema returns the ResourceSchema described by template or panics on error.
+func MustNewResourceSchema(template string) *ResourceSchema {
+	s, err := NewResourceSchema(template)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// String returns the template of the schema.
+func (s *ResourceSchema) String() string {
+	return s.template
+}
+
+// Fields returns the names of the fields of the schema in order.
+func (s *ResourceSchema) Fields() []string {
+	return append([]string(nil), s.fields...)
+}
+
+// Describes reports whether name starts like the names of the schema, so that it should be parsed by it.
+func (s *ResourceSchema) Describes(name string) bool {
+	return strings.HasPrefix(name, s.prefix)
+}
+
+// Parse splits a resource name into its fields, rejecting names that do not follow the schema, leave a
+// required field empty or contain wildcards. Only the last field may contain the text separating fields.
+func (s *ResourceSchema) Parse(name string) (ResourceName, error) {
+	rn, err := s.split(name, false)
+	if err != nil {
+		return ResourceName{}, err
+	}
+
+	for i, v := range rn.values {
+		if strings.ContainsAny(v, "*?") {
+			return ResourceName{}, fmt.Errorf("resource name %q: %s contains a wildcard", name, s.fields[i])
+		}
+	}
+
+	return rn, nil
+}
+
+// ParsePattern splits a resource name pattern into its fields. Each field may hold wildcards, which only
+// match within the field. A * taking the place of a whole field at the end of the pattern covers that field
+// and all the following ones, so rn:docs:* reads as rn:docs:*:*:*/* in the default schema.
+func (s *ResourceSchema) ParsePattern(pattern string) (ResourceName, error) {
+	return s.split(pattern, true)
+}
+
+// split divides name into the values of the fields. In a pattern, a * making up the rest of the name from the
+// start of a field fills that field and the following ones.
+func (s *ResourceSchema) split(name string, pattern bool) (ResourceName, error) {
+	if !strings.HasPrefix(name, s.prefix) {
+		return ResourceName{}, fmt.Errorf("resource name %q does not start with %q", name, s.prefix)
+	}
+
+	rest := name[len(s.prefix):]
+	values := make([]string, len(s.fields))
+
+	for i, lit := range s.suffixes {
+		if pattern && rest == "*" {
+			for j := i; j < len(values); j++ {
+				values[j] = "*"
+			}
+
+			break
+		}
+
+		var end int
+
+		switch {
+		case i == len(s.fields)-1:
+			if !strings.HasSuffix(rest, lit) {
+				return ResourceName{}, fmt.Errorf("resource name %q does not end with %q", name, lit)
+			}
+
+			end = len(rest) - len(lit)
+		default:
+			if end = strings.Index(rest, lit); end < 0 {
+				return ResourceName{}, fmt.Errorf("resource name %q is missing %q after %s", name, lit, s.fields[i])
+			}
+		}
+
+		values[i] = rest[:end]
+		rest = rest[end+len(lit):]
+
+		if values[i] == "" && !s.optional[i] {
+			return ResourceName{}, fmt.Errorf("resource name %q: %s is required", name, s.fields[i])
+		}
+
+		if i < len(s.fields)-1 && s.containsSeparator(values[i]) {
+			return ResourceName{}, fmt.Errorf("resource name %q: %s contains a separator", name, s.fields[i])
+		}
+	}
+
+	return ResourceName{schema: s, values: values}, nil
+}
+
+// containsSeparator tells whether v holds the literal text between fields, which only the last field may.
+func (s *ResourceSchema) containsSeparator(v string) bool {
+	for _, lit := range s.suffixes {
+		if lit != "" && strings.Contains(v, lit) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error when name is not a valid resource name of the schema.
+func (s *ResourceSchema) Validate(name string) error {
+	_, err := s.Parse(name)
+	return err
+}
+
+// ResourceName is a resource name, or name pattern, split into the fields of its ResourceSchema.
+type ResourceName struct {
+	schema *ResourceSchema
+	values []string
+}
+
+// Schema returns the schema the name was parsed by.
+func (n ResourceName) Schema() *ResourceSchema {
+	return n.schema
+}
+
+// Field returns the value of the named field, empty when the field is empty or not part of the schema.
+func (n ResourceName) Field(name string) string {
+	if n.schema == nil {
+		return ""
+	}
+
+	for i, f := range n.schema.fields {
+		if f == name {
+			return n.values[i]
+		}
+	}
+
+	return ""
+}
+
+// Fields returns the values of the fields by name.
+func (n ResourceName) Fields() map[string]string {
+	m := make(map[string]string, len(n.values))
+
+	if n.schema != nil {
+		for i, f := range n.schema.fields {
+			m[f] = n.values[i]
+		}
+	}
+
+	return m
+}
+
+// String joins the fields back into a resource name.
+func (n ResourceName) String() string {
+	if n.schema == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(n.schema.prefix)
+
+	for i, v := range n.values {
+		sb.WriteString(v)
+		sb.WriteString(n.schema.suffixes[i])
+	}
+
+	return sb.String()
+}
+
+// Match evaluates to true when name, of the same schema, matches the pattern n field by field by wildcard.
+func (n ResourceName) Match(name ResourceName) bool {
+	if n.schema != name.schema || n.schema == nil {
+		return false
+	}
+
+	for i, p := range n.values {
+		if !strmatch.MatchWildcard(p, name.values[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type resourceNameMatcher struct {
+	schema   *ResourceSchema
+	fallback Matcher
+}
+
+// NewResourceNameMatcher returns a Matcher comparing structured resource names field by field, so that a
+// wildcard never matches across fields. Patterns and values that start like the names of schema are parsed by
+// it, and a malformed one is an error; anything else, such as actions and roles, is left to fallback, the
+// DefaultMatcher when nil.
+func NewResourceNameMatcher(schema *ResourceSchema, fallback Matcher) Matcher {
+	if fallback == nil {
+		fallback = DefaultMatcher
+	}
+
+	return &resourceNameMatcher{
+		schema:   schema,
+		fallback: fallback,
+	}
+}
+
+// MatchPolicy evaluates true when the provided val matches at least one element in def, comparing resource
+// names field by field. If def is nil, a match is assumed against any value.
+func (m *resourceNameMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
+	match, _, err := m.match(p, def, val, false)
+	return match, err
+}
+
+// MatchPolicyCaptures fulfills CaptureMatcher. A resource name matched by a name pattern captures its fields
+// by name; other matches capture what the fallback Matcher does, when it is a CaptureMatcher.
+func (m *resourceNameMatcher) MatchPolicyCaptures(p Policy, def []string, val string) (bool, Captures, error) {
+	return m.match(p, def, val, true)
+}
+
+func (m *resourceNameMatcher) match(p Policy, def []string, val string, capture bool) (bool, Captures, error) {
+	if def == nil || !m.schema.Describes(val) {
+		return m.fallbackMatch(p, def, val, capture)
+	}
+
+	name, err := m.schema.Parse(val)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var rest []string
+
+	for _, h := range def {
+		if !m.schema.Describes(h) {
+			rest = append(rest, h)
+			continue
+		}
+
+		pat, err := m.schema.ParsePattern(h)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if pat.Match(name) {
+			if !capture {
+				return true, nil, nil
+			}
+
+			return true, Captures(name.Fields()), nil
+		}
+	}
+
+	if len(rest) == 0 {
+		return false, nil, nil
+	}
+
+	return m.fallbackMatch(p, rest, val, capture)
+}
+
+func (m *resourceNameMatcher) fallbackMatch(p Policy, def []string, val string, capture bool) (bool, Captures, error) {
+	if cm, ok := m.fallback.(CaptureMatcher); ok && capture {
+		return cm.MatchPolicyCaptures(p, def, val)
+	}
+
+	match, err := m.fallback.MatchPolicy(p, def, val)
+
+	return match, nil, err
+}
+
+// MatchRole delegates to the fallback Matcher.
+func (m *resourceNameMatcher) MatchRole(r *Role, val string) (bool, error) {
+	return m.fallback.MatchRole(r, val)
+}
+
+// Precompile fulfills MatcherPrecompiler, rejecting malformed resource name patterns and passing the others
+// to the fallback Matcher when it is a MatcherPrecompiler.
+func (m *resourceNameMatcher) Precompile(patterns ...string) error {
+	var rest []string
+
+	for _, h := range patterns {
+		if !m.schema.Describes(h) {
+			rest = append(rest, h)
+			continue
+		}
+
+		if _, err := m.schema.ParsePattern(h); err != nil {
+			return err
+		}
+	}
+
+	if pc, ok := m.fallback.(MatcherPrecompiler); ok {
+		return pc.Precompile(rest...)
+	}
+
+	return nil
+}
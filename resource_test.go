@@ -0,0 +1,197 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceSchema(t *testing.T) {
+	for _, template := range []string{
+		"",
+		"rn",
+		"{service}:{id}",
+		"rn:{service",
+		"rn:{service}{id}",
+		"rn:{service}:{service}",
+		"rn:{}",
+		"rn:{service}*{id}",
+	} {
+		if _, err := NewResourceSchema(template); err == nil {
+			t.Errorf("NewResourceSchema(%q) error = nil", template)
+		}
+	}
+
+	want := []string{"service", "region", "tenant", "type", "id"}
+	if got := DefaultResourceSchema.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+}
+
+func TestParseResourceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "rn:docs:eu-west-1:acme:document/42",
+			want: map[string]string{"service": "docs", "region": "eu-west-1", "tenant": "acme", "type": "document", "id": "42"},
+		},
+		{
+			name: "rn:iam::acme:user/teams/ops/alice",
+			want: map[string]string{"service": "iam", "region": "", "tenant": "acme", "type": "user", "id": "teams/ops/alice"},
+		},
+		{name: "arn:docs:eu:acme:document/42", wantErr: true},
+		{name: "rn:docs:eu:acme:document", wantErr: true},
+		{name: "rn:docs:eu:acme", wantErr: true},
+		{name: "rn:docs:eu::document/42", wantErr: true},
+		{name: "rn:docs:eu:acme:/42", wantErr: true},
+		{name: "rn:docs:eu:acme:document/", wantErr: true},
+		{name: "rn:docs:eu:acme:document/*", wantErr: true},
+		{name: "rn:docs:eu:acme:evil:document/42", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rn, err := DefaultResourceSchema.Parse(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := rn.Fields(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fields() = %v, want %v", got, tt.want)
+			}
+
+			if rn.String() != tt.name {
+				t.Errorf("String() = %q, want %q", rn.String(), tt.name)
+			}
+		})
+	}
+}
+
+func TestParseResourcePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{pattern: "rn:*", want: "rn:*:*:*:*/*"},
+		{pattern: "rn:docs:*", want: "rn:docs:*:*:*/*"},
+		{pattern: "rn:docs:eu:acme:*", want: "rn:docs:eu:acme:*/*"},
+		{pattern: "rn:docs:eu:acme:document/*", want: "rn:docs:eu:acme:document/*"},
+		{pattern: "rn:docs:eu*", wantErr: true},
+		{pattern: "rn:docs:*/42", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			rn, err := DefaultResourceSchema.ParsePattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && rn.String() != tt.want {
+				t.Errorf("String() = %q, want %q", rn.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := DefaultResourceSchema.Parse("rn:docs:*"); err == nil {
+		t.Error("Parse() of a pattern error = nil")
+	}
+}
+
+func TestResourceNameMatcher(t *testing.T) {
+	m := NewResourceNameMatcher(DefaultResourceSchema, nil)
+
+	tests := []struct {
+		name    string
+		def     []string
+		val     string
+		want    bool
+		wantErr bool
+	}{
+		{"exact", []string{"rn:docs:eu:acme:document/42"}, "rn:docs:eu:acme:document/42", true, false},
+		{"field wildcard", []string{"rn:docs:*:acme:document/*"}, "rn:docs:eu:acme:document/42", true, false},
+		{"empty region", []string{"rn:docs:*:acme:document/*"}, "rn:docs::acme:document/42", true, false},
+		{"wildcard stays in field", []string{"rn:docs:eu:acme:*/42"}, "rn:docs:eu:acme:document/x/42", false, false},
+		{"trailing wildcard covers the rest", []string{"rn:docs:*:acme:*"}, "rn:docs:eu:acme:document/x/42", true, false},
+		{"trailing wildcard keeps the tenant", []string{"rn:docs:*:acme:*"}, "rn:docs:eu:globex:document/42", false, false},
+		{"wildcard does not reach the tenant", []string{"rn:docs:eu*:document/*"}, "rn:docs:eu:acme:document/42", false, true},
+		{"other tenant", []string{"rn:docs:*:acme:document/*"}, "rn:docs:eu:globex:document/42", false, false},
+		{"prefix in field", []string{"rn:docs:eu-*:acme:document/4?"}, "rn:docs:eu-west-1:acme:document/42", true, false},
+		{"plain wildcard", []string{"*"}, "rn:docs:eu:acme:document/42", true, false},
+		{"second pattern", []string{"rn:iam:*:*:*/*", "rn:docs:*:*:*/*"}, "rn:docs:eu:acme:document/42", true, false},
+		{"not a name", []string{"read*"}, "read_all", true, false},
+		{"nil def", nil, "rn:docs:eu:acme:document/42", true, false},
+		{"malformed value", []string{"rn:docs:*:*:*/*"}, "rn:docs:eu", false, true},
+		{"service wildcard", []string{"rn:docs:*"}, "rn:docs::acme:document/42", true, false},
+		{"other service", []string{"rn:docs:*"}, "rn:iam::acme:user/alice", false, false},
+		{"any name", []string{"rn:*"}, "rn:iam::acme:user/alice", true, false},
+		{"malformed pattern", []string{"rn:docs:eu"}, "rn:docs:eu:acme:document/42", false, true},
+		{"wildcard ending a field", []string{"rn:docs:eu*"}, "rn:docs:eu:acme:document/42", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.MatchPolicy(nil, tt.def, tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("MatchPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	pm := NewManager(ManagerMatcher(m))
+
+	bad := MustNewPolicy(
+		PolicyName("bad"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("rn:docs:eu"),
+	)
+
+	if err := pm.Create(bad); err == nil {
+		t.Error("Create() with a malformed resource name error = nil")
+	}
+}
+
+func TestResourceNameEnforce(t *testing.T) {
+	schema := MustNewResourceSchema("urn:{service}:{tenant}:{type}/{id}")
+	pm := NewManager()
+
+	p := MustNewPolicy(
+		PolicyName("tenant_docs"),
+		PolicyAllow(),
+		WithRole(NewRole("staff")),
+		SetActions("read"),
+		SetResources("urn:docs:acme:*/*"),
+	)
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm, EnforcerMatcher(NewResourceNameMatcher(schema, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("urn:docs:acme:document/42", "read", "staff", "", nil)); err != nil {
+		t.Errorf("Enforce() = %v, want allowed", err)
+	}
+
+	if err := e.Enforce(NewRequest("urn:docs:acme-evil:document/42", "read", "staff", "", nil)); err == nil {
+		t.Error("Enforce() allowed another tenant")
+	}
+
+	// a plain wildcard would let the type match evil:document
+	if err := e.Enforce(NewRequest("urn:docs:acme:evil:document/42", "read", "staff", "", nil)); err == nil {
+		t.Error("Enforce() allowed a malformed resource name")
+	}
+}
@@ -0,0 +1,129 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// policyOptionsJSON aliases PolicyOptions to marshal it without recursing into its JSON methods.
+type policyOptionsJSON PolicyOptions
+
+// MarshalJSON emits the "roles" key as the embedded role trees of Roles followed by the role id references of
+// RoleRefs, so that a document unmarshals into the same roles and references.
+func (o PolicyOptions) MarshalJSON() ([]byte, error) {
+	roles := make([]interface{}, 0, len(o.Roles)+len(o.RoleRefs))
+	seen := make(map[string]bool)
+
+	for _, r := range o.Roles {
+		seen[r.ID] = true
+		roles = append(roles, r)
+	}
+
+	for _, id := range o.RoleRefs {
+		if !seen[id] {
+			seen[id] = true
+			roles = append(roles, id)
+		}
+	}
+
+	// name and description are repeated so that roles keeps its position in the document
+	return json.Marshal(struct {
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Roles       []interface{} `json:"roles"`
+		policyOptionsJSON
+	}{
+		Name:              o.Name,
+		Description:       o.Description,
+		Roles:             roles,
+		policyOptionsJSON: policyOptionsJSON(o),
+	})
+}
+
+// UnmarshalJSON accepts role id strings, which populate RoleRefs, and embedded role objects, which populate
+// Roles, under the "roles" key.
+func (o *PolicyOptions) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*policyOptionsJSON
+		Roles []json.RawMessage `json:"roles"`
+	}{
+		policyOptionsJSON: (*policyOptionsJSON)(o),
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	o.Roles = nil
+	o.RoleRefs = nil
+
+	for _, raw := range aux.Roles {
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`"`)) {
+			var id string
+			if err := json.Unmarshal(raw, &id); err != nil {
+				return err
+			}
+
+			o.RoleRefs = append(o.RoleRefs, id)
+
+			continue
+		}
+
+		var r Role
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+
+		o.Roles = append(o.Roles, &r)
+	}
+
+	return nil
+}
+
+// ResolveRoleRefs returns the roles referenced by ids from rm, failing on the first dangling reference.
+func ResolveRoleRefs(rm RoleManager, ids []string) ([]*Role, error) {
+	roles := make([]*Role, 0, len(ids))
+
+	for _, id := range ids {
+		r, err := rm.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("dangling role reference %s: %w", id, err)
+		}
+
+		roles = append(roles, r)
+	}
+
+	return roles, nil
+}
+
+// MigrateRoles converts the embedded role trees of a policy document into role references. Embedded roles,
+// including their sub roles, are registered with rm when it does not already hold a role with the same id;
+// roles already held by rm are treated as authoritative.
+func MigrateRoles(o PolicyOptions, rm RoleManager) (PolicyOptions, error) {
+	refs := append([]string{}, o.RoleRefs...)
+
+	for _, r := range o.Roles {
+		er, err := r.EffectiveRoles()
+		if err != nil {
+			return o, err
+		}
+
+		for _, sr := range er {
+			if _, err := rm.Get(sr.ID); err == nil {
+				continue
+			}
+
+			if err := rm.Create(sr); err != nil {
+				return o, err
+			}
+		}
+
+		refs = append(refs, r.ID)
+	}
+
+	o.Roles = nil
+	o.RoleRefs = refs
+
+	return o, nil
+}
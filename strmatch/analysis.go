@@ -0,0 +1,473 @@
+package strmatch
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Verdict is the answer to a question about patterns, which may be Unknown when it cannot be decided.
+type Verdict int
+
+// Verdicts of the pattern analysis functions.
+const (
+	Unknown Verdict = iota
+	No
+	Yes
+)
+
+// String returns the name of the verdict.
+func (v Verdict) String() string {
+	switch v {
+	case Yes:
+		return "yes"
+	case No:
+		return "no"
+	}
+
+	return "unknown"
+}
+
+// analysisBudget bounds the number of transitions explored before the analysis gives up with Unknown.
+const analysisBudget = 1 << 20
+
+// WildcardCovers decides whether wildcard pattern a covers pattern b, that is whether every value b matches
+// with MatchWildcard is matched by a too. When it does not, the returned witness is a value b matches and a
+// does not. The verdict is Unknown only for patterns too large to analyse.
+func WildcardCovers(a, b string) (Verdict, string) {
+	return covers(newWildcardNFA(a), newWildcardNFA(b))
+}
+
+// WildcardOverlaps decides whether wildcard patterns a and b match a common value, which is returned as the
+// witness. The verdict is Unknown only for patterns too large to analyse.
+func WildcardOverlaps(a, b string) (Verdict, string) {
+	return overlaps(newWildcardNFA(a), newWildcardNFA(b))
+}
+
+// DelimitedCovers is WildcardCovers for patterns that may hold regular expressions between delimStart and
+// delimEnd, as matched by CompileDelimitedRegex. Patterns without delimiters are wildcard patterns. The
+// verdict is Unknown for expressions using line or word boundaries, and for patterns too large to analyse.
+// Invalid patterns are an error.
+func DelimitedCovers(a, b string, delimStart, delimEnd rune) (Verdict, string, error) {
+	na, nb, ok, err := delimitedNFAs(a, b, delimStart, delimEnd)
+	if err != nil || !ok {
+		return Unknown, "", err
+	}
+
+	v, w := covers(na, nb)
+
+	return v, w, nil
+}
+
+// DelimitedOverlaps is WildcardOverlaps for patterns that may hold regular expressions, see DelimitedCovers.
+func DelimitedOverlaps(a, b string, delimStart, delimEnd rune) (Verdict, string, error) {
+	na, nb, ok, err := delimitedNFAs(a, b, delimStart, delimEnd)
+	if err != nil || !ok {
+		return Unknown, "", err
+	}
+
+	v, w := overlaps(na, nb)
+
+	return v, w, nil
+}
+
+func delimitedNFAs(a, b string, delimStart, delimEnd rune) (nfa, nfa, bool, error) {
+	na, ok, err := newDelimitedNFA(a, delimStart, delimEnd)
+	if err != nil || !ok {
+		return nil, nil, false, err
+	}
+
+	nb, ok, err := newDelimitedNFA(b, delimStart, delimEnd)
+	if err != nil || !ok {
+		return nil, nil, false, err
+	}
+
+	return na, nb, true, nil
+}
+
+// stateSet is a sorted set of automaton states.
+type stateSet []int
+
+func (s stateSet) key(sb *strings.Builder) {
+	for _, st := range s {
+		sb.WriteString(strconv.Itoa(st))
+		sb.WriteByte(',')
+	}
+}
+
+// nfa is a nondeterministic automaton over runes. The analysis runs the subset construction on the fly, so
+// sets of states are the states of the equivalent deterministic automaton.
+type nfa interface {
+	start() stateSet
+	step(s stateSet, r rune) stateSet
+	accepts(s stateSet) bool
+	// bounds appends the runes at which the automaton changes behaviour: every interval between two
+	// consecutive bounds is matched alike.
+	bounds(b []rune) []rune
+}
+
+// wildcardNFA has a state per position in the pattern, the last being accepting.
+type wildcardNFA struct {
+	pattern []rune
+}
+
+func newWildcardNFA(pattern string) *wildcardNFA {
+	return &wildcardNFA{pattern: []rune(pattern)}
+}
+
+func (w *wildcardNFA) closure(set map[int]bool, i int) {
+	set[i] = true
+
+	// a star may match nothing
+	for i < len(w.pattern) && w.pattern[i] == '*' {
+		i++
+		set[i] = true
+	}
+}
+
+func (w *wildcardNFA) start() stateSet {
+	set := make(map[int]bool)
+	w.closure(set, 0)
+
+	return sortedStates(set)
+}
+
+func (w *wildcardNFA) step(s stateSet, r rune) stateSet {
+	set := make(map[int]bool)
+
+	for _, i := range s {
+		if i == len(w.pattern) {
+			continue
+		}
+
+		switch w.pattern[i] {
+		case '*':
+			w.closure(set, i)
+		case '?':
+			w.closure(set, i+1)
+		case r:
+			w.closure(set, i+1)
+		}
+	}
+
+	return sortedStates(set)
+}
+
+func (w *wildcardNFA) accepts(s stateSet) bool {
+	return len(s) > 0 && s[len(s)-1] == len(w.pattern)
+}
+
+func (w *wildcardNFA) bounds(b []rune) []rune {
+	for _, r := range w.pattern {
+		if r != '*' && r != '?' {
+			b = append(b, r, r+1)
+		}
+	}
+
+	return b
+}
+
+// regexNFA runs a compiled regular expression program. Its states are the instructions consuming a rune and
+// the match instruction, plus endState, which accepts only once the input ends because the program asserted
+// the end of the text on its way to a match.
+type regexNFA struct {
+	prog     *syntax.Prog
+	endState int
+}
+
+// newDelimitedNFA builds an automaton for a delimited pattern, reporting false when the expression uses
+// assertions the analysis does not support.
+func newDelimitedNFA(pattern string, delimStart, delimEnd rune) (nfa, bool, error) {
+	if !strings.ContainsRune(pattern, delimStart) {
+		return newWildcardNFA(pattern), true, nil
+	}
+
+	re, err := CompileDelimitedRegex(pattern, delimStart, delimEnd)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil, false, err
+	}
+
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, inst := range prog.Inst {
+		if inst.Op == syntax.InstEmptyWidth &&
+			syntax.EmptyOp(inst.Arg)&^(syntax.EmptyBeginText|syntax.EmptyEndText) != 0 {
+			return nil, false, nil
+		}
+	}
+
+	return &regexNFA{prog: prog, endState: len(prog.Inst)}, true, nil
+}
+
+// closure adds the states reachable from pc without consuming input. atStart tells whether no input was
+// consumed yet, and afterEnd whether the end of the text was asserted, after which no rune may be consumed.
+func (n *regexNFA) closure(set map[int]bool, seen map[int]bool, pc uint32, atStart, afterEnd bool) {
+	id := int(pc) * 2
+	if afterEnd {
+		id++
+	}
+
+	if seen[id] {
+		return
+	}
+
+	seen[id] = true
+
+	inst := n.prog.Inst[pc]
+
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		n.closure(set, seen, inst.Out, atStart, afterEnd)
+		n.closure(set, seen, inst.Arg, atStart, afterEnd)
+	case syntax.InstCapture, syntax.InstNop:
+		n.closure(set, seen, inst.Out, atStart, afterEnd)
+	case syntax.InstEmptyWidth:
+		op := syntax.EmptyOp(inst.Arg)
+		if op&syntax.EmptyBeginText != 0 && !atStart {
+			return
+		}
+
+		n.closure(set, seen, inst.Out, atStart, afterEnd || op&syntax.EmptyEndText != 0)
+	case syntax.InstMatch:
+		if afterEnd {
+			set[n.endState] = true
+		} else {
+			set[int(pc)] = true
+		}
+	case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		if !afterEnd {
+			set[int(pc)] = true
+		}
+	}
+}
+
+func (n *regexNFA) start() stateSet {
+	set := make(map[int]bool)
+	n.closure(set, make(map[int]bool), uint32(n.prog.Start), true, false)
+
+	return sortedStates(set)
+}
+
+func (n *regexNFA) step(s stateSet, r rune) stateSet {
+	set := make(map[int]bool)
+	seen := make(map[int]bool)
+
+	for _, pc := range s {
+		if pc == n.endState {
+			continue
+		}
+
+		inst := n.prog.Inst[pc]
+
+		var ok bool
+
+		switch inst.Op {
+		case syntax.InstRune:
+			ok = inst.MatchRune(r)
+		case syntax.InstRune1:
+			ok = r == inst.Rune[0]
+		case syntax.InstRuneAny:
+			ok = true
+		case syntax.InstRuneAnyNotNL:
+			ok = r != '\n'
+		}
+
+		if ok {
+			n.closure(set, seen, inst.Out, false, false)
+		}
+	}
+
+	return sortedStates(set)
+}
+
+func (n *regexNFA) accepts(s stateSet) bool {
+	for _, pc := range s {
+		if pc == n.endState || n.prog.Inst[pc].Op == syntax.InstMatch {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *regexNFA) bounds(b []rune) []rune {
+	for _, inst := range n.prog.Inst {
+		switch inst.Op {
+		case syntax.InstRune1:
+			b = append(b, inst.Rune[0], inst.Rune[0]+1)
+		case syntax.InstRuneAnyNotNL:
+			b = append(b, '\n', '\n'+1)
+		case syntax.InstRune:
+			if len(inst.Rune) == 1 {
+				// a single rune may match case insensitively, each rune it folds to is its own interval
+				r := inst.Rune[0]
+				b = append(b, r, r+1)
+
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					b = append(b, f, f+1)
+				}
+
+				continue
+			}
+
+			for i := 0; i+1 < len(inst.Rune); i += 2 {
+				b = append(b, inst.Rune[i], inst.Rune[i+1]+1)
+			}
+		}
+	}
+
+	return b
+}
+
+func sortedStates(set map[int]bool) stateSet {
+	s := make(stateSet, 0, len(set))
+	for st := range set {
+		s = append(s, st)
+	}
+
+	sort.Ints(s)
+
+	return s
+}
+
+// alphabet returns a representative rune of every interval of runes that a and b treat alike.
+func alphabet(a, b nfa) []rune {
+	bounds := a.bounds(b.bounds([]rune{0, utf8.MaxRune + 1, 0xD800, 0xE000}))
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	reps := make([]rune, 0, len(bounds))
+
+	for i := 0; i+1 < len(bounds); i++ {
+		lo, hi := bounds[i], bounds[i+1]-1
+		if lo > hi || lo > utf8.MaxRune || (lo >= 0xD800 && hi < 0xE000) {
+			continue
+		}
+
+		reps = append(reps, representative(lo, hi))
+	}
+
+	return reps
+}
+
+// representative prefers a readable rune of the interval from lo to hi, for readable witnesses.
+func representative(lo, hi rune) rune {
+	for _, r := range "xyzabc0123456789XYZ_-" {
+		if r >= lo && r <= hi {
+			return r
+		}
+	}
+
+	for r := lo; r <= hi && r < lo+256; r++ {
+		if unicode.IsPrint(r) {
+			return r
+		}
+	}
+
+	return lo
+}
+
+// pair is a state of the product of two automata, linked to the state it was reached from for witnesses.
+type pair struct {
+	a, b stateSet
+	prev int
+	r    rune
+}
+
+// search explores the product of a and b breadth first, skipping pairs for which prune holds, until a pair
+// satisfying goal is found. It returns the shortest input leading to it, or Unknown when the budget runs out.
+func search(a, b nfa, prune, goal func(p pair) bool) (Verdict, string) {
+	sigma := alphabet(a, b)
+	queue := []pair{{a: a.start(), b: b.start(), prev: -1}}
+	seen := map[string]bool{}
+
+	var sb strings.Builder
+
+	key := func(p pair) string {
+		sb.Reset()
+		p.a.key(&sb)
+		sb.WriteByte('|')
+		p.b.key(&sb)
+
+		return sb.String()
+	}
+
+	seen[key(queue[0])] = true
+	steps := 0
+
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+
+		if prune(p) {
+			continue
+		}
+
+		if goal(p) {
+			return Yes, witness(queue, i)
+		}
+
+		for _, r := range sigma {
+			if steps++; steps > analysisBudget {
+				return Unknown, ""
+			}
+
+			next := pair{a: a.step(p.a, r), b: b.step(p.b, r), prev: i, r: r}
+
+			k := key(next)
+			if !seen[k] {
+				seen[k] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return No, ""
+}
+
+func witness(queue []pair, i int) string {
+	var rs []rune
+
+	for ; queue[i].prev >= 0; i = queue[i].prev {
+		rs = append(rs, queue[i].r)
+	}
+
+	for l, r := 0, len(rs)-1; l < r; l, r = l+1, r-1 {
+		rs[l], rs[r] = rs[r], rs[l]
+	}
+
+	return string(rs)
+}
+
+func overlaps(a, b nfa) (Verdict, string) {
+	return search(a, b,
+		func(p pair) bool { return len(p.a) == 0 || len(p.b) == 0 },
+		func(p pair) bool { return a.accepts(p.a) && b.accepts(p.b) },
+	)
+}
+
+// covers looks for a value b accepts and a rejects; a covers b when there is none.
+func covers(a, b nfa) (Verdict, string) {
+	v, w := search(a, b,
+		func(p pair) bool { return len(p.b) == 0 },
+		func(p pair) bool { return b.accepts(p.b) && !a.accepts(p.a) },
+	)
+
+	switch v {
+	case Yes:
+		return No, w
+	case No:
+		return Yes, ""
+	}
+
+	return Unknown, ""
+}
@@ -0,0 +1,179 @@
+package strmatch
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWildcardCovers(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want Verdict
+	}{
+		{"*", "anything", Yes},
+		{"*", "a*b?", Yes},
+		{"a*", "ab*", Yes},
+		{"ab*", "a*", No},
+		{"a*c", "a?c", Yes},
+		{"a?c", "a*c", No},
+		{"*.example.com", "api.example.com", Yes},
+		{"*.example.com", "*.api.example.com", Yes},
+		{"*.api.example.com", "*.example.com", No},
+		{"documents:*", "documents:*:read", Yes},
+		{"*a*", "?*a", Yes},
+		{"??*", "?*?", Yes},
+		{"?*?", "??*", Yes},
+		{"", "", Yes},
+		{"", "*", No},
+		{"日*", "日本語", Yes},
+	}
+	for _, tt := range tests {
+		got, w := WildcardCovers(tt.a, tt.b)
+		if got != tt.want {
+			t.Errorf("WildcardCovers(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+
+			continue
+		}
+
+		if got == No && (!MatchWildcard(tt.b, w) || MatchWildcard(tt.a, w)) {
+			t.Errorf("WildcardCovers(%q, %q) counterexample %q is wrong", tt.a, tt.b, w)
+		}
+	}
+}
+
+func TestWildcardOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want Verdict
+	}{
+		{"a*", "*b", Yes},
+		{"a*", "b*", No},
+		{"*.read", "documents:*", Yes},
+		{"a?c", "a??c", No},
+		{"*x*", "*y*", Yes},
+		{"x", "x", Yes},
+		{"", "*", Yes},
+		{"?", "", No},
+	}
+	for _, tt := range tests {
+		got, w := WildcardOverlaps(tt.a, tt.b)
+		if got != tt.want {
+			t.Errorf("WildcardOverlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+
+			continue
+		}
+
+		if got == Yes && (!MatchWildcard(tt.a, w) || !MatchWildcard(tt.b, w)) {
+			t.Errorf("WildcardOverlaps(%q, %q) witness %q is wrong", tt.a, tt.b, w)
+		}
+	}
+}
+
+func TestDelimitedAnalysis(t *testing.T) {
+	tests := []struct {
+		a, b         string
+		wantCovers   Verdict
+		wantOverlaps Verdict
+	}{
+		{"documents/<[0-9]+>", "documents/42", Yes, Yes},
+		{"documents/<[0-9]+>", "documents/<[1-9][0-9]*>", Yes, Yes},
+		{"documents/<[1-9][0-9]*>", "documents/<[0-9]+>", No, Yes},
+		{"documents/*", "documents/<[0-9]+>", Yes, Yes},
+		{"documents/<[0-9]+>", "documents/*", No, Yes},
+		{"<[a-z]+>", "<[0-9]+>", No, No},
+		{"<(?i)abc>", "ABC", Yes, Yes},
+		{"<a|b>", "<[ab]>", Yes, Yes},
+		{"<.*>", "<(?s).*>", No, Yes},
+		{"<\\bword>", "word", Unknown, Unknown},
+	}
+	for _, tt := range tests {
+		got, w, err := DelimitedCovers(tt.a, tt.b, '<', '>')
+		if err != nil {
+			t.Fatalf("DelimitedCovers(%q, %q) error = %v", tt.a, tt.b, err)
+		}
+
+		if got != tt.wantCovers {
+			t.Errorf("DelimitedCovers(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.wantCovers)
+		} else if got == No && (!delimitedMatch(t, tt.b, w) || delimitedMatch(t, tt.a, w)) {
+			t.Errorf("DelimitedCovers(%q, %q) counterexample %q is wrong", tt.a, tt.b, w)
+		}
+
+		got, w, err = DelimitedOverlaps(tt.a, tt.b, '<', '>')
+		if err != nil {
+			t.Fatalf("DelimitedOverlaps(%q, %q) error = %v", tt.a, tt.b, err)
+		}
+
+		if got != tt.wantOverlaps {
+			t.Errorf("DelimitedOverlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.wantOverlaps)
+		} else if got == Yes && (!delimitedMatch(t, tt.a, w) || !delimitedMatch(t, tt.b, w)) {
+			t.Errorf("DelimitedOverlaps(%q, %q) witness %q is wrong", tt.a, tt.b, w)
+		}
+	}
+
+	if _, _, err := DelimitedCovers("<[>", "a", '<', '>'); err == nil {
+		t.Error("DelimitedCovers() with an invalid expression error = nil")
+	}
+}
+
+func delimitedMatch(t *testing.T, pattern, val string) bool {
+	t.Helper()
+
+	if !strings.ContainsRune(pattern, '<') {
+		return MatchWildcard(pattern, val)
+	}
+
+	re, err := CompileDelimitedRegex(pattern, '<', '>')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return re.MatchString(val)
+}
+
+// TestWildcardAnalysisEquivalence checks verdicts against matching every short value over the patterns'
+// alphabet, which is exhaustive evidence for counterexamples and witnesses found by brute force.
+func TestWildcardAnalysisEquivalence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	symbols := []string{"a", "b", "*", "?"}
+
+	var values []string
+
+	var gen func(prefix string, n int)
+	gen = func(prefix string, n int) {
+		values = append(values, prefix)
+
+		if n > 0 {
+			for _, r := range []string{"a", "b", "c"} {
+				gen(prefix+r, n-1)
+			}
+		}
+	}
+
+	gen("", 6)
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomWildcard(rng, symbols, 4), randomWildcard(rng, symbols, 4)
+
+		covered, common := true, false
+
+		for _, v := range values {
+			ma, mb := MatchWildcard(a, v), MatchWildcard(b, v)
+			if mb && !ma {
+				covered = false
+			}
+
+			if ma && mb {
+				common = true
+			}
+		}
+
+		if got, _ := WildcardCovers(a, b); (got == Yes) != covered {
+			t.Fatalf("WildcardCovers(%q, %q) = %v, brute force %v", a, b, got, covered)
+		}
+
+		if got, _ := WildcardOverlaps(a, b); (got == Yes) != common {
+			t.Fatalf("WildcardOverlaps(%q, %q) = %v, brute force %v", a, b, got, common)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"unicode/utf8"
 )
 
 // ExtractDelimited returns a slice of values found between the given start and end delimiters.
@@ -17,9 +18,8 @@ func ExtractDelimited(s string, delimStart, delimEnd rune) ([]string, error) {
 	var end int
 
 	for i := 0; i < len(idxs); i += 2 {
-		// rval := s[end:idx[i]]
 		end = idxs[i+1]
-		match := s[idxs[i]+1 : end-1]
+		match := s[idxs[i]+utf8.RuneLen(delimStart) : end-utf8.RuneLen(delimEnd)]
 
 		vidx := i / 2
 
@@ -44,7 +44,7 @@ func CompileDelimitedRegex(s string, delimStart, delimEnd rune) (*regexp.Regexp,
 	for i := 0; i < len(idxs); i += 2 {
 		raw := s[end:idxs[i]]
 		end = idxs[i+1]
-		patt := s[idxs[i]+1 : end-1]
+		patt := s[idxs[i]+utf8.RuneLen(delimStart) : end-utf8.RuneLen(delimEnd)]
 
 		_, err := fmt.Fprintf(pattern, "%s(%s)", regexp.QuoteMeta(raw), patt)
 		if err != nil {
@@ -64,21 +64,22 @@ func CompileDelimitedRegex(s string, delimStart, delimEnd rune) (*regexp.Regexp,
 	return reg, nil
 }
 
+// delimIndices returns the byte offsets of every outermost delimited section of s, as pairs of the offset of
+// its start delimiter and the offset just past its end delimiter. Sections nest unless both delimiters are the
+// same rune, in which case they alternate.
 func delimIndices(s string, delimStart, delimEnd rune) ([]int, error) {
 	var level, idx int
 	idxs := make([]int, 0)
 
-	rs := []rune(s)
-
-	for i := 0; i < len(s); i++ {
-		switch rs[i] {
-		case delimStart:
+	for i, r := range s {
+		switch {
+		case r == delimStart && (delimStart != delimEnd || level == 0):
 			if level++; level == 1 {
 				idx = i
 			}
-		case delimEnd:
+		case r == delimEnd:
 			if level--; level == 0 {
-				idxs = append(idxs, idx, i+1)
+				idxs = append(idxs, idx, i+utf8.RuneLen(r))
 			} else if level < 0 {
 				return nil, fmt.Errorf("unbalanced escape sequence %q", s)
 			}
@@ -42,6 +42,52 @@ func TestExtractDelimited(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "multibyte text",
+			args: args{
+				s:          "日本語/<[0-9]+>/é",
+				delimStart: '<',
+				delimEnd:   '>',
+			},
+			want: []string{
+				"[0-9]+",
+			},
+			wantErr: false,
+		},
+		{
+			name: "multibyte delimiters",
+			args: args{
+				s:          "docs/«[a-z]+»",
+				delimStart: '«',
+				delimEnd:   '»',
+			},
+			want: []string{
+				"[a-z]+",
+			},
+			wantErr: false,
+		},
+		{
+			name: "same delimiters",
+			args: args{
+				s:          "docs/|[a-z]+|/|[0-9]|",
+				delimStart: '|',
+				delimEnd:   '|',
+			},
+			want: []string{
+				"[a-z]+",
+				"[0-9]",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unbalanced",
+			args: args{
+				s:          "docs/<[a-z]+",
+				delimStart: '<',
+				delimEnd:   '>',
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -81,6 +127,37 @@ func TestCompileDelimitedRegex(t *testing.T) {
 			want:    true,
 			wantErr: false,
 		},
+		{
+			name: "multibyte text",
+			args: args{
+				s:          "日本語/<[0-9]+>",
+				delimStart: '<',
+				delimEnd:   '>',
+			},
+			match:   "日本語/42",
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "braces",
+			args: args{
+				s:          "documents/{[0-9]+}",
+				delimStart: '{',
+				delimEnd:   '}',
+			},
+			match:   "documents/x",
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "invalid regex",
+			args: args{
+				s:          "documents/<[0-9>",
+				delimStart: '<',
+				delimEnd:   '>',
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -90,6 +167,10 @@ func TestCompileDelimitedRegex(t *testing.T) {
 				return
 			}
 
+			if err != nil {
+				return
+			}
+
 			if got := reg.MatchString(tt.match); got != tt.want {
 				t.Errorf("CompileDelimitedRegex() = %v, want %v", got, tt.want)
 			}
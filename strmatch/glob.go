@@ -0,0 +1,264 @@
+package strmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompileGlob returns a regular expression matching values by the segment aware glob pattern s, in which
+// segments are divided by sep. A * matches any run of characters within a segment and ** any run across
+// segments, a whole **/ segment also matching no segment at all. A ? matches one character other than sep.
+// A class such as [a-z] matches one of its characters, [!a-z] or [^a-z] one outside it, and never sep.
+// Alternatives such as {a,b} may hold patterns and nest. A backslash escapes the character after it. Each
+// wildcard, class and alternation is a group of the expression, numbered in the order they appear; a whole **/
+// segment captures the segments it matched without the trailing sep.
+func CompileGlob(s string, sep rune) (*regexp.Regexp, error) {
+	g := globCompiler{src: s, sep: sep}
+
+	var sb strings.Builder
+
+	sb.WriteByte('^')
+
+	if err := g.sequence(&sb, false); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", s, err)
+	}
+
+	if g.pos < len(g.src) {
+		return nil, fmt.Errorf("invalid glob %q: unexpected %q", s, g.src[g.pos])
+	}
+
+	sb.WriteByte('$')
+
+	return regexp.Compile(sb.String())
+}
+
+// MatchGlob evaluates to true when val matches the glob pattern with segments divided by sep, see CompileGlob.
+func MatchGlob(pattern, val string, sep rune) (bool, error) {
+	re, err := CompileGlob(pattern, sep)
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(val), nil
+}
+
+type globCompiler struct {
+	src   string
+	pos   int
+	sep   rune
+	depth int
+}
+
+func (g *globCompiler) next() (rune, bool) {
+	if g.pos >= len(g.src) {
+		return 0, false
+	}
+
+	r, w := utf8.DecodeRuneInString(g.src[g.pos:])
+	g.pos += w
+
+	return r, true
+}
+
+func (g *globCompiler) peek() rune {
+	r, _ := utf8.DecodeRuneInString(g.src[g.pos:])
+	return r
+}
+
+// atSegmentStart tells whether the rune before offset i begins a segment.
+func (g *globCompiler) atSegmentStart(i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	r, _ := utf8.DecodeLastRuneInString(g.src[:i])
+
+	return r == g.sep
+}
+
+// sequence translates the pattern up to its end, or up to the , or } closing an alternative when inAlt.
+func (g *globCompiler) sequence(sb *strings.Builder, inAlt bool) error {
+	sep := regexp.QuoteMeta(string(g.sep))
+	notSep := "[^" + sep + "]"
+
+	for g.pos < len(g.src) {
+		start := g.pos
+		r, _ := g.next()
+
+		switch r {
+		case '*':
+			if g.pos < len(g.src) && g.peek() == '*' {
+				g.pos++
+
+				// a whole **/ segment stands for any number of leading segments, none included
+				if g.atSegmentStart(start) && g.pos < len(g.src) && g.peek() == g.sep {
+					g.next()
+					sb.WriteString("(?:(.*)" + sep + ")?")
+
+					continue
+				}
+
+				sb.WriteString("(.*)")
+
+				continue
+			}
+
+			sb.WriteString("(" + notSep + "*)")
+		case '?':
+			sb.WriteString("(" + notSep + ")")
+		case '[':
+			if err := g.class(sb); err != nil {
+				return err
+			}
+		case '{':
+			if err := g.alternation(sb); err != nil {
+				return err
+			}
+		case ',', '}':
+			if inAlt {
+				g.pos = start
+				return nil
+			}
+
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		case '\\':
+			e, ok := g.next()
+			if !ok {
+				return fmt.Errorf("trailing escape")
+			}
+
+			sb.WriteString(regexp.QuoteMeta(string(e)))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if inAlt {
+		return fmt.Errorf("unclosed alternation")
+	}
+
+	return nil
+}
+
+func (g *globCompiler) alternation(sb *strings.Builder) error {
+	if g.depth++; g.depth > 32 {
+		return fmt.Errorf("alternations nested too deeply")
+	}
+
+	defer func() { g.depth-- }()
+
+	sb.WriteByte('(')
+
+	for {
+		if err := g.sequence(sb, true); err != nil {
+			return err
+		}
+
+		r, _ := g.next()
+		if r == '}' {
+			sb.WriteByte(')')
+			return nil
+		}
+
+		sb.WriteByte('|')
+	}
+}
+
+// class translates a character class, removing sep from it so that it never matches across segments.
+func (g *globCompiler) class(sb *strings.Builder) error {
+	negated := false
+	if g.pos < len(g.src) && (g.peek() == '!' || g.peek() == '^') {
+		negated = true
+		g.pos++
+	}
+
+	var ranges [][2]rune
+
+	for first := true; ; first = false {
+		r, ok := g.next()
+		if !ok {
+			return fmt.Errorf("unclosed character class")
+		}
+
+		if r == ']' && !first {
+			break
+		}
+
+		if r == '\\' {
+			if r, ok = g.next(); !ok {
+				return fmt.Errorf("trailing escape")
+			}
+		}
+
+		hi := r
+
+		if g.pos+1 < len(g.src) && g.peek() == '-' && g.src[g.pos+1] != ']' {
+			g.pos++
+
+			if hi, _ = g.next(); hi == '\\' {
+				if hi, ok = g.next(); !ok {
+					return fmt.Errorf("trailing escape")
+				}
+			}
+
+			if hi < r {
+				return fmt.Errorf("invalid character range %c-%c", r, hi)
+			}
+		}
+
+		ranges = append(ranges, [2]rune{r, hi})
+	}
+
+	if negated {
+		ranges = append(ranges, [2]rune{g.sep, g.sep})
+	} else {
+		ranges = withoutRune(ranges, g.sep)
+	}
+
+	if len(ranges) == 0 {
+		// nothing but sep, which no class matches
+		sb.WriteString(`([^\x00-\x{10FFFF}])`)
+		return nil
+	}
+
+	sb.WriteString("([")
+
+	if negated {
+		sb.WriteByte('^')
+	}
+
+	for _, rg := range ranges {
+		fmt.Fprintf(sb, `\x{%x}`, rg[0])
+
+		if rg[1] != rg[0] {
+			fmt.Fprintf(sb, `-\x{%x}`, rg[1])
+		}
+	}
+
+	sb.WriteString("])")
+
+	return nil
+}
+
+func withoutRune(ranges [][2]rune, r rune) [][2]rune {
+	out := make([][2]rune, 0, len(ranges))
+
+	for _, rg := range ranges {
+		if r < rg[0] || r > rg[1] {
+			out = append(out, rg)
+			continue
+		}
+
+		if rg[0] < r {
+			out = append(out, [2]rune{rg[0], r - 1})
+		}
+
+		if r < rg[1] {
+			out = append(out, [2]rune{r + 1, rg[1]})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,71 @@
+package strmatch
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		val     string
+		sep     rune
+		want    bool
+	}{
+		{"articles/*", "articles/1", '/', true},
+		{"articles/*", "articles/1/comments/9", '/', false},
+		{"articles/**", "articles/1/comments/9", '/', true},
+		{"articles/*/comments/*", "articles/1/comments/9", '/', true},
+		{"articles/**/9", "articles/1/comments/9", '/', true},
+		{"articles/**/9", "articles/9", '/', true},
+		{"**/9", "9", '/', true},
+		{"**/9", "a/b/9", '/', true},
+		{"a**b", "a/x/b", '/', true},
+		{"articles/?", "articles/1", '/', true},
+		{"articles/?", "articles//", '/', false},
+		{"articles/[0-9]", "articles/7", '/', true},
+		{"articles/[!0-9]", "articles/x", '/', true},
+		{"articles/[!0-9]", "articles/7", '/', false},
+		{"articles/[^0-9]", "articles//", '/', false},
+		{"a[!x]b", "a/b", '/', false},
+		{"a[+-0]b", "a/b", '/', false},
+		{"a[+-0]b", "a.b", '/', true},
+		{"a[/]b", "a/b", '/', false},
+		{"a[]]b", "a]b", '/', true},
+		{"a[\\]]b", "a]b", '/', true},
+		{"{articles,posts}/*", "posts/1", '/', true},
+		{"{articles,posts}/*", "users/1", '/', false},
+		{"{articles/{1,2},users/*}", "articles/2", '/', true},
+		{"{articles/{1,2},users/*}", "users/x", '/', true},
+		{"{a,}b", "b", '/', true},
+		{"a,b", "a,b", '/', true},
+		{"a}b", "a}b", '/', true},
+		{"a\\*", "a*", '/', true},
+		{"a\\*", "ab", '/', false},
+		{"a.b+c", "a.b+c", '/', true},
+		{"a.b+c", "a.bbc", '/', false},
+		{"service:*:read", "service:documents:read", ':', true},
+		{"service:*:read", "service:documents:all:read", ':', false},
+		{"service:**:read", "service:documents:all:read", ':', true},
+		{"*.example.com", "api.example.com", '.', true},
+		{"*.example.com", "a.api.example.com", '.', false},
+		{"文書/*", "文書/日本", '/', true},
+		{"", "", '/', true},
+	}
+	for _, tt := range tests {
+		got, err := MatchGlob(tt.pattern, tt.val, tt.sep)
+		if err != nil {
+			t.Errorf("MatchGlob(%q, %q, %q) error = %v", tt.pattern, tt.val, tt.sep, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("MatchGlob(%q, %q, %q) = %v, want %v", tt.pattern, tt.val, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobErrors(t *testing.T) {
+	for _, pattern := range []string{"{a,b", "a[bc", "a\\", "[z-a]", "[a-\\"} {
+		if _, err := CompileGlob(pattern, '/'); err == nil {
+			t.Errorf("CompileGlob(%q) error = nil", pattern)
+		}
+	}
+}
@@ -1,48 +1,368 @@
 package strmatch
 
-// MatchWildcard evaluates to true when the given value matches the search string by wildcard.
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchWildcard evaluates to true when the given value matches the search string by wildcard. A * matches any
+// sequence of characters, including none, and a ? matches exactly one character.
 func MatchWildcard(search, val string) bool {
 	return matchWildcard(search, val, false)
 }
 
-// MatchSimpleWildcard evaluates to true when the given value matches the search string by simple wildcard.
+// MatchSimpleWildcard evaluates to true when the given value matches the search string by simple wildcard. It
+// differs from MatchWildcard in that a ? may also match nothing once the end of the value is reached, so
+// "v1.?" matches both "v1.2" and "v1.".
 func MatchSimpleWildcard(search, val string) bool {
 	return matchWildcard(search, val, true)
 }
 
+// matchWildcard matches the segments of the pattern, the parts between its stars, left to right. The first
+// segment is anchored at the start of the value and the last one at its end. Each segment in between is
+// matched at its leftmost occurrence after the previous one, since the star before it can absorb anything an
+// earlier occurrence would have left. Segments are found with the two-way string search, so matching never
+// backtracks or allocates and takes time linear in len(search) + len(val).
+//
+// The exception are segments after a star holding a ? between other characters, or runes that only compare
+// equal once decoded such as invalid UTF-8. They are tried at every position of the value, taking up to
+// len(val) * len(segment) steps. Whether that happens depends on the pattern alone, not on the value.
 func matchWildcard(search, val string, simple bool) bool {
-	if val == search {
+	if val == search || search == "*" {
 		return true
 	}
 
-	if search == "*" {
-		return true
+	body, lo, hi := wildcardTail(search, simple)
+
+	star := strings.IndexByte(body, '*')
+	if star < 0 {
+		end, ok := matchSegment(body, val, 0)
+		return ok && runesBetween(val[end:], lo, hi)
+	}
+
+	pos, ok := matchSegment(body[:star], val, 0)
+	if !ok {
+		return false
 	}
 
-	rval := []rune(val)
-	rsearch := []rune(search)
+	for body = body[star+1:]; ; body = body[star+1:] {
+		if star = strings.IndexByte(body, '*'); star < 0 {
+			break
+		}
 
-	return runeSearch(rval, rsearch, simple)
+		if pos, ok = findSegment(body[:star], val, pos); !ok {
+			return false
+		}
+	}
+
+	return matchLastSegment(body, val, pos, lo, hi)
 }
 
-func runeSearch(val, search []rune, simple bool) bool {
-	for len(search) > 0 {
-		switch search[0] {
-		default:
-			if len(val) == 0 || val[0] != search[0] {
-				return false
+// wildcardTail splits the run of stars and question marks ending search from the rest of the pattern, which
+// then ends with a character or is empty. The run only bounds how many runes of the value are left once the
+// rest matched: at least lo and at most hi, or any number from lo when hi is negative. In simple mode its
+// question marks may match nothing.
+func wildcardTail(search string, simple bool) (string, int, int) {
+	i := len(search)
+	for i > 0 && (search[i-1] == '*' || search[i-1] == '?') {
+		i--
+	}
+
+	var (
+		q    int
+		star bool
+	)
+
+	for j := i; j < len(search); j++ {
+		if search[j] == '?' {
+			q++
+		} else {
+			star = true
+		}
+	}
+
+	lo, hi := q, q
+	if simple {
+		lo = 0
+	}
+
+	if star {
+		hi = -1
+	}
+
+	return search[:i], lo, hi
+}
+
+// runesBetween reports whether s holds at least lo and at most hi runes, any number from lo when hi is
+// negative.
+func runesBetween(s string, lo, hi int) bool {
+	n := 0
+
+	for i := 0; i < len(s); n++ {
+		if hi < 0 && n >= lo {
+			return true
+		}
+
+		if n == hi {
+			return false
+		}
+
+		_, w := utf8.DecodeRuneInString(s[i:])
+		i += w
+	}
+
+	return n >= lo
+}
+
+// advanceRunes returns the offset n runes after i in val.
+func advanceRunes(val string, i, n int) (int, bool) {
+	for ; n > 0; n-- {
+		if i >= len(val) {
+			return 0, false
+		}
+
+		_, w := utf8.DecodeRuneInString(val[i:])
+		i += w
+	}
+
+	return i, true
+}
+
+// matchSegment matches seg, which holds no star, at offset i of val and returns where the match ends. A ?
+// matches any one rune while other runes must be equal.
+func matchSegment(seg, val string, i int) (int, bool) {
+	for p := 0; p < len(seg); {
+		if i >= len(val) {
+			return 0, false
+		}
+
+		vr, vw := utf8.DecodeRuneInString(val[i:])
+
+		if seg[p] == '?' {
+			p++
+		} else {
+			pr, pw := utf8.DecodeRuneInString(seg[p:])
+			if pr != vr {
+				return 0, false
 			}
-		case '?':
-			if len(val) == 0 && !simple {
-				return false
+
+			p += pw
+		}
+
+		i += vw
+	}
+
+	return i, true
+}
+
+// findSegment finds the leftmost match of seg in val at or after from and returns where it ends. Question
+// marks at either end of seg only need runes before or after the rest, so they are skipped rather than
+// searched for.
+func findSegment(seg, val string, from int) (int, bool) {
+	lead, trail := 0, 0
+
+	for lead < len(seg) && seg[lead] == '?' {
+		lead++
+	}
+
+	for trail < len(seg)-lead && seg[len(seg)-1-trail] == '?' {
+		trail++
+	}
+
+	from, ok := advanceRunes(val, from, lead)
+	if !ok {
+		return 0, false
+	}
+
+	_, end, ok := indexSegment(seg[lead:len(seg)-trail], val, from)
+	if !ok {
+		return 0, false
+	}
+
+	return advanceRunes(val, end, trail)
+}
+
+// matchLastSegment matches seg, the segment after the last star which ends with a character, so that it starts
+// at or after pos and leaves between lo and hi runes of val, any number from lo when hi is negative.
+func matchLastSegment(seg, val string, pos, lo, hi int) bool {
+	lead := 0
+	for seg[lead] == '?' {
+		lead++
+	}
+
+	pos, ok := advanceRunes(val, pos, lead)
+	if !ok {
+		return false
+	}
+
+	core := seg[lead:]
+
+	if hi < 0 {
+		// the leftmost match leaves the most runes
+		_, end, ok := indexSegment(core, val, pos)
+		return ok && runesBetween(val[end:], lo, -1)
+	}
+
+	// the match has to end between first, leaving hi runes, and last, leaving lo runes
+	n := utf8.RuneCountInString(val)
+	if n < lo {
+		return false
+	}
+
+	skip := n - hi
+	if skip < 0 {
+		skip = 0
+	}
+
+	first, _ := advanceRunes(val, 0, skip)
+	last, _ := advanceRunes(val, first, n-lo-skip)
+
+	if searchable(core) {
+		from := first - len(core)
+		if from < pos {
+			from = pos
+		}
+
+		return newTwoWay(core).index(val[:last], from) >= 0
+	}
+
+	for i := pos; i < len(val); {
+		if end, ok := matchSegment(core, val, i); ok && end >= first && end <= last {
+			return true
+		}
+
+		_, w := utf8.DecodeRuneInString(val[i:])
+		i += w
+	}
+
+	return false
+}
+
+// indexSegment returns the bounds of the leftmost match of core, a segment that neither starts nor ends with
+// a ?, in val at or after from.
+func indexSegment(core, val string, from int) (int, int, bool) {
+	if core == "" {
+		return from, from, true
+	}
+
+	if searchable(core) {
+		i := newTwoWay(core).index(val, from)
+		return i, i + len(core), i >= 0
+	}
+
+	for i := from; i < len(val); {
+		if end, ok := matchSegment(core, val, i); ok {
+			return i, end, true
+		}
+
+		_, w := utf8.DecodeRuneInString(val[i:])
+		i += w
+	}
+
+	return 0, 0, false
+}
+
+// searchable reports whether the occurrences of seg as runes are its occurrences as bytes, so that it can be
+// found with a string search. That holds for valid UTF-8 without ?, as long as it does not hold U+FFFD, which
+// compares equal to every invalid byte once decoded.
+func searchable(seg string) bool {
+	return strings.IndexByte(seg, '?') < 0 && utf8.ValidString(seg) && !strings.ContainsRune(seg, utf8.RuneError)
+}
+
+// twoWay searches for a needle with the two-way algorithm of Crochemore and Perrin, in time linear in the
+// lengths of the needle and the haystack and in constant space.
+type twoWay struct {
+	needle string
+	// ms ends the left half of the critical factorization of the needle, -1 when it is empty
+	ms int
+	// period is the shift after a match of the right half and a mismatch in the left one
+	period int
+	// mem0 is the length of the prefix known to match after that shift, zero unless the needle is periodic
+	mem0 int
+}
+
+func newTwoWay(needle string) twoWay {
+	ms, p := maxSuffix(needle, false)
+	if rms, rp := maxSuffix(needle, true); rms > ms {
+		ms, p = rms, rp
+	}
+
+	t := twoWay{needle: needle, ms: ms, period: p}
+
+	if needle[:ms+1] == needle[p:p+ms+1] {
+		t.mem0 = len(needle) - p
+	} else {
+		t.period = ms + 1
+		if r := len(needle) - ms - 1; r > ms {
+			t.period = r + 1
+		}
+	}
+
+	return t
+}
+
+// maxSuffix returns the position before the lexically greatest suffix of n, by the reverse order when
+// reverse is set, along with the period of that suffix.
+func maxSuffix(n string, reverse bool) (int, int) {
+	ip, jp, k, p := -1, 0, 1, 1
+
+	for jp+k < len(n) {
+		a, b := n[ip+k], n[jp+k]
+
+		switch {
+		case a == b:
+			if k == p {
+				jp += p
+				k = 1
+			} else {
+				k++
 			}
-		case '*':
-			return runeSearch(val, search[1:], simple) || (len(val) > 0 && runeSearch(val[1:], search, simple))
+		case (a > b) != reverse:
+			jp += k
+			k = 1
+			p = jp - ip
+		default:
+			ip = jp
+			jp++
+			k, p = 1, 1
+		}
+	}
+
+	return ip, p
+}
+
+// index returns the offset of the first occurrence of the needle in hay at or after from, or -1.
+func (t twoWay) index(hay string, from int) int {
+	n, l := t.needle, len(t.needle)
+	mem := 0
+
+	for h := from; h+l <= len(hay); {
+		k := t.ms + 1
+		if k < mem {
+			k = mem
+		}
+
+		for k < l && n[k] == hay[h+k] {
+			k++
+		}
+
+		if k < l {
+			h += k - t.ms
+			mem = 0
+
+			continue
+		}
+
+		for k = t.ms + 1; k > mem && n[k-1] == hay[h+k-1]; k-- {
+		}
+
+		if k <= mem {
+			return h
 		}
 
-		val = val[1:]
-		search = search[1:]
+		h += t.period
+		mem = t.mem0
 	}
 
-	return len(val) == 0 && len(search) == 0
+	return -1
 }
@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package strmatch
+
+import "testing"
+
+func FuzzMatchWildcard(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"test*", "test_string"},
+		{"a*b?c", "axxbyc"},
+		{"v1.?", "v1."},
+		{"*a*a*b", "aaaa"},
+		{"日*語", "日本語"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, search, val string) {
+		// the reference backtracks exponentially in the number of stars
+		stars := 0
+		for i := 0; i < len(search); i++ {
+			if search[i] == '*' {
+				stars++
+			}
+		}
+
+		if stars > 6 || len(val) > 64 {
+			t.Skip()
+		}
+
+		for _, simple := range []bool{false, true} {
+			if got, want := matchWildcard(search, val, simple), refMatchWildcard(search, val, simple); got != want {
+				t.Errorf("matchWildcard(%q, %q, %v) = %v, want %v", search, val, simple, got, want)
+			}
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package strmatch
 
-import "testing"
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
 
 func TestMatchSimpleWildcard(t *testing.T) {
 	type args struct {
@@ -20,6 +24,47 @@ func TestMatchSimpleWildcard(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			// the recursive matcher read past the end of the value here and panicked
+			name: "optional trailing character",
+			args: args{
+				search: "v1.?",
+				val:    "v1.",
+			},
+			want: true,
+		},
+		{
+			name: "optional trailing characters",
+			args: args{
+				search: "v1.??",
+				val:    "v1.",
+			},
+			want: true,
+		},
+		{
+			name: "optional trailing character after a star",
+			args: args{
+				search: "v*.?",
+				val:    "v1.",
+			},
+			want: true,
+		},
+		{
+			name: "optional characters only at the end",
+			args: args{
+				search: "v?.1",
+				val:    "v.1",
+			},
+			want: false,
+		},
+		{
+			name: "trailing star and question mark",
+			args: args{
+				search: "ab?*?",
+				val:    "ab",
+			},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -29,3 +74,172 @@ func TestMatchSimpleWildcard(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		search string
+		val    string
+		want   bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"**", "abc", true},
+		{"a*", "a", true},
+		{"*c", "abc", true},
+		{"*b*", "abc", true},
+		{"a*c", "abbbbc", true},
+		{"a*c", "abbbbd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"v1.?", "v1.", false},
+		{"?", "é", true},
+		{"??", "é", false},
+		{"日*語", "日本語", true},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"a*b*c*d", "aXbYcZd", true},
+		{"a*b*c*d", "aXbYcZ", false},
+		{"*a*a*a*a*b", strings.Repeat("a", 100), false},
+		{"*a*a*a*a*b", strings.Repeat("a", 100) + "b", true},
+		{"mississippi", "mis*is*ip*", false},
+		{"mis*is*ip*", "mississippi", true},
+		{"a\xffb", "a\xffb", true},
+		{"a?b", "a\xffb", true},
+	}
+	for _, tt := range tests {
+		if got := MatchWildcard(tt.search, tt.val); got != tt.want {
+			t.Errorf("MatchWildcard(%q, %q) = %v, want %v", tt.search, tt.val, got, tt.want)
+		}
+	}
+}
+
+// refMatchWildcard is the recursive backtracking matcher strmatch used to rely on, with a ? at the end of the
+// value no longer reading past it in simple mode. It is exponential in the number of stars and serves as the
+// reference for the equivalence tests.
+func refMatchWildcard(search, val string, simple bool) bool {
+	if val == search || search == "*" {
+		return true
+	}
+
+	return refRuneSearch([]rune(val), []rune(search), simple)
+}
+
+func refRuneSearch(val, search []rune, simple bool) bool {
+	for len(search) > 0 {
+		switch search[0] {
+		default:
+			if len(val) == 0 || val[0] != search[0] {
+				return false
+			}
+		case '?':
+			if len(val) == 0 && !simple {
+				return false
+			}
+		case '*':
+			return refRuneSearch(val, search[1:], simple) || (len(val) > 0 && refRuneSearch(val[1:], search, simple))
+		}
+
+		if len(val) > 0 {
+			val = val[1:]
+		}
+
+		search = search[1:]
+	}
+
+	return len(val) == 0 && len(search) == 0
+}
+
+// randomWildcard returns a short string over a small alphabet, so random patterns and values match often.
+func randomWildcard(rng *rand.Rand, alphabet []string, max int) string {
+	var sb strings.Builder
+
+	for n := rng.Intn(max + 1); n > 0; n-- {
+		sb.WriteString(alphabet[rng.Intn(len(alphabet))])
+	}
+
+	return sb.String()
+}
+
+func TestMatchWildcardEquivalence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	patterns := []string{"a", "b", "ab", "é", "*", "?", "\xff", "\ufffd"}
+	values := []string{"a", "b", "é", "\xff", "\ufffd", "\xc3"}
+
+	for i := 0; i < 100000; i++ {
+		search := randomWildcard(rng, patterns, 10)
+		val := randomWildcard(rng, values, 12)
+
+		for _, simple := range []bool{false, true} {
+			want := refMatchWildcard(search, val, simple)
+			if got := matchWildcard(search, val, simple); got != want {
+				t.Fatalf("matchWildcard(%q, %q, %v) = %v, want %v", search, val, simple, got, want)
+			}
+		}
+	}
+}
+
+func TestTwoWayIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, alphabet := range [][]string{{"a", "b"}, {"a", "b", "c"}, {"a", "é", "日"}} {
+		for i := 0; i < 20000; i++ {
+			needle := randomWildcard(rng, alphabet, 8)
+			if needle == "" {
+				continue
+			}
+
+			hay := randomWildcard(rng, alphabet, 30)
+			from := rng.Intn(len(hay) + 1)
+
+			want := strings.Index(hay[from:], needle)
+			if want >= 0 {
+				want += from
+			}
+
+			if got := newTwoWay(needle).index(hay, from); got != want {
+				t.Fatalf("index(%q, %q, %d) = %d, want %d", hay, needle, from, got, want)
+			}
+		}
+	}
+}
+
+func TestMatchWildcardAllocations(t *testing.T) {
+	search, val := "*a*?*é*b", strings.Repeat("aé", 50)+"b"
+
+	allocs := testing.AllocsPerRun(100, func() {
+		MatchWildcard(search, val)
+		MatchSimpleWildcard(search, val)
+	})
+
+	if allocs != 0 {
+		t.Errorf("matching allocated %v times", allocs)
+	}
+}
+
+func BenchmarkMatchWildcard(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		search string
+		val    string
+	}{
+		{"literal", "resource:documents:read", "resource:documents:read"},
+		{"prefix", "resource:*", "resource:documents:read"},
+		{"infix", "res*doc*:read", "resource:documents:read"},
+		{"question", "resource:????????s:*", "resource:documents:read"},
+		{"pathological", "a*a*a*a*a*a*b", strings.Repeat("a", 1000)},
+		{"long segment", "*" + strings.Repeat("a", 500) + "b*", strings.Repeat("a", 10000)},
+		{"many segments", strings.Repeat("*a", 500) + "*b", strings.Repeat("a", 10000)},
+		{"unicode", "日*語*", "日本語のテキスト"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				MatchWildcard(bm.search, bm.val)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// ValidationProblem describes a single issue found while validating a policy.
+type ValidationProblem struct {
+	Field   string
+	Message string
+	Warning bool
+}
+
+// String returns the problem prefixed with its field path.
+func (p ValidationProblem) String() string {
+	if p.Field == "" {
+		return p.Message
+	}
+
+	return p.Field + ": " + p.Message
+}
+
+// Validation collects errors and warnings along with the path of the field they were found in. Scoped
+// validations created with Field share the problems of their parent.
+type Validation struct {
+	prefix   string
+	problems *[]ValidationProblem
+}
+
+// NewValidation returns an empty Validation.
+func NewValidation() *Validation {
+	return &Validation{
+		problems: &[]ValidationProblem{},
+	}
+}
+
+// Field returns a Validation scoped to the named field. Index segments such as "[0]" are appended without
+// a separator.
+func (v *Validation) Field(name string) *Validation {
+	return &Validation{
+		prefix:   v.path(name),
+		problems: v.problems,
+	}
+}
+
+// Errorf records an error for field, relative to the scope of the Validation.
+func (v *Validation) Errorf(field, format string, args ...interface{}) {
+	v.add(field, false, format, args...)
+}
+
+// Warnf records a warning for field, relative to the scope of the Validation.
+func (v *Validation) Warnf(field, format string, args ...interface{}) {
+	v.add(field, true, format, args...)
+}
+
+// Errors returns the errors recorded so far.
+func (v *Validation) Errors() []ValidationProblem {
+	return v.filter(false)
+}
+
+// Warnings returns the warnings recorded so far.
+func (v *Validation) Warnings() []ValidationProblem {
+	return v.filter(true)
+}
+
+// Err returns a *ValidationError holding all recorded errors, or nil when there are none. Warnings alone
+// do not produce an error.
+func (v *Validation) Err() error {
+	errs := v.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: errs}
+}
+
+func (v *Validation) add(field string, warning bool, format string, args ...interface{}) {
+	*v.problems = append(*v.problems, ValidationProblem{
+		Field:   v.path(field),
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+func (v *Validation) filter(warning bool) []ValidationProblem {
+	var out []ValidationProblem
+
+	for _, p := range *v.problems {
+		if p.Warning == warning {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+func (v *Validation) path(field string) string {
+	switch {
+	case field == "":
+		return v.prefix
+	case v.prefix == "", strings.HasPrefix(field, "["):
+		return v.prefix + field
+	default:
+		return v.prefix + "." + field
+	}
+}
+
+// ValidationError is returned when validation finds one or more errors.
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+// Error lists every problem of the validation.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.String())
+	}
+
+	return "invalid policy: " + strings.Join(msgs, "; ")
+}
+
+// ConditionValidator is implemented by conditions that can check their own configuration.
+type ConditionValidator interface {
+	Validate(v *Validation)
+}
+
+// ValidatePolicyOptions checks policy options for problems. Conditions are built with reg, or with the
+// default ConditionRegistry when reg is nil, and validated when they implement ConditionValidator.
+func ValidatePolicyOptions(o PolicyOptions, reg ConditionRegistry) *Validation {
+	v, _ := validatePolicyOptions(o, reg)
+
+	return v
+}
+
+// ValidatePolicy checks a built policy for problems, checking its patterns for the <regex> syntax of the
+// default regex Matcher.
+func ValidatePolicy(p Policy) *Validation {
+	return ValidatePolicyMatcher(p, nil)
+}
+
+// ValidatePolicyMatcher checks a built policy for problems, checking its patterns for m. Patterns are compiled
+// when m is a MatcherPrecompiler and left unchecked otherwise. A nil m checks them as ValidatePolicy does.
+func ValidatePolicyMatcher(p Policy, m Matcher) *Validation {
+	v := NewValidation()
+
+	validatePolicyFields(v, policyFields{
+		name:      p.ID(),
+		roles:     p.Roles(),
+		roleRefs:  p.RoleRefs(),
+		actions:   p.Actions(),
+		resources: p.Resources(),
+		scopes:    p.Scopes(),
+		effect:    string(p.Effect()),
+		matcher:   m,
+	})
+
+	for name, c := range p.Conditions() {
+		if cv, ok := c.(ConditionValidator); ok {
+			cv.Validate(v.Field("conditions." + name))
+		}
+	}
+
+	return v
+}
+
+// validatePolicyOptions validates o and returns the conditions it built along the way.
+func validatePolicyOptions(o PolicyOptions, reg ConditionRegistry) (*Validation, Conditions) {
+	v := NewValidation()
+
+	validatePolicyFields(v, policyFields{
+		name:      o.Name,
+		roles:     o.Roles,
+		roleRefs:  o.RoleRefs,
+		actions:   o.Actions,
+		resources: o.Resources,
+		scopes:    o.Scopes,
+		effect:    o.Effect,
+		matcher:   o.Matcher,
+	})
+
+	if reg == nil {
+		reg = NewConditionRegistry()
+	}
+
+	conds := make(Conditions)
+
+	for i, co := range o.Conditions {
+		cv := v.Field(fmt.Sprintf("conditions[%d]", i))
+
+		switch _, exists := conds[co.Name]; {
+		case co.Name == "":
+			cv.Errorf("name", "is required")
+		case exists:
+			cv.Errorf("name", "duplicate condition name %s", co.Name)
+		}
+
+		c, err := newCondition(co, reg)
+		if err != nil {
+			cv.Errorf("", "%v", err)
+			continue
+		}
+
+		if val, ok := c.(ConditionValidator); ok {
+			val.Validate(cv.Field("options"))
+		}
+
+		conds[co.Name] = c
+	}
+
+	return v, conds
+}
+
+type policyFields struct {
+	name      string
+	roles     []*Role
+	roleRefs  []string
+	actions   []string
+	resources []string
+	scopes    []string
+	effect    string
+	matcher   Matcher
+}
+
+func validatePolicyFields(v *Validation, f policyFields) {
+	if f.name == "" {
+		v.Errorf("name", "is required")
+	}
+
+	switch f.effect {
+	case string(PolicyEffectAllow), string(PolicyEffectDeny):
+	case "":
+		v.Warnf("effect", "is not set and defaults to deny")
+	default:
+		v.Errorf("effect", "unknown effect %q", f.effect)
+	}
+
+	if len(f.actions) == 0 {
+		v.Errorf("actions", "at least one action is required")
+	}
+
+	if len(f.roles)+len(f.roleRefs) == 0 {
+		v.Errorf("roles", "at least one role is required")
+	}
+
+	if len(f.resources) == 0 {
+		v.Warnf("resources", "no resources given, the policy applies to every resource")
+	}
+
+	for i, r := range f.roles {
+		switch {
+		case r == nil:
+			v.Errorf(fmt.Sprintf("roles[%d]", i), "role is nil")
+		case r.ID == "":
+			v.Errorf(fmt.Sprintf("roles[%d].id", i), "is required")
+		default:
+			if _, err := r.EffectiveRoles(); err != nil {
+				v.Errorf(fmt.Sprintf("roles[%d]", i), "%v", err)
+			}
+		}
+	}
+
+	for i, id := range f.roleRefs {
+		if id == "" {
+			v.Errorf(fmt.Sprintf("roles[%d]", len(f.roles)+i), "role reference is empty")
+		}
+	}
+
+	validatePatterns(v, "actions", f.actions, f.matcher)
+	validatePatterns(v, "resources", f.resources, f.matcher)
+	validatePatterns(v, "scopes", f.scopes, f.matcher)
+}
+
+// validatePatterns checks the templates of pats and compiles the others for m, or checks their <regex> parts
+// when m is nil.
+func validatePatterns(v *Validation, field string, pats []string, m Matcher) {
+	pc, precompile := m.(MatcherPrecompiler)
+
+	for i, pat := range pats {
+		if isTemplate(pat) {
+			if err := validateTemplate(pat); err != nil {
+				v.Errorf(fmt.Sprintf("%s[%d]", field, i), "%v", err)
+			}
+
+			continue
+		}
+
+		var err error
+
+		switch {
+		case m == nil && strings.ContainsAny(pat, "<>"):
+			_, err = strmatch.CompileDelimitedRegex(pat, '<', '>')
+		case precompile:
+			err = pc.Precompile(pat)
+		}
+
+		if err != nil {
+			v.Errorf(fmt.Sprintf("%s[%d]", field, i), "%v", err)
+		}
+	}
+}